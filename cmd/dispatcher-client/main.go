@@ -3,14 +3,24 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"dispatcher/cmd/internal/setup"
 	"dispatcher/internal/config"
+	deliveryQuic "dispatcher/internal/delivery/quic"
 	deliveryUdp "dispatcher/internal/delivery/udp"
 	"dispatcher/internal/usecase"
+	deltaCompressor "dispatcher/internal/usecase/compressor/delta"
 	gzipCompressor "dispatcher/internal/usecase/compressor/gzip"
+	lz4Compressor "dispatcher/internal/usecase/compressor/lz4"
+	octreeCompressor "dispatcher/internal/usecase/compressor/octree"
+	quantizeCompressor "dispatcher/internal/usecase/compressor/quantize"
+	rangeImageCompressor "dispatcher/internal/usecase/compressor/rangeimage"
 	voxelCompressor "dispatcher/internal/usecase/compressor/voxel"
+	zstdCompressor "dispatcher/internal/usecase/compressor/zstd"
+	velodyneDecoder "dispatcher/internal/usecase/decoder/velodyne"
 	"fmt"
 	"github.com/quic-go/quic-go"
 	"log"
+	"os"
 	"sync"
 	"time"
 )
@@ -30,28 +40,207 @@ func main() {
 		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
 	}
 
-	log.Printf("Конфигурация загружена: соединение с %s:%d, прослушивание на %s:%d",
-		cfg.Network.ServerIP, cfg.Network.ServerPort,
-		cfg.Network.ListenIP, cfg.Network.ListenPort)
+	log.Printf("Конфигурация загружена: ТС %s, соединение с %s:%d, сенсоров: %d",
+		cfg.Network.VehicleID, cfg.Network.ServerIP, cfg.Network.ServerPort, len(cfg.Sensors))
 
-	// UDP слушатель для принятия точек от Velodyne
-	// --------------------------------------------
-	udpChan := make(chan deliveryUdp.Packet, 1024)
-	// Запускаем UDP слушатель
-	err = deliveryUdp.StartUDPListener(cfg.Network.ListenIP, cfg.Network.ListenPort, udpChan)
+	byteChan := make(chan []byte, 1024)
+	processor := usecase.NewPointCloudProcessor()
+	processor.SetSource(cfg.Network.VehicleID, cfg.Processing.FrameID)
+
+	fields, err := usecase.ParsePointFields(cfg.Processing.Fields)
 	if err != nil {
-		log.Fatalf("Ошибка запуска UDP: %v\n", err)
+		log.Fatalf("Ошибка в списке полей точек: %v", err)
 	}
-	byteChan := make(chan []byte, 1024)
-	processor := usecase.NewPointCloudProcessor(float32(cfg.Processing.FilterRadius))
+	processor.SetFields(fields)
+
+	returns, err := usecase.ParseReturnSelection(cfg.Processing.Returns)
+	if err != nil {
+		log.Fatalf("Ошибка в настройке отражений: %v", err)
+	}
+	processor.SetReturnSelection(returns)
+
+	segmentation := cfg.Processing.Segmentation
+	segmentationConfig := usecase.FrameAssemblerConfig{
+		Mode:        usecase.SegmentationMode(segmentation.Mode),
+		CutAzimuth:  float32(segmentation.CutAzimuth),
+		Period:      time.Duration(segmentation.PeriodMs) * time.Millisecond,
+		Packets:     segmentation.Packets,
+		MinCoverage: float32(segmentation.MinCoverage),
+	}
+
+	filters, err := setup.Filters(cfg.Processing.Filters)
+	if err != nil {
+		log.Fatalf("Ошибка в настройке фильтров: %v", err)
+	}
+	processor.SetFilters(filters...)
+
+	if clustering := cfg.Processing.Clustering; clustering.Enabled {
+		clusterer, err := usecase.NewClusterer(usecase.ClusterConfig{
+			Tolerance: float32(clustering.Tolerance),
+			MinPoints: clustering.MinPoints,
+			MaxPoints: clustering.MaxPoints,
+			Oriented:  clustering.Oriented,
+		})
+		if err != nil {
+			log.Fatalf("Ошибка в настройке кластеризации: %v", err)
+		}
+		processor.SetClusterer(clusterer)
+	}
+
+	switch output := cfg.Processing.Output; output {
+	case "cloud":
+	case "grid", "both":
+		grid, err := usecase.NewGridBuilder(usecase.GridConfig{
+			CellSize: float32(cfg.Processing.Grid.CellSize),
+			Extent:   float32(cfg.Processing.Grid.Extent),
+		})
+		if err != nil {
+			log.Fatalf("Ошибка в настройке сетки высот: %v", err)
+		}
+		processor.SetGrid(grid, output == "both")
+	default:
+		log.Fatalf("Неизвестный режим передачи: %q (ожидается cloud, grid или both)", output)
+	}
+
+	switch deskew := cfg.Processing.Deskew; deskew.Source {
+	case "":
+	case "odometry":
+		odometry, err := usecase.NewOdometry(usecase.OdometryConfig{
+			VoxelSize:         float32(deskew.Odometry.VoxelSize),
+			MaxCorrespondence: float32(deskew.Odometry.MaxCorrespondence),
+			MaxIterations:     deskew.Odometry.MaxIterations,
+			MinPairs:          deskew.Odometry.MinPairs,
+		})
+		if err != nil {
+			log.Fatalf("Ошибка в настройке одометрии для компенсации движения: %v", err)
+		}
+		processor.SetMotion(usecase.NewOdometryMotion(odometry))
+	case "udp":
+		feed := usecase.NewVelocityFeed(time.Duration(deskew.MaxAgeMs) * time.Millisecond)
+		motionChan := make(chan deliveryUdp.MotionMessage, 256)
+		if err := deliveryUdp.StartMotionListener(deskew.ListenIP, deskew.ListenPort, motionChan); err != nil {
+			log.Fatalf("Ошибка запуска приёма движения ТС: %v", err)
+		}
+		go func() {
+			for msg := range motionChan {
+				feed.Push(usecase.VelocitySample{
+					Time:     msg.Time,
+					Velocity: usecase.Velocity{Linear: msg.Linear, Angular: msg.Angular},
+				})
+			}
+		}()
+		processor.SetMotion(feed)
+	default:
+		log.Fatalf("Неизвестный источник движения: %q (ожидается odometry или udp)", deskew.Source)
+	}
+
+	// UDP слушатель и декодер для каждого сенсора
+	// --------------------------------------------
+	ground := cfg.Processing.Ground
+	groundConfig := usecase.GroundConfig{
+		Method:            usecase.GroundMethod(ground.Method),
+		Mode:              usecase.GroundMode(ground.Mode),
+		GroundZ:           float32(ground.GroundZ),
+		MaxHeight:         float32(ground.MaxHeight),
+		MaxSlope:          float32(ground.MaxSlope),
+		DistanceThreshold: float32(ground.DistanceThreshold),
+		Iterations:        ground.Iterations,
+		AzimuthBins:       ground.AzimuthBins,
+		DecimateVoxel:     float32(ground.DecimateVoxel),
+	}
+
+	sensors := make([]*usecase.Sensor, 0, len(cfg.Sensors))
+	sensorIDs := make([]uint8, 0, len(cfg.Sensors))
+	for _, sensorCfg := range cfg.Sensors {
+		sensor, err := startSensor(sensorCfg, segmentationConfig)
+		if err != nil {
+			log.Fatalf("Ошибка запуска сенсора %d: %v", sensorCfg.ID, err)
+		}
+		if ground.Method != "" {
+			// У каждого сенсора свой сегментатор: они работают в разных горутинах
+			segmenter, err := usecase.NewGroundSegmenter(groundConfig)
+			if err != nil {
+				log.Fatalf("Ошибка в настройке сегментации земли: %v", err)
+			}
+			sensor.SetGround(segmenter)
+		}
+		processor.AddSensor(sensor)
+		sensors = append(sensors, sensor)
+		sensorIDs = append(sensorIDs, sensor.ID)
+	}
+
+	fuser := usecase.NewFrameFuser(sensorIDs, usecase.FusionConfig{
+		MaxSkew: time.Duration(cfg.Processing.Fusion.MaxSkewMs) * time.Millisecond,
+		MaxWait: time.Duration(cfg.Processing.Fusion.MaxWaitMs) * time.Millisecond,
+	})
+	processor.SetFuser(fuser)
+
+	// Периодически выводим статистику сборки кадров
+	go func() {
+		for range time.Tick(10 * time.Second) {
+			for _, sensor := range sensors {
+				stats := sensor.Stats()
+				log.Printf("Статистика кадров сенсора %d: собрано %d, неполных %d, отброшено начальных %d",
+					sensor.ID, stats.Frames, stats.Incomplete, stats.Discarded)
+			}
+			if len(sensors) > 1 {
+				stats := fuser.Stats()
+				log.Printf("Статистика объединения: кадров %d, без части сенсоров %d, по часам хоста %d",
+					stats.Frames, stats.Partial, stats.Unsynced)
+			}
+			if cfg.Processing.Deskew.Source != "" {
+				compensated, missing := processor.DeskewStats()
+				log.Printf("Статистика компенсации движения: кадров %d, без известной скорости %d", compensated, missing)
+			}
+		}
+	}()
 
-	// сначала voxel, потом gzip
-	processor.SetCompressors(
-		voxelCompressor.NewVoxelCompressor(float32(cfg.Processing.VoxelSize)),
-		gzipCompressor.NewGzipCompressor(),
-	)
+	// сначала voxel, затем квантование, октодерево или дельта, если заданы, и общая ступень сжатия;
+	// изображение дальностей заменяет всю цепочку
+	compressors := []usecase.PointCloudCompressor{voxelCompressor.NewVoxelCompressor(float32(cfg.Processing.VoxelSize))}
+	quantization, octree, delta := cfg.Processing.Quantization, cfg.Processing.Octree, cfg.Processing.Delta
+	rangeImage := cfg.Processing.RangeImage
+	var deltaCodec *deltaCompressor.DeltaCompressor
+	switch {
+	case quantization < 0 || octree.LeafSize < 0:
+		log.Fatalf("Шаг квантования и размер листа октодерева не могут быть отрицательными")
+	case boolCount(quantization > 0, octree.LeafSize > 0, delta.Enabled, rangeImage.Enabled) > 1:
+		log.Fatalf("Квантование, октодерево, дельта и изображение дальностей не применяются вместе: задайте только одно из quantization, octree.leafSize, delta, rangeImage")
+	case rangeImage.Enabled:
+		if cfg.Processing.Deskew.Source != "" {
+			log.Fatalf("Изображение дальностей передаёт измерения сенсора и несовместимо с компенсацией движения (processing.deskew.source)")
+		}
+		// Проверяем параметры заранее, а не на первом кадре
+		if err := usecase.ValidateRangeImageConfig(float32(rangeImage.AzimuthStep), float32(rangeImage.RangeUnit)); err != nil {
+			log.Fatalf("Ошибка в настройке изображения дальностей: %v", err)
+		}
+		compressors = []usecase.PointCloudCompressor{
+			rangeImageCompressor.NewRangeImageCompressor(float32(rangeImage.AzimuthStep), float32(rangeImage.RangeUnit)),
+		}
+	case quantization > 0:
+		compressors = append(compressors, quantizeCompressor.NewQuantizeCompressor(float32(quantization)))
+	case octree.LeafSize > 0:
+		compressors = append(compressors, octreeCompressor.NewOctreeCompressor(float32(octree.LeafSize), octree.Arithmetic))
+	case delta.Enabled:
+		if delta.VoxelSize <= 0 || delta.KeyframeInterval < 1 {
+			log.Fatalf("Недопустимые параметры дельты: voxelSize %v, keyframeInterval %d", delta.VoxelSize, delta.KeyframeInterval)
+		}
+		deltaCodec = deltaCompressor.NewDeltaCompressor(float32(delta.VoxelSize), delta.KeyframeInterval)
+		compressors = append(compressors, deltaCodec)
+	}
+	if !rangeImage.Enabled {
+		// Потоки изображения дальностей уже сжаты арифметическим кодером
+		general, err := generalCompressor(cfg.Processing.Compression.Codec, cfg.Processing.Compression.Level, cfg.Processing.Compression.Dictionary)
+		if err != nil {
+			log.Fatalf("Ошибка в настройке сжатия: %v", err)
+		}
+		if general != nil {
+			compressors = append(compressors, general)
+		}
+	}
+	processor.SetCompressors(compressors...)
 
-	go processor.Tx(udpChan, byteChan)
+	go processor.Tx(byteChan)
 
 	// Подключение по QUIC к удалённому серверу
 	// ------------------------------------------------
@@ -68,6 +257,23 @@ func main() {
 	if err != nil {
 		log.Fatalf("Ошибка подключения к QUIC серверу: %v", err)
 	}
+	// Первым потоком сообщаем серверу, какое ТС подключилось
+	if err := deliveryQuic.SendHandshake(ctx, conn, cfg.Network.VehicleID); err != nil {
+		log.Fatalf("Ошибка рукопожатия с QUIC сервером: %v", err)
+	}
+	// Сервер запрашивает опорный кадр, если не смог восстановить дельту
+	go func() {
+		err := deliveryQuic.AcceptControl(ctx, conn, func(msg deliveryQuic.ControlMessage) {
+			switch {
+			case msg == deliveryQuic.ControlKeyframe && deltaCodec != nil:
+				log.Printf("Сервер запросил опорный кадр")
+				deltaCodec.RequestKeyframe()
+			default:
+				log.Printf("Пропущено сообщение управления %d", msg)
+			}
+		})
+		log.Printf("Приём сообщений управления остановлен: %v", err)
+	}()
 
 	for {
 		// Принимаем данные от Velodyne
@@ -112,3 +318,93 @@ func main() {
 		log.Printf("Отправлено %d байт по QUIC", len(sendBuf))
 	}
 }
+
+// startSensor запускает UDP слушатель сенсора и создаёт его декодер и сборщик кадров
+func startSensor(cfg config.SensorConfig, segmentation usecase.FrameAssemblerConfig) (*usecase.Sensor, error) {
+	var calibration *velodyneDecoder.Calibration
+	if cfg.Calibration != "" {
+		var err error
+		calibration, err = velodyneDecoder.LoadCalibration(cfg.Calibration)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка загрузки калибровки: %w", err)
+		}
+		log.Printf("Сенсор %d: калибровка загружена: %s (%d лазеров)", cfg.ID, cfg.Calibration, len(calibration.Lasers))
+	}
+	decoder, err := velodyneDecoder.NewVelodyneDecoder(cfg.Model, calibration)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания декодера: %w", err)
+	}
+	transform, err := mountTransform(cfg.Mount)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка в положении сенсора: %w", err)
+	}
+	assembler, err := usecase.NewFrameAssembler(segmentation)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка в настройке сегментации: %w", err)
+	}
+
+	udpChan := make(chan deliveryUdp.Packet, 1024)
+	if err := deliveryUdp.StartUDPListener(cfg.ListenIP, cfg.ListenPort, udpChan); err != nil {
+		return nil, fmt.Errorf("ошибка запуска UDP: %w", err)
+	}
+	log.Printf("Сенсор %d: модель %s, прослушивание на %s:%d", cfg.ID, cfg.Model, cfg.ListenIP, cfg.ListenPort)
+	return usecase.NewSensor(uint8(cfg.ID), udpChan, decoder, transform, assembler), nil
+}
+
+// generalCompressor создаёт общую ступень сжатия по имени кодека; для none возвращает nil
+func generalCompressor(codec string, level int, dictionary string) (usecase.PointCloudCompressor, error) {
+	if dictionary != "" && codec != "zstd" {
+		return nil, fmt.Errorf("словарь поддерживается только кодеком zstd, задан %q", codec)
+	}
+	switch codec {
+	case "none":
+		return nil, nil
+	case "gzip":
+		if level != 0 {
+			return nil, fmt.Errorf("уровень gzip не настраивается")
+		}
+		return gzipCompressor.NewGzipCompressor(), nil
+	case "zstd":
+		var dictionaries [][]byte
+		if dictionary != "" {
+			dict, err := os.ReadFile(dictionary)
+			if err != nil {
+				return nil, fmt.Errorf("невозможно прочитать словарь zstd: %w", err)
+			}
+			dictionaries = append(dictionaries, dict)
+			log.Printf("Словарь zstd загружен: %s (%d байт)", dictionary, len(dict))
+		}
+		return zstdCompressor.NewZstdCompressor(level, dictionaries...)
+	case "lz4":
+		return lz4Compressor.NewLz4Compressor(level)
+	default:
+		return nil, fmt.Errorf("неизвестный кодек сжатия: %q (ожидается gzip, zstd, lz4 или none)", codec)
+	}
+}
+
+// boolCount возвращает число истинных условий
+func boolCount(conditions ...bool) int {
+	n := 0
+	for _, c := range conditions {
+		if c {
+			n++
+		}
+	}
+	return n
+}
+
+// mountTransform строит преобразование из системы сенсора в систему ТС по конфигурации
+func mountTransform(mount config.MountConfig) (usecase.Transform, error) {
+	translation := [3]float32{float32(mount.Translation[0]), float32(mount.Translation[1]), float32(mount.Translation[2])}
+	switch len(mount.Quaternion) {
+	case 0:
+		return usecase.NewTransformRPY(translation,
+			float32(mount.RPY[0]), float32(mount.RPY[1]), float32(mount.RPY[2])), nil
+	case 4:
+		q := mount.Quaternion
+		return usecase.NewTransformQuaternion(translation,
+			float32(q[0]), float32(q[1]), float32(q[2]), float32(q[3]))
+	default:
+		return usecase.Transform{}, fmt.Errorf("кватернион должен содержать 4 числа (x, y, z, w), задано %d", len(mount.Quaternion))
+	}
+}
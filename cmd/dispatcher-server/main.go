@@ -3,17 +3,28 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"dispatcher/cmd/internal/setup"
 	"dispatcher/internal/config"
 	deliveryHttp "dispatcher/internal/delivery/http"
+	deliveryQuic "dispatcher/internal/delivery/quic"
 	"dispatcher/internal/usecase"
+	deltaCompressor "dispatcher/internal/usecase/compressor/delta"
 	gzipCompressor "dispatcher/internal/usecase/compressor/gzip"
+	lz4Compressor "dispatcher/internal/usecase/compressor/lz4"
+	octreeCompressor "dispatcher/internal/usecase/compressor/octree"
+	quantizeCompressor "dispatcher/internal/usecase/compressor/quantize"
+	rangeImageCompressor "dispatcher/internal/usecase/compressor/rangeimage"
+	voxelCompressor "dispatcher/internal/usecase/compressor/voxel"
+	zstdCompressor "dispatcher/internal/usecase/compressor/zstd"
 	"errors"
 	"fmt"
 	"github.com/labstack/echo/v4"
 	"github.com/quic-go/quic-go"
 	"io"
 	"log"
+	"os"
 	"sync"
+	"time"
 )
 
 // Создаём пулы буферов для переиспользования
@@ -32,6 +43,13 @@ var dataBufferPool = sync.Pool{
 	},
 }
 
+// Кадры соединения читаются параллельно и перед Rx упорядочиваются по Seq: ждём пропущенный
+// кадр не дольше reorderTimeout (около кадра сенсора 10 Гц) и держим не больше reorderWindow кадров
+const (
+	reorderWindow  = 16
+	reorderTimeout = 150 * time.Millisecond
+)
+
 func main() {
 	// Загружаем конфигурацию
 	cfg, err := config.LoadServerConfig()
@@ -43,22 +61,38 @@ func main() {
 		cfg.Network.ListenIP, cfg.Network.ListenPort,
 		cfg.Network.SseIP, cfg.Network.SsePort)
 
+	// Параметры обработки проверяются один раз; компоненты хранят состояние между кадрами,
+	// поэтому создаются для каждой сессии
+	pipeline, err := newSessionPipeline(cfg)
+	if err != nil {
+		log.Fatalf("Ошибка в настройке обработки: %v", err)
+	}
+
+	// Сессии ТС: у каждого ТС свой Rx pipeline и своя рассылка операторам
+	// ---------------------------------------------------------------------
+	registry := usecase.NewSessionRegistry(pipeline.newProcessor, cfg.Network.SseQueueSize)
+
+	// Периодически удаляем сессии давно отключённых ТС
+	if cfg.Network.SessionTimeout > 0 {
+		sessionTimeout := time.Duration(cfg.Network.SessionTimeout) * time.Second
+		go func() {
+			for range time.Tick(sessionTimeout / 10) {
+				registry.Prune(sessionTimeout)
+			}
+		}()
+	}
+
 	// SSE сервер для операторов
 	// -------------------------
-	pointsChan := make(chan [][]float32, 1024)
-	byteChan := make(chan []byte, 1024)
-	processor := usecase.NewPointCloudProcessor(float32(cfg.Processing.FilterRadius))
-	processor.SetCompressors(
-		gzipCompressor.NewGzipCompressor(),
-	)
-	// обработка приходящих данных
-	go processor.Rx(byteChan, pointsChan)
-
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = true
 
-	deliveryHttp.RegisterSSEHandler(e, deliveryHttp.SSEConfig{CORS: cfg.Network.Cors}, pointsChan)
+	sseConfig := deliveryHttp.SSEConfig{CORS: cfg.Network.Cors}
+	deliveryHttp.RegisterSSEHandler(e, sseConfig, registry)
+	deliveryHttp.RegisterVehiclesHandler(e, sseConfig, registry)
+	deliveryHttp.RegisterGridHandler(e, sseConfig, registry)
+	deliveryHttp.RegisterMapHandler(e, sseConfig, registry)
 
 	// Добавляем отдачу статических файлов Vite
 	e.GET("/*", echo.WrapHandler(deliveryHttp.StaticHandler()))
@@ -97,13 +131,56 @@ func main() {
 			continue
 		}
 		// Запускаем обработку соединения в отдельной горутине
-		go handleConnection(conn, byteChan)
+		go handleConnection(conn, registry)
 	}
 }
 
-func handleConnection(conn *quic.Conn, byteChan chan<- []byte) {
+func handleConnection(conn *quic.Conn, registry *usecase.SessionRegistry) {
 	log.Printf("Новое соединение: %s", conn.RemoteAddr().String())
 
+	// Первый поток соединения должен содержать идентификатор ТС
+	vehicleID, err := deliveryQuic.AcceptHandshake(context.Background(), conn)
+	if err != nil {
+		log.Printf("Ошибка рукопожатия с %s: %v", conn.RemoteAddr().String(), err)
+		_ = conn.CloseWithError(deliveryQuic.HandshakeErrorCode, "handshake failed")
+		return
+	}
+	session, err := registry.Attach(vehicleID)
+	if err != nil {
+		log.Printf("Ошибка создания сессии ТС %s: %v", vehicleID, err)
+		_ = conn.CloseWithError(deliveryQuic.SessionErrorCode, "session failed")
+		return
+	}
+	defer registry.Detach(session)
+	log.Printf("Соединение %s принадлежит ТС %s", conn.RemoteAddr().String(), vehicleID)
+
+	// Запросы опорного кадра от Rx передаются ТС потоками управления
+	go func() {
+		for {
+			select {
+			case <-session.KeyframeRequests():
+				if err := deliveryQuic.SendControl(conn.Context(), conn, deliveryQuic.ControlKeyframe); err != nil {
+					log.Printf("Ошибка отправки запроса опорного кадра ТС %s: %v", vehicleID, err)
+				}
+			case <-conn.Context().Done():
+				return
+			}
+		}
+	}()
+
+	// Потоки читаются параллельно, а Rx получает кадры в порядке Seq
+	reorderer, err := usecase.NewFrameReorderer(reorderWindow, reorderTimeout, session.Push)
+	if err != nil {
+		log.Printf("Ошибка в настройке упорядочивания кадров ТС %s: %v", vehicleID, err)
+		_ = conn.CloseWithError(deliveryQuic.SessionErrorCode, "session failed")
+		return
+	}
+	defer func() {
+		reorderer.Close()
+		skipped, stale := reorderer.Stats()
+		log.Printf("Соединение ТС %s закрыто: пропусков в нумерации кадров %d, устаревших кадров %d", vehicleID, skipped, stale)
+	}()
+
 	// Обрабатываем все потоки от соединения
 	for {
 		stream, err := conn.AcceptUniStream(context.Background())
@@ -161,8 +238,8 @@ func handleConnection(conn *quic.Conn, byteChan chan<- []byte) {
 				// Теперь можем вернуть dataBuf в пул
 				dataBufferPool.Put(dataBuf)
 
-				// Отправляем копию данных в канал
-				byteChan <- result
+				// Отправляем копию данных в pipeline сессии ТС в порядке Seq
+				reorderer.Add(result)
 			} else {
 				// Возвращаем пустой буфер в пул
 				dataBufferPool.Put(dataBuf)
@@ -170,3 +247,147 @@ func handleConnection(conn *quic.Conn, byteChan chan<- []byte) {
 		}(stream)
 	}
 }
+
+// sessionPipeline — проверенные параметры Rx pipeline сессии ТС.
+// Отключённые ступени обработки не заданы (nil).
+type sessionPipeline struct {
+	filters   []config.FilterConfig
+	clusterer *usecase.ClusterConfig
+	tracker   *usecase.TrackerConfig
+	odometry  *usecase.OdometryConfig
+	voxelMap  *usecase.VoxelMapConfig
+	// zstd общий для всех сессий: декодер не хранит состояние между кадрами
+	zstd usecase.PointCloudCompressor
+}
+
+func newSessionPipeline(cfg *config.ServerConfig) (*sessionPipeline, error) {
+	processing := cfg.Processing
+	// Фильтры хранят буферы между кадрами, поэтому здесь цепочка только проверяется,
+	// а каждая сессия собирает свою
+	if _, err := setup.Filters(processing.Filters); err != nil {
+		return nil, fmt.Errorf("фильтры: %w", err)
+	}
+	p := &sessionPipeline{filters: processing.Filters}
+
+	if clustering := processing.Clustering; clustering.Enabled {
+		p.clusterer = &usecase.ClusterConfig{
+			Tolerance: float32(clustering.Tolerance),
+			MinPoints: clustering.MinPoints,
+			MaxPoints: clustering.MaxPoints,
+			Oriented:  clustering.Oriented,
+		}
+		if err := p.clusterer.Validate(); err != nil {
+			return nil, fmt.Errorf("кластеризация: %w", err)
+		}
+	}
+	if tracking := processing.Tracking; tracking.Enabled {
+		p.tracker = &usecase.TrackerConfig{
+			MaxDistance:      float32(tracking.MaxDistance),
+			MaxMisses:        tracking.MaxMisses,
+			MinHits:          tracking.MinHits,
+			ProcessNoise:     float32(tracking.ProcessNoise),
+			MeasurementNoise: float32(tracking.MeasurementNoise),
+		}
+		if err := p.tracker.Validate(); err != nil {
+			return nil, fmt.Errorf("сопровождение препятствий: %w", err)
+		}
+	}
+	if odometry := processing.Odometry; odometry.Enabled {
+		p.odometry = &usecase.OdometryConfig{
+			VoxelSize:         float32(odometry.VoxelSize),
+			MaxCorrespondence: float32(odometry.MaxCorrespondence),
+			MaxIterations:     odometry.MaxIterations,
+			MinPairs:          odometry.MinPairs,
+		}
+		if err := p.odometry.Validate(); err != nil {
+			return nil, fmt.Errorf("одометрия: %w", err)
+		}
+	}
+	if voxelMap := processing.Map; voxelMap.Enabled {
+		if p.odometry == nil {
+			return nil, fmt.Errorf("карта окружения требует включённой одометрии (processing.odometry.enabled)")
+		}
+		p.voxelMap = &usecase.VoxelMapConfig{
+			VoxelSize: float32(voxelMap.VoxelSize),
+			Radius:    float32(voxelMap.Radius),
+			MaxVoxels: voxelMap.MaxVoxels,
+		}
+		if err := p.voxelMap.Validate(); err != nil {
+			return nil, fmt.Errorf("карта окружения: %w", err)
+		}
+	}
+
+	// Словари zstd всех клиентов: декодер выбирает словарь по номеру из кадра
+	var dictionaries [][]byte
+	for _, path := range processing.Compression.Dictionaries {
+		dict, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("невозможно прочитать словарь zstd: %w", err)
+		}
+		dictionaries = append(dictionaries, dict)
+	}
+	zstd, err := zstdCompressor.NewZstdCompressor(0, dictionaries...)
+	if err != nil {
+		return nil, fmt.Errorf("словари zstd: %w", err)
+	}
+	p.zstd = zstd
+	return p, nil
+}
+
+// newProcessor создаёт Rx pipeline новой сессии
+func (p *sessionPipeline) newProcessor() (*usecase.PointCloudProcessor, error) {
+	processor := usecase.NewPointCloudProcessor()
+	filters, err := setup.Filters(p.filters)
+	if err != nil {
+		return nil, err
+	}
+	processor.SetFilters(filters...)
+	lz4, err := lz4Compressor.NewLz4Compressor(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.clusterer != nil {
+		clusterer, err := usecase.NewClusterer(*p.clusterer)
+		if err != nil {
+			return nil, err
+		}
+		processor.SetClusterer(clusterer)
+	}
+	if p.tracker != nil {
+		tracker, err := usecase.NewTracker(*p.tracker)
+		if err != nil {
+			return nil, err
+		}
+		processor.SetTracker(tracker)
+	}
+	if p.odometry != nil {
+		odometry, err := usecase.NewOdometry(*p.odometry)
+		if err != nil {
+			return nil, err
+		}
+		processor.SetOdometry(odometry)
+	}
+	if p.voxelMap != nil {
+		voxelMap, err := usecase.NewVoxelMap(*p.voxelMap)
+		if err != nil {
+			return nil, err
+		}
+		processor.SetVoxelMap(voxelMap)
+	}
+	// Декомпрессоры выбираются по кодекам из заголовка кадра.
+	// Параметры компрессоров на сервере не важны: Decompress вокселя данные не меняет,
+	// а шаг квантования, размеры листа октодерева и вокселя дельты и модель сенсора
+	// изображения дальностей записаны в кадре.
+	// Дельта-кодек хранит последний восстановленный кадр, поэтому создаётся для каждой сессии.
+	processor.SetCompressors(
+		voxelCompressor.NewVoxelCompressor(0),
+		quantizeCompressor.NewQuantizeCompressor(0),
+		octreeCompressor.NewOctreeCompressor(0, false),
+		deltaCompressor.NewDeltaCompressor(0, 0),
+		rangeImageCompressor.NewRangeImageCompressor(0, 0),
+		gzipCompressor.NewGzipCompressor(),
+		p.zstd,
+		lz4,
+	)
+	return processor, nil
+}
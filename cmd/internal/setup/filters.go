@@ -0,0 +1,45 @@
+package setup
+
+import (
+	"dispatcher/internal/config"
+	"dispatcher/internal/usecase"
+	"dispatcher/internal/usecase/filter"
+	"fmt"
+)
+
+// Filters собирает цепочку фильтров по списку из конфигурации, сохраняя порядок
+func Filters(cfgs []config.FilterConfig) ([]usecase.PointCloudFilter, error) {
+	filters := make([]usecase.PointCloudFilter, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		f, err := newFilter(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("фильтр #%d (%s): %w", i, cfg.Type, err)
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+func newFilter(cfg config.FilterConfig) (usecase.PointCloudFilter, error) {
+	switch cfg.Type {
+	case "cropBox":
+		var min, max [3]float32
+		for i := range min {
+			min[i], max[i] = float32(cfg.Min[i]), float32(cfg.Max[i])
+		}
+		return filter.NewCropBoxFilter(min, max, cfg.Negative)
+	case "range":
+		return filter.NewRangeFilter(float32(cfg.MinRange), float32(cfg.MaxRange))
+	case "intensity":
+		if cfg.MinIntensity < 0 || cfg.MinIntensity > 255 {
+			return nil, fmt.Errorf("порог интенсивности должен быть в диапазоне 0..255: %d", cfg.MinIntensity)
+		}
+		return filter.NewIntensityFilter(uint8(cfg.MinIntensity)), nil
+	case "outlier":
+		return filter.NewOutlierFilter(cfg.K, float32(cfg.StdDevMul))
+	case "voxel":
+		return filter.NewVoxelFilter(float32(cfg.VoxelSize))
+	default:
+		return nil, fmt.Errorf("неизвестный тип фильтра")
+	}
+}
@@ -0,0 +1,40 @@
+package setup
+
+import (
+	"dispatcher/internal/config"
+	"testing"
+)
+
+func TestFiltersKeepOrder(t *testing.T) {
+	filters, err := Filters([]config.FilterConfig{
+		{Type: "range", MinRange: 1, MaxRange: 100},
+		{Type: "cropBox", Min: [3]float64{-1, -1, -1}, Max: [3]float64{1, 1, 1}, Negative: true},
+		{Type: "intensity", MinIntensity: 5},
+		{Type: "outlier", K: 8, StdDevMul: 1},
+		{Type: "voxel", VoxelSize: 0.1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"range", "cropBox", "intensity", "outlier", "voxel"}
+	if len(filters) != len(want) {
+		t.Fatalf("фильтров %d, ожидалось %d", len(filters), len(want))
+	}
+	for i, f := range filters {
+		if f.Name() != want[i] {
+			t.Errorf("фильтр #%d: %s, ожидался %s", i, f.Name(), want[i])
+		}
+	}
+}
+
+func TestFiltersRejectInvalid(t *testing.T) {
+	for _, cfg := range []config.FilterConfig{
+		{Type: "intensity", MinIntensity: 256},
+		{Type: "voxel"},
+		{Type: "median"},
+	} {
+		if _, err := Filters([]config.FilterConfig{{Type: "voxel", VoxelSize: 0.1}, cfg}); err == nil {
+			t.Errorf("создан фильтр %+v", cfg)
+		}
+	}
+}
@@ -5,7 +5,9 @@ go 1.24
 require (
 	github.com/chewxy/math32 v1.11.1
 	github.com/google/gopacket v1.1.19
+	github.com/klauspost/compress v1.18.0
 	github.com/labstack/echo/v4 v4.13.4
+	github.com/pierrec/lz4/v4 v4.1.22
 	github.com/quic-go/quic-go v0.53.0
 	gopkg.in/yaml.v3 v3.0.1
 )
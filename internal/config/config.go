@@ -8,6 +8,80 @@ import (
 	"path/filepath"
 )
 
+// FilterConfig описывает одну ступень очистки облака точек. Используются только параметры
+// выбранного типа:
+//
+//	cropBox   — min, max, negative
+//	range     — minRange, maxRange
+//	intensity — minIntensity
+//	outlier   — k, stdDevMul
+//	voxel     — voxelSize
+type FilterConfig struct {
+	Type         string     `yaml:"type"`
+	Min          [3]float64 `yaml:"min,flow,omitempty"` // углы параллелепипеда в системе ТС, метры
+	Max          [3]float64 `yaml:"max,flow,omitempty"`
+	Negative     bool       `yaml:"negative,omitempty"` // удалять точки внутри параллелепипеда
+	MinRange     float64    `yaml:"minRange,omitempty"`
+	MaxRange     float64    `yaml:"maxRange,omitempty"` // 0 — без ограничения
+	MinIntensity int        `yaml:"minIntensity,omitempty"`
+	K            int        `yaml:"k,omitempty"`         // число соседей
+	StdDevMul    float64    `yaml:"stdDevMul,omitempty"` // порог: среднее + stdDevMul·σ расстояний до соседей
+	VoxelSize    float64    `yaml:"voxelSize,omitempty"`
+}
+
+// ClusterConfig задаёт выделение препятствий евклидовой кластеризацией точек, не относящихся к земле
+type ClusterConfig struct {
+	Enabled   bool    `yaml:"enabled"`
+	Tolerance float64 `yaml:"tolerance"` // наибольшее расстояние между соседними точками кластера, метры
+	MinPoints int     `yaml:"minPoints"` // кластеры меньшего размера считаются шумом
+	MaxPoints int     `yaml:"maxPoints"` // кластеры большего размера отбрасываются; 0 — без ограничения
+	Oriented  bool    `yaml:"oriented"`  // повёрнутые по главной оси рамки вместо рамок по осям
+}
+
+func defaultClusterConfig() ClusterConfig {
+	return ClusterConfig{
+		Tolerance: 0.5,
+		MinPoints: 10,
+		MaxPoints: 20000,
+	}
+}
+
+// TrackingConfig задаёт сопровождение препятствий между кадрами на сервере
+type TrackingConfig struct {
+	Enabled          bool    `yaml:"enabled"`
+	MaxDistance      float64 `yaml:"maxDistance"`      // наибольшее смещение препятствия от прогноза трека, метры
+	MaxMisses        int     `yaml:"maxMisses"`        // кадров без сопоставления до удаления трека
+	MinHits          int     `yaml:"minHits"`          // сопоставлений до публикации трека
+	ProcessNoise     float64 `yaml:"processNoise"`     // СКО ускорения объектов, м/с²
+	MeasurementNoise float64 `yaml:"measurementNoise"` // СКО положения центроида препятствия, метры
+}
+
+// OdometryConfig задаёт оценку перемещения ТС сопоставлением соседних кадров (ICP точка — плоскость)
+type OdometryConfig struct {
+	Enabled           bool    `yaml:"enabled"`
+	VoxelSize         float64 `yaml:"voxelSize"`         // прореживание кадров перед сопоставлением, метры
+	MaxCorrespondence float64 `yaml:"maxCorrespondence"` // наибольшее расстояние между точками пары, метры
+	MaxIterations     int     `yaml:"maxIterations"`
+	MinPairs          int     `yaml:"minPairs"` // при меньшем числе пар положение экстраполируется
+}
+
+func defaultOdometryConfig() OdometryConfig {
+	return OdometryConfig{
+		VoxelSize:         0.5,
+		MaxCorrespondence: 1,
+		MaxIterations:     30,
+		MinPairs:          200,
+	}
+}
+
+// MapConfig задаёт накопление карты окружения каждого ТС по положениям одометрии
+type MapConfig struct {
+	Enabled   bool    `yaml:"enabled"`
+	VoxelSize float64 `yaml:"voxelSize"` // размер вокселя карты, метры
+	Radius    float64 `yaml:"radius"`    // воксели дальше от ТС удаляются, метры; 0 — без ограничения
+	MaxVoxels int     `yaml:"maxVoxels"` // наибольшее число вокселей на ТС
+}
+
 // ServerConfig содержит конфигурацию сервера
 type ServerConfig struct {
 	Network struct {
@@ -16,6 +90,10 @@ type ServerConfig struct {
 		SseIP      string `yaml:"sseIP"`
 		SsePort    int    `yaml:"ssePort"`
 		Cors       string `yaml:"cors"`
+		// Длина очереди кадров каждого оператора; при переполнении выбрасываются старые кадры
+		SseQueueSize int `yaml:"sseQueueSize"`
+		// Через сколько секунд неактивности сессия отключённого ТС удаляется
+		SessionTimeout int `yaml:"sessionTimeout"`
 	} `yaml:"network"`
 
 	SSL struct {
@@ -24,22 +102,148 @@ type ServerConfig struct {
 	} `yaml:"ssl"`
 
 	Processing struct {
-		FilterRadius float64 `yaml:"filterRadius"`
+		// Устаревший параметр: то же, что фильтр cropBox с negative: true и полуразмером filterRadius
+		FilterRadius *float64 `yaml:"filterRadius,omitempty"`
+		// Фильтры, применяемые к каждому принятому кадру до рассылки операторам, по порядку
+		Filters []FilterConfig `yaml:"filters"`
+		// Выделение препятствий на сервере; заменяет препятствия, полученные от клиента
+		Clustering ClusterConfig `yaml:"clustering"`
+		// Сопровождение препятствий из кадров клиента или выделенных на сервере
+		Tracking TrackingConfig `yaml:"tracking"`
+		// Одометрия по облакам точек
+		Odometry OdometryConfig `yaml:"odometry"`
+		// Карта окружения; требует включённой одометрии
+		Map MapConfig `yaml:"map"`
+		// Словари zstd, которыми клиенты сжимают кадры (processing.compression.dictionary клиента)
+		Compression struct {
+			Dictionaries []string `yaml:"dictionaries"`
+		} `yaml:"compression"`
 	} `yaml:"processing"`
 }
 
+// MountConfig задаёт положение сенсора на ТС
+type MountConfig struct {
+	Translation [3]float64 `yaml:"translation,flow"` // x, y, z сенсора в системе ТС, метры
+	RPY         [3]float64 `yaml:"rpy,flow"`         // крен, тангаж, рыскание, градусы
+	// Кватернион x, y, z, w; если задан, используется вместо rpy
+	Quaternion []float64 `yaml:"quaternion,flow"`
+}
+
+// SensorConfig описывает один LiDAR на ТС
+type SensorConfig struct {
+	ID         int    `yaml:"id"` // идентификатор сенсора в кадре, 0..255
+	ListenIP   string `yaml:"listenIP"`
+	ListenPort int    `yaml:"listenPort"`
+	// Модель LiDAR: auto, vlp16, puck-hires, vlp32c, hdl32e, hdl64e
+	Model string `yaml:"model"`
+	// Путь к файлу калибровки Velodyne (db.xml или YAML из ROS); пусто — номинальные углы
+	Calibration string      `yaml:"calibration"`
+	Mount       MountConfig `yaml:"mount"`
+}
+
 // ClientConfig содержит конфигурацию клиента
 type ClientConfig struct {
 	Network struct {
+		VehicleID  string `yaml:"vehicleID"`
 		ServerIP   string `yaml:"serverIP"`
 		ServerPort int    `yaml:"serverPort"`
-		ListenIP   string `yaml:"listenIP"`
-		ListenPort int    `yaml:"listenPort"`
+		// Устаревшие параметры: адрес и порт прослушивания UDP первого сенсора из sensors
+		ListenIP   string `yaml:"listenIP,omitempty"`
+		ListenPort int    `yaml:"listenPort,omitempty"`
 	} `yaml:"network"`
 
+	// Сенсоры ТС; кадры нескольких сенсоров объединяются в один
+	Sensors []SensorConfig `yaml:"sensors"`
+
 	Processing struct {
-		FilterRadius float64 `yaml:"filterRadius"`
-		VoxelSize    float64 `yaml:"voxelSize"`
+		// Система координат ТС, в которую переводятся точки
+		FrameID string `yaml:"frameID"`
+		// Необязательные поля точек, передаваемые на сервер: intensity, ring, time, return
+		Fields []string `yaml:"fields"`
+		// Какие отражения оставлять в двойном режиме сенсора: strongest, last, both
+		Returns string `yaml:"returns"`
+		// Устаревший параметр: то же, что фильтр cropBox с negative: true и полуразмером filterRadius
+		FilterRadius *float64 `yaml:"filterRadius,omitempty"`
+		// Фильтры, применяемые к объединённому кадру перед компрессией, по порядку
+		Filters   []FilterConfig `yaml:"filters"`
+		VoxelSize float64        `yaml:"voxelSize"`
+		// Шаг квантования координат, метры: ошибка координат на сервере не больше половины шага; 0 — без квантования
+		Quantization float64 `yaml:"quantization"`
+		// Кодирование облака октодеревом вместо передачи координат; несовместимо с quantization
+		Octree struct {
+			LeafSize   float64 `yaml:"leafSize"`   // размер листа, метры; 0 — отключить
+			Arithmetic bool    `yaml:"arithmetic"` // сжимать байты занятости арифметическим кодером
+		} `yaml:"octree"`
+		// Межкадровое сжатие: опорные кадры и изменения вокселей между ними; несовместимо с quantization и octree
+		Delta struct {
+			Enabled          bool    `yaml:"enabled"`
+			VoxelSize        float64 `yaml:"voxelSize"`        // размер вокселя, метры
+			KeyframeInterval int     `yaml:"keyframeInterval"` // опорный кадр не реже чем через столько кадров
+		} `yaml:"delta"`
+		// Передача кадра изображениями дальностей (кольца × азимут) вместо координат точек, voxel и gzip;
+		// несовместимо с quantization, octree, delta и компенсацией движения
+		RangeImage struct {
+			Enabled     bool    `yaml:"enabled"`
+			AzimuthStep float64 `yaml:"azimuthStep"` // ширина столбца, градусы (0.01..2.55); не больше шага выстрелов сенсора
+			RangeUnit   float64 `yaml:"rangeUnit"`   // единица дальности, метры
+		} `yaml:"rangeImage"`
+		// Общая ступень сжатия в конце цепочки (с rangeImage не применяется). Выбирается для
+		// канала: lz4 меньше нагружает процессор, zstd экономит трафик
+		Compression struct {
+			Codec      string `yaml:"codec"`      // gzip, zstd, lz4 или none
+			Level      int    `yaml:"level"`      // zstd: 0..22, 0 — по умолчанию; lz4: 0 — быстрый, 1..9 — HC
+			Dictionary string `yaml:"dictionary"` // словарь zstd (zstd --train); тот же файл указывается на сервере
+		} `yaml:"compression"`
+
+		// Сегментация потока измерений на кадры
+		Segmentation struct {
+			Mode        string  `yaml:"mode"`        // azimuth, time или packets
+			CutAzimuth  float64 `yaml:"cutAzimuth"`  // азимут разреза, градусы (mode: azimuth)
+			PeriodMs    int     `yaml:"periodMs"`    // длительность кадра, мс (mode: time)
+			Packets     int     `yaml:"packets"`     // пакетов в кадре (mode: packets)
+			MinCoverage float64 `yaml:"minCoverage"` // охват азимута, ниже которого кадр считается неполным
+		} `yaml:"segmentation"`
+
+		// Сегментация земли в кадре каждого сенсора
+		Ground struct {
+			Method            string  `yaml:"method"`            // ransac, ring; пусто — отключить
+			Mode              string  `yaml:"mode"`              // drop, decimate или layer
+			GroundZ           float64 `yaml:"groundZ"`           // высота дороги в системе ТС, метры
+			MaxHeight         float64 `yaml:"maxHeight"`         // допуск высоты земли относительно groundZ, метры
+			MaxSlope          float64 `yaml:"maxSlope"`          // наибольший уклон дороги, градусы
+			DistanceThreshold float64 `yaml:"distanceThreshold"` // допуск расстояния до плоскости (ransac) или по высоте (ring), метры
+			Iterations        int     `yaml:"iterations"`        // число выборок RANSAC
+			AzimuthBins       int     `yaml:"azimuthBins"`       // число азимутальных столбцов (ring)
+			DecimateVoxel     float64 `yaml:"decimateVoxel"`     // размер вокселя для земли (decimate), метры
+		} `yaml:"ground"`
+
+		// Объединение кадров нескольких сенсоров
+		Fusion struct {
+			MaxSkewMs int `yaml:"maxSkewMs"` // наибольшая разница времени съёмки объединяемых кадров, мс
+			MaxWaitMs int `yaml:"maxWaitMs"` // сколько ждать кадр отставшего сенсора, мс
+		} `yaml:"fusion"`
+
+		// Выделение препятствий на клиенте; рамки передаются в кадре вместе с точками
+		Clustering ClusterConfig `yaml:"clustering"`
+
+		// Что передавать на сервер: cloud — облако точек, grid — только сетку высот, both — оба
+		Output string `yaml:"output"`
+		// Сетка высот (вид сверху) по каждому кадру
+		Grid struct {
+			CellSize float64 `yaml:"cellSize"` // сторона ячейки, метры
+			Extent   float64 `yaml:"extent"`   // полуразмер сетки вокруг ТС, метры
+		} `yaml:"grid"`
+
+		// Компенсация движения ТС за время кадра
+		Deskew struct {
+			// Источник скорости: odometry — одометрия по предыдущим кадрам, udp — внешний
+			// источник (ИНС, одометрия шасси); пусто — отключить
+			Source     string         `yaml:"source"`
+			ListenIP   string         `yaml:"listenIP"`   // адрес приёма сообщений о движении (source: udp)
+			ListenPort int            `yaml:"listenPort"` // порт приёма сообщений о движении (source: udp)
+			MaxAgeMs   int            `yaml:"maxAgeMs"`   // наибольшая разница времени кадра и измерения скорости, мс
+			Odometry   OdometryConfig `yaml:"odometry"`   // параметры одометрии (source: odometry); enabled не используется
+		} `yaml:"deskew"`
 	} `yaml:"processing"`
 }
 
@@ -79,12 +283,28 @@ func LoadServerConfig() (*ServerConfig, error) {
 	config.Network.SseIP = "0.0.0.0"
 	config.Network.SsePort = 8080
 	config.Network.Cors = "*"
+	config.Network.SseQueueSize = 2
+	config.Network.SessionTimeout = 600
 
 	// Используем пути к сертификатам внутри /etc/dispatcher/config
 	config.SSL.CertFile = "/etc/dispatcher/config/localhost.pem"
 	config.SSL.KeyFile = "/etc/dispatcher/config/localhost-key.pem"
 
-	config.Processing.FilterRadius = 0.05
+	config.Processing.Filters = []FilterConfig{}
+	config.Processing.Clustering = defaultClusterConfig()
+	config.Processing.Tracking = TrackingConfig{
+		MaxDistance:      2,
+		MaxMisses:        5,
+		MinHits:          3,
+		ProcessNoise:     2,
+		MeasurementNoise: 0.3,
+	}
+	config.Processing.Odometry = defaultOdometryConfig()
+	config.Processing.Map = MapConfig{
+		VoxelSize: 0.2,
+		Radius:    100,
+		MaxVoxels: 300000,
+	}
 
 	// Пытаемся загрузить конфигурацию из файла
 	if _, err := os.Stat(*configPath); err == nil {
@@ -133,8 +353,9 @@ func LoadServerConfig() (*ServerConfig, error) {
 		config.SSL.KeyFile = *keyFile
 	}
 	if *filterRadius != -1 {
-		config.Processing.FilterRadius = *filterRadius
+		config.Processing.FilterRadius = filterRadius
 	}
+	config.Processing.Filters = withRadiusFilter(config.Processing.Filters, config.Processing.FilterRadius, nil)
 
 	return config, nil
 }
@@ -144,10 +365,15 @@ func LoadClientConfig() (*ClientConfig, error) {
 	configPath := flag.String("config", "/etc/dispatcher/client.yaml", "Путь к файлу конфигурации")
 
 	// Сетевые настройки
+	vehicleID := flag.String("vehicle-id", "", "Идентификатор ТС, передаваемый серверу")
 	serverIP := flag.String("server-ip", "", "IP удалённого сервера для QUIC соединения")
 	serverPort := flag.Int("server-port", 0, "Порт удалённого сервера для QUIC соединения")
+
+	// Настройки первого сенсора
 	listenPort := flag.Int("port", 0, "Порт для UDP сервера")
 	listenIP := flag.String("ip", "", "IP для прослушивания UDP")
+	model := flag.String("model", "", "Модель LiDAR (auto - определить по пакетам)")
+	calibration := flag.String("calibration", "", "Путь к файлу калибровки Velodyne (db.xml или YAML)")
 
 	// Настройки обработки
 	filterRadius := flag.Float64("filter-radius", -1, "Радиус фильтрации точек у центра (0 - отключить фильтр)")
@@ -166,11 +392,42 @@ func LoadClientConfig() (*ClientConfig, error) {
 
 	// Создаем дефолтную конфигурацию
 	config := &ClientConfig{}
+	if hostname, err := os.Hostname(); err == nil {
+		config.Network.VehicleID = hostname
+	}
 	config.Network.ServerIP = "localhost"
 	config.Network.ServerPort = 8081
-	config.Network.ListenIP = "0.0.0.0"
-	config.Network.ListenPort = 2368
-	config.Processing.FilterRadius = 0.5
+	config.Sensors = []SensorConfig{{ID: 0, ListenIP: "0.0.0.0", ListenPort: 2368, Model: "auto"}}
+	config.Processing.FrameID = "base_link"
+	config.Processing.Fields = []string{"intensity", "ring", "time", "return"}
+	config.Processing.Returns = "both"
+	config.Processing.Segmentation.Mode = "azimuth"
+	config.Processing.Segmentation.CutAzimuth = 0
+	config.Processing.Segmentation.PeriodMs = 100
+	config.Processing.Segmentation.Packets = 76
+	config.Processing.Segmentation.MinCoverage = 350
+	config.Processing.Ground.Mode = "layer"
+	config.Processing.Ground.MaxHeight = 1
+	config.Processing.Ground.MaxSlope = 10
+	config.Processing.Ground.DistanceThreshold = 0.15
+	config.Processing.Ground.Iterations = 100
+	config.Processing.Ground.AzimuthBins = 900
+	config.Processing.Ground.DecimateVoxel = 0.5
+	config.Processing.Fusion.MaxSkewMs = 50
+	config.Processing.Fusion.MaxWaitMs = 300
+	config.Processing.Clustering = defaultClusterConfig()
+	config.Processing.Output = "cloud"
+	config.Processing.Grid.CellSize = 0.2
+	config.Processing.Grid.Extent = 50
+	config.Processing.Delta.VoxelSize = 0.1
+	config.Processing.Delta.KeyframeInterval = 50
+	config.Processing.RangeImage.AzimuthStep = 0.2
+	config.Processing.RangeImage.RangeUnit = 0.002
+	config.Processing.Compression.Codec = "gzip"
+	config.Processing.Deskew.ListenIP = "127.0.0.1"
+	config.Processing.Deskew.ListenPort = 2370
+	config.Processing.Deskew.MaxAgeMs = 200
+	config.Processing.Deskew.Odometry = defaultOdometryConfig()
 	config.Processing.VoxelSize = 0.05
 
 	// Пытаемся загрузить конфигурацию из файла
@@ -184,8 +441,11 @@ func LoadClientConfig() (*ClientConfig, error) {
 			return nil, fmt.Errorf("невозможно распарсить YAML конфигурацию: %w", err)
 		}
 	} else {
-		// Если файл не существует, создаем его
-		data, err := yaml.Marshal(config)
+		// Если файл не существует, создаем его. Фильтры по умолчанию записываются в файл,
+		// но не в config: без списка filters устаревший filterRadius заменяет их
+		written := *config
+		written.Processing.Filters = defaultClientFilters()
+		data, err := yaml.Marshal(&written)
 		if err != nil {
 			return nil, fmt.Errorf("невозможно сериализовать конфигурацию: %w", err)
 		}
@@ -198,24 +458,91 @@ func LoadClientConfig() (*ClientConfig, error) {
 	}
 
 	// Параметры командной строки имеют приоритет над конфигурационным файлом
+	if *vehicleID != "" {
+		config.Network.VehicleID = *vehicleID
+	}
 	if *serverIP != "" {
 		config.Network.ServerIP = *serverIP
 	}
 	if *serverPort != 0 {
 		config.Network.ServerPort = *serverPort
 	}
+	if len(config.Sensors) == 0 {
+		return nil, fmt.Errorf("в конфигурации не задан ни один сенсор")
+	}
+	if config.Network.ListenIP != "" {
+		config.Sensors[0].ListenIP = config.Network.ListenIP
+	}
+	if config.Network.ListenPort != 0 {
+		config.Sensors[0].ListenPort = config.Network.ListenPort
+	}
 	if *listenIP != "" {
-		config.Network.ListenIP = *listenIP
+		config.Sensors[0].ListenIP = *listenIP
 	}
 	if *listenPort != 0 {
-		config.Network.ListenPort = *listenPort
+		config.Sensors[0].ListenPort = *listenPort
+	}
+	if *model != "" {
+		config.Sensors[0].Model = *model
+	}
+	if *calibration != "" {
+		config.Sensors[0].Calibration = *calibration
 	}
 	if *filterRadius != -1 {
-		config.Processing.FilterRadius = *filterRadius
+		config.Processing.FilterRadius = filterRadius
 	}
+	config.Processing.Filters = withRadiusFilter(config.Processing.Filters, config.Processing.FilterRadius, defaultClientFilters())
 	if *voxelSize != -1 {
 		config.Processing.VoxelSize = *voxelSize
 	}
 
+	// Незаданные в списке параметры сенсоров берутся по умолчанию
+	seen := make(map[int]bool, len(config.Sensors))
+	for i := range config.Sensors {
+		sensor := &config.Sensors[i]
+		if sensor.ListenIP == "" {
+			sensor.ListenIP = "0.0.0.0"
+		}
+		if sensor.Model == "" {
+			sensor.Model = "auto"
+		}
+		if sensor.ID < 0 || sensor.ID > 255 || seen[sensor.ID] {
+			return nil, fmt.Errorf("сенсор #%d: недопустимый или повторный идентификатор %d", i, sensor.ID)
+		}
+		seen[sensor.ID] = true
+		if sensor.ListenPort <= 0 {
+			return nil, fmt.Errorf("сенсор %d: не задан порт для прослушивания UDP", sensor.ID)
+		}
+	}
+
 	return config, nil
 }
+
+// defaultClientFilters — фильтры клиента, если в конфигурации не заданы ни filters, ни filterRadius
+func defaultClientFilters() []FilterConfig {
+	return []FilterConfig{
+		{Type: "cropBox", Min: [3]float64{-0.5, -0.5, -0.5}, Max: [3]float64{0.5, 0.5, 0.5}, Negative: true},
+	}
+}
+
+// withRadiusFilter переводит устаревший filterRadius в фильтр cropBox в начале списка.
+// Если список filters не задан (nil), возвращаются фильтры по умолчанию defaults, а заданный
+// filterRadius заменяет их: 0 отключает фильтры по умолчанию.
+func withRadiusFilter(filters []FilterConfig, radius *float64, defaults []FilterConfig) []FilterConfig {
+	if filters == nil {
+		if radius == nil {
+			return defaults
+		}
+		filters = []FilterConfig{}
+	}
+	if radius == nil || *radius <= 0 {
+		return filters
+	}
+	box := FilterConfig{
+		Type:     "cropBox",
+		Min:      [3]float64{-*radius, -*radius, -*radius},
+		Max:      [3]float64{*radius, *radius, *radius},
+		Negative: true,
+	}
+	return append([]FilterConfig{box}, filters...)
+}
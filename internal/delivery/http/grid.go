@@ -0,0 +1,77 @@
+package http
+
+import (
+	"bytes"
+	"dispatcher/internal/usecase"
+	"github.com/labstack/echo/v4"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+)
+
+// Диапазон высот, который раскрашивается слоем height, метры
+const (
+	gridMinHeight = -2
+	gridMaxHeight = 3
+)
+
+// RegisterGridHandler отдаёт последнюю сетку высот ТС в виде PNG.
+// Параметр layer: height (по умолчанию) — цвет по наибольшей высоте в ячейке,
+// occupancy — яркость по числу точек. Направление вперёд (ось x) — вверх изображения.
+func RegisterGridHandler(e *echo.Echo, config SSEConfig, registry *usecase.SessionRegistry) {
+	e.GET("/grid.png", func(c echo.Context) error {
+		c.Response().Header().Set("Access-Control-Allow-Origin", config.CORS)
+		session, err := resolveVehicle(c, registry)
+		if err != nil {
+			return err
+		}
+		grid := session.Grid()
+		if grid == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "no grid received")
+		}
+		var shade func(usecase.GridCell) color.NRGBA
+		switch c.QueryParam("layer") {
+		case "", "height":
+			shade = heightColor
+		case "occupancy":
+			shade = occupancyColor
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, "unknown layer")
+		}
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, renderGrid(grid, shade)); err != nil {
+			return err
+		}
+		c.Response().Header().Set("Cache-Control", "no-cache")
+		return c.Blob(http.StatusOK, "image/png", buf.Bytes())
+	})
+}
+
+// renderGrid рисует сетку видом сверху: столбцы сетки (ось x) идут снизу вверх,
+// строки (ось y) — справа налево
+func renderGrid(grid *usecase.OccupancyGrid, shade func(usecase.GridCell) color.NRGBA) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, grid.Height, grid.Width))
+	for row := range grid.Height {
+		for col := range grid.Width {
+			img.SetNRGBA(grid.Height-1-row, grid.Width-1-col, shade(grid.Cell(col, row)))
+		}
+	}
+	return img
+}
+
+func occupancyColor(cell usecase.GridCell) color.NRGBA {
+	v := uint8(min(255, int(cell.Hits)*32))
+	return color.NRGBA{R: v, G: v, B: v, A: 255}
+}
+
+// heightColor раскрашивает занятые ячейки от синего (низко) к красному (высоко)
+func heightColor(cell usecase.GridCell) color.NRGBA {
+	if cell.Hits == 0 {
+		return color.NRGBA{A: 255}
+	}
+	t := (cell.MaxZ - gridMinHeight) / (gridMaxHeight - gridMinHeight)
+	t = max(0, min(1, t))
+	return color.NRGBA{R: uint8(255 * t), G: uint8(255 * (1 - 2*max(t-0.5, 0.5-t))), B: uint8(255 * (1 - t)), A: 255}
+}
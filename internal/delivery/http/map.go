@@ -0,0 +1,60 @@
+package http
+
+import (
+	"dispatcher/internal/usecase"
+	"github.com/chewxy/math32"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+// mapSnapshot — карта окружения ТС в системе первого кадра одометрии.
+// Воксели — [i, j, k, x, y, z, интенсивность], как в событии map.
+type mapSnapshot struct {
+	VehicleID string       `json:"vehicle"`
+	VoxelSize float32      `json:"voxelSize"`
+	Voxels    [][7]float32 `json:"voxels"`
+}
+
+// RegisterMapHandler отдаёт текущую карту окружения ТС. Параметр voxel (метры) дополнительно
+// прореживает карту; индексы вокселей тогда считаются для нового размера.
+func RegisterMapHandler(e *echo.Echo, config SSEConfig, registry *usecase.SessionRegistry) {
+	e.GET("/map", func(c echo.Context) error {
+		c.Response().Header().Set("Access-Control-Allow-Origin", config.CORS)
+		session, err := resolveVehicle(c, registry)
+		if err != nil {
+			return err
+		}
+		voxelMap := session.VoxelMap()
+		if voxelMap == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "map is disabled")
+		}
+
+		voxels := voxelMap.Voxels()
+		size := voxelMap.VoxelSize()
+		if param := c.QueryParam("voxel"); param != "" {
+			coarse, err := strconv.ParseFloat(param, 32)
+			if err != nil || !(float32(coarse) > size) || math32.IsInf(float32(coarse), 1) {
+				return echo.NewHTTPError(http.StatusBadRequest, "voxel must be a finite number greater than the map voxel size")
+			}
+			size = float32(coarse)
+			points := make([]usecase.Point, len(voxels))
+			for i, v := range voxels {
+				points[i] = v.Point
+			}
+			points = usecase.VoxelDownsample(points, size)
+			voxels = make([]usecase.MapVoxel, len(points))
+			for i, pt := range points {
+				voxels[i] = usecase.MapVoxel{
+					Key:   [3]int32{int32(math32.Floor(pt.X / size)), int32(math32.Floor(pt.Y / size)), int32(math32.Floor(pt.Z / size))},
+					Point: pt,
+				}
+			}
+		}
+		return c.JSON(http.StatusOK, mapSnapshot{
+			VehicleID: session.ID,
+			VoxelSize: size,
+			Voxels:    mapVoxelRows(voxels),
+		})
+	})
+}
@@ -1,19 +1,253 @@
 package http
 
 import (
+	"dispatcher/internal/usecase"
 	"encoding/json"
 	"github.com/labstack/echo/v4"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type SSEConfig struct {
 	CORS string
 }
 
-func RegisterSSEHandler(e *echo.Echo, config SSEConfig, pointsChan <-chan [][]float32) {
+// cloudEvent — облако точек в том виде, в котором его получает оператор.
+// Каждая точка — массив [x, y, z, ...] с необязательными полями в порядке Fields.
+type cloudEvent struct {
+	VehicleID   string      `json:"vehicle"`
+	Seq         uint32      `json:"seq"`
+	FrameID     string      `json:"frame"`
+	CaptureTime time.Time   `json:"captureTime"`
+	Fields      []string    `json:"fields"`
+	Points      [][]float32 `json:"points"`
+}
+
+func newCloudEvent(frame usecase.Frame) cloudEvent {
+	fields := frame.Fields.Names()
+	width := 3 + len(fields)
+	flat := make([]float32, len(frame.Points)*width)
+	points := make([][]float32, len(frame.Points))
+	for i, pt := range frame.Points {
+		row := flat[i*width : (i+1)*width : (i+1)*width]
+		row[0], row[1], row[2] = pt.X, pt.Y, pt.Z
+		col := 3
+		if frame.Fields&usecase.FieldIntensity != 0 {
+			row[col] = float32(pt.Intensity)
+			col++
+		}
+		if frame.Fields&usecase.FieldRing != 0 {
+			row[col] = float32(pt.Ring)
+			col++
+		}
+		if frame.Fields&usecase.FieldTime != 0 {
+			row[col] = float32(pt.Time)
+			col++
+		}
+		if frame.Fields&usecase.FieldReturn != 0 {
+			row[col] = float32(pt.Return)
+			col++
+		}
+		if frame.Fields&usecase.FieldSensor != 0 {
+			row[col] = float32(pt.Sensor)
+			col++
+		}
+		if frame.Fields&usecase.FieldLabel != 0 {
+			row[col] = float32(pt.Label)
+		}
+		points[i] = row
+	}
+	return cloudEvent{
+		VehicleID:   frame.Header.VehicleID,
+		Seq:         frame.Header.Seq,
+		FrameID:     frame.Header.FrameID,
+		CaptureTime: frame.Header.CaptureTime,
+		Fields:      fields,
+		Points:      points,
+	}
+}
+
+// obstaclesEvent — препятствия кадра; отправляется отдельным событием obstacles
+// сразу после облака точек того же кадра
+type obstaclesEvent struct {
+	VehicleID   string             `json:"vehicle"`
+	Seq         uint32             `json:"seq"`
+	FrameID     string             `json:"frame"`
+	CaptureTime time.Time          `json:"captureTime"`
+	Obstacles   []usecase.Obstacle `json:"obstacles"`
+}
+
+func newObstaclesEvent(frame usecase.Frame) obstaclesEvent {
+	return obstaclesEvent{
+		VehicleID:   frame.Header.VehicleID,
+		Seq:         frame.Header.Seq,
+		FrameID:     frame.Header.FrameID,
+		CaptureTime: frame.Header.CaptureTime,
+		Obstacles:   frame.Obstacles,
+	}
+}
+
+// tracksEvent — подтверждённые треки препятствий; отправляется событием tracks
+// после облака точек того же кадра
+type tracksEvent struct {
+	VehicleID   string          `json:"vehicle"`
+	Seq         uint32          `json:"seq"`
+	FrameID     string          `json:"frame"`
+	CaptureTime time.Time       `json:"captureTime"`
+	Tracks      []usecase.Track `json:"tracks"`
+}
+
+func newTracksEvent(frame usecase.Frame) tracksEvent {
+	return tracksEvent{
+		VehicleID:   frame.Header.VehicleID,
+		Seq:         frame.Header.Seq,
+		FrameID:     frame.Header.FrameID,
+		CaptureTime: frame.Header.CaptureTime,
+		Tracks:      frame.Tracks,
+	}
+}
+
+// gridEvent — сетка высот кадра; отправляется событием grid.
+// Передаются только занятые ячейки: [столбец, строка, число точек, minZ, maxZ].
+type gridEvent struct {
+	VehicleID   string       `json:"vehicle"`
+	Seq         uint32       `json:"seq"`
+	FrameID     string       `json:"frame"`
+	CaptureTime time.Time    `json:"captureTime"`
+	CellSize    float32      `json:"cellSize"`
+	OriginX     float32      `json:"originX"`
+	OriginY     float32      `json:"originY"`
+	Width       int          `json:"width"`
+	Height      int          `json:"height"`
+	Cells       [][5]float32 `json:"cells"`
+}
+
+func newGridEvent(frame usecase.Frame) gridEvent {
+	g := frame.Grid
+	cells := make([][5]float32, 0)
+	for i, cell := range g.Cells {
+		if cell.Hits > 0 {
+			cells = append(cells, [5]float32{float32(i % g.Width), float32(i / g.Width), float32(cell.Hits), cell.MinZ, cell.MaxZ})
+		}
+	}
+	return gridEvent{
+		VehicleID:   frame.Header.VehicleID,
+		Seq:         frame.Header.Seq,
+		FrameID:     frame.Header.FrameID,
+		CaptureTime: frame.Header.CaptureTime,
+		CellSize:    g.CellSize,
+		OriginX:     g.OriginX,
+		OriginY:     g.OriginY,
+		Width:       g.Width,
+		Height:      g.Height,
+		Cells:       cells,
+	}
+}
+
+// poseEvent — положение ТС по одометрии; отправляется событием pose
+type poseEvent struct {
+	VehicleID   string       `json:"vehicle"`
+	Seq         uint32       `json:"seq"`
+	FrameID     string       `json:"frame"`
+	CaptureTime time.Time    `json:"captureTime"`
+	Pose        usecase.Pose `json:"pose"`
+}
+
+// mapEvent — изменения карты окружения после кадра; отправляется событием map.
+// Воксели — [i, j, k, x, y, z, интенсивность], удалённые — [i, j, k].
+// Updated — уже переданные воксели с заметно сместившимся средним.
+type mapEvent struct {
+	VehicleID   string       `json:"vehicle"`
+	Seq         uint32       `json:"seq"`
+	CaptureTime time.Time    `json:"captureTime"`
+	Added       [][7]float32 `json:"added"`
+	Updated     [][7]float32 `json:"updated"`
+	Removed     [][3]int32   `json:"removed"`
+}
+
+func newMapEvent(frame usecase.Frame) mapEvent {
+	removed := frame.MapUpdate.Removed
+	if removed == nil {
+		removed = [][3]int32{}
+	}
+	return mapEvent{
+		VehicleID:   frame.Header.VehicleID,
+		Seq:         frame.Header.Seq,
+		CaptureTime: frame.Header.CaptureTime,
+		Added:       mapVoxelRows(frame.MapUpdate.Added),
+		Updated:     mapVoxelRows(frame.MapUpdate.Updated),
+		Removed:     removed,
+	}
+}
+
+func mapVoxelRows(voxels []usecase.MapVoxel) [][7]float32 {
+	rows := make([][7]float32, len(voxels))
+	for i, v := range voxels {
+		rows[i] = [7]float32{
+			float32(v.Key[0]), float32(v.Key[1]), float32(v.Key[2]),
+			v.Point.X, v.Point.Y, v.Point.Z, float32(v.Point.Intensity),
+		}
+	}
+	return rows
+}
+
+func newPoseEvent(frame usecase.Frame) poseEvent {
+	return poseEvent{
+		VehicleID:   frame.Header.VehicleID,
+		Seq:         frame.Header.Seq,
+		FrameID:     frame.Header.FrameID,
+		CaptureTime: frame.Header.CaptureTime,
+		Pose:        *frame.Pose,
+	}
+}
+
+// coarsenFrame заменяет облако кадра центрами узлов октодерева на lod уровней выше листьев,
+// прошедшими фильтры сервера
+func coarsenFrame(frame usecase.Frame, lod int) usecase.Frame {
+	points, err := frame.Levels.Points(lod)
+	if err != nil {
+		log.Printf("SSE: ошибка огрубления кадра %s/%d: %v", frame.Header.VehicleID, frame.Header.Seq, err)
+		return frame
+	}
+	frame.Points = points
+	return frame
+}
+
+// writeEvent отправляет событие SSE с данными кадра в JSON; пустое имя — событие по умолчанию (message)
+func writeEvent(w io.Writer, name string, frame usecase.Frame, event any) {
+	jsonData, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("SSE: ошибка сериализации события %q кадра %s/%d: %v", name, frame.Header.VehicleID, frame.Header.Seq, err)
+		return
+	}
+	if name != "" {
+		_, _ = io.WriteString(w, "event: "+name+"\n")
+	}
+	_, _ = io.WriteString(w, "data: ")
+	_, _ = w.Write(jsonData)
+	_, _ = io.WriteString(w, "\n\n")
+}
+
+func RegisterSSEHandler(e *echo.Echo, config SSEConfig, registry *usecase.SessionRegistry) {
 	e.GET("/sse", func(c echo.Context) error {
-		log.Printf("SSE: оператор %s подключился", c.Request().RemoteAddr)
+		session, err := resolveVehicle(c, registry)
+		if err != nil {
+			return err
+		}
+		// Детализация облака: число уровней октодерева над листьями; действует для кадров,
+		// сжатых кодеком octree
+		lod := 0
+		if param := c.QueryParam("lod"); param != "" {
+			lod, err = strconv.Atoi(param)
+			if err != nil || lod < 0 {
+				return echo.NewHTTPError(http.StatusBadRequest, "lod must be a non-negative integer")
+			}
+		}
+		hub := session.Hub
+		log.Printf("SSE: оператор %s подключился к ТС %s", c.Request().RemoteAddr, session.ID)
 		c.Response().Header().Set("Content-Type", "text/event-stream")
 		c.Response().Header().Set("Cache-Control", "no-cache")
 		c.Response().Header().Set("Connection", "keep-alive")
@@ -22,23 +256,79 @@ func RegisterSSEHandler(e *echo.Echo, config SSEConfig, pointsChan <-chan [][]fl
 		if !ok {
 			return echo.NewHTTPError(http.StatusInternalServerError, "Streaming unsupported")
 		}
+
+		// Каждый оператор получает собственную очередь кадров
+		sub := hub.Subscribe(c.Request().RemoteAddr)
+		defer hub.Unsubscribe(sub)
+
 		for {
 			select {
-			case points := <-pointsChan:
-				log.Printf("SSE: отправка облака точек оператору %s, количество точек: %d", c.Request().RemoteAddr, len(points))
-				jsonData, err := json.Marshal(points)
-				if err != nil {
-					_, _ = c.Response().Write([]byte("data: []\n\n"))
-				} else {
-					_, _ = c.Response().Write([]byte("data: "))
-					_, _ = c.Response().Write(jsonData)
-					_, _ = c.Response().Write([]byte("\n\n"))
+			case frame, ok := <-sub.C():
+				if !ok {
+					log.Printf("SSE: поток кадров закрыт, отключаем оператора %s", c.Request().RemoteAddr)
+					return nil
+				}
+				if lod > 0 && frame.Levels != nil {
+					frame = coarsenFrame(frame, lod)
+				}
+				if frame.Points != nil {
+					log.Printf("SSE: отправка облака точек оператору %s, количество точек: %d", c.Request().RemoteAddr, len(frame.Points))
+					writeEvent(c.Response(), "", frame, newCloudEvent(frame))
+				}
+				if frame.Grid != nil {
+					writeEvent(c.Response(), "grid", frame, newGridEvent(frame))
+				}
+				if frame.Pose != nil {
+					writeEvent(c.Response(), "pose", frame, newPoseEvent(frame))
+				}
+				if frame.MapUpdate != nil {
+					writeEvent(c.Response(), "map", frame, newMapEvent(frame))
+				}
+				if frame.Obstacles != nil {
+					writeEvent(c.Response(), "obstacles", frame, newObstaclesEvent(frame))
+				}
+				if frame.Tracks != nil {
+					writeEvent(c.Response(), "tracks", frame, newTracksEvent(frame))
 				}
 				flusher.Flush()
 			case <-c.Request().Context().Done():
-				log.Printf("SSE: оператор %s отключился", c.Request().RemoteAddr)
+				stats := sub.Stats()
+				log.Printf("SSE: оператор %s отключился (отправлено: %d, пропущено: %d)",
+					c.Request().RemoteAddr, stats.Sent, stats.Dropped)
 				return nil
 			}
 		}
 	})
+
+	// Статистика доставки кадров по каждому оператору выбранного ТС
+	e.GET("/sse/stats", func(c echo.Context) error {
+		c.Response().Header().Set("Access-Control-Allow-Origin", config.CORS)
+		session, err := resolveVehicle(c, registry)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, session.Hub.Stats())
+	})
+}
+
+// resolveVehicle находит сессию ТС по параметру запроса vehicle.
+// Если параметр не задан и на сервере ровно одно ТС, используется оно.
+func resolveVehicle(c echo.Context, registry *usecase.SessionRegistry) (*usecase.VehicleSession, error) {
+	vehicleID := c.QueryParam("vehicle")
+	if vehicleID == "" {
+		sessions := registry.Sessions()
+		switch len(sessions) {
+		case 0:
+			return nil, echo.NewHTTPError(http.StatusNotFound, "no vehicles connected")
+		case 1:
+			return sessions[0], nil
+		default:
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "vehicle parameter is required")
+		}
+	}
+	session, ok := registry.Get(vehicleID)
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "unknown vehicle")
+	}
+	return session, nil
 }
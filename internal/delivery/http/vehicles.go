@@ -0,0 +1,15 @@
+package http
+
+import (
+	"dispatcher/internal/usecase"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// RegisterVehiclesHandler отдаёт список сессий ТС с их статистикой
+func RegisterVehiclesHandler(e *echo.Echo, config SSEConfig, registry *usecase.SessionRegistry) {
+	e.GET("/vehicles", func(c echo.Context) error {
+		c.Response().Header().Set("Access-Control-Allow-Origin", config.CORS)
+		return c.JSON(http.StatusOK, registry.List())
+	})
+}
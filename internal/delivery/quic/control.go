@@ -0,0 +1,53 @@
+package quic
+
+import (
+	"context"
+	"github.com/quic-go/quic-go"
+	"io"
+	"log"
+)
+
+const controlMagic = "DSPC" // сигнатура потока управления от сервера к ТС
+
+// ControlMessage — тип сообщения управления
+type ControlMessage uint8
+
+const (
+	// ControlKeyframe — сервер не может восстановить кадр и просит передать следующий опорным
+	ControlKeyframe ControlMessage = 1
+)
+
+// SendControl открывает однонаправленный поток от сервера к ТС и передаёт в нём сообщение управления
+func SendControl(ctx context.Context, conn *quic.Conn, msg ControlMessage) error {
+	stream, err := conn.OpenUniStreamSync(ctx)
+	if err != nil {
+		return err
+	}
+	buf := append([]byte(controlMagic), byte(msg))
+	if _, err := stream.Write(buf); err != nil {
+		_ = stream.Close()
+		return err
+	}
+	return stream.Close()
+}
+
+// AcceptControl принимает потоки управления от сервера и передаёт сообщения в handle,
+// пока соединение не закроется
+func AcceptControl(ctx context.Context, conn *quic.Conn, handle func(ControlMessage)) error {
+	for {
+		stream, err := conn.AcceptUniStream(ctx)
+		if err != nil {
+			return err
+		}
+		header := make([]byte, len(controlMagic)+1)
+		if _, err := io.ReadFull(stream, header); err != nil {
+			log.Printf("QUIC: ошибка чтения потока управления: %v", err)
+			continue
+		}
+		if string(header[:len(controlMagic)]) != controlMagic {
+			log.Printf("QUIC: неверная сигнатура потока управления")
+			continue
+		}
+		handle(ControlMessage(header[len(controlMagic)]))
+	}
+}
@@ -0,0 +1,73 @@
+package quic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/quic-go/quic-go"
+	"io"
+	"time"
+)
+
+const (
+	handshakeMagic   = "DSPH" // сигнатура потока рукопожатия
+	handshakeTimeout = 5 * time.Second
+	maxVehicleIDLen  = 255
+)
+
+const (
+	// HandshakeErrorCode — код закрытия соединения при неудачном рукопожатии
+	HandshakeErrorCode quic.ApplicationErrorCode = 0x100
+	// SessionErrorCode — код закрытия соединения, если сервер не смог создать сессию ТС
+	SessionErrorCode quic.ApplicationErrorCode = 0x101
+)
+
+var ErrBadHandshake = errors.New("некорректное рукопожатие")
+
+// SendHandshake открывает первый однонаправленный поток соединения и передаёт в нём
+// идентификатор ТС. Сервер ожидает этот поток до любых кадров.
+func SendHandshake(ctx context.Context, conn *quic.Conn, vehicleID string) error {
+	if vehicleID == "" || len(vehicleID) > maxVehicleIDLen {
+		return fmt.Errorf("%w: недопустимая длина идентификатора ТС (%d)", ErrBadHandshake, len(vehicleID))
+	}
+	stream, err := conn.OpenUniStreamSync(ctx)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 0, len(handshakeMagic)+1+len(vehicleID))
+	buf = append(buf, handshakeMagic...)
+	buf = append(buf, byte(len(vehicleID)))
+	buf = append(buf, vehicleID...)
+	if _, err := stream.Write(buf); err != nil {
+		_ = stream.Close()
+		return err
+	}
+	return stream.Close()
+}
+
+// AcceptHandshake принимает поток рукопожатия и возвращает идентификатор ТС
+func AcceptHandshake(ctx context.Context, conn *quic.Conn) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+	defer cancel()
+	stream, err := conn.AcceptUniStream(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	header := make([]byte, len(handshakeMagic)+1)
+	if _, err := io.ReadFull(stream, header); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrBadHandshake, err)
+	}
+	if string(header[:len(handshakeMagic)]) != handshakeMagic {
+		return "", fmt.Errorf("%w: неверная сигнатура", ErrBadHandshake)
+	}
+	idLen := int(header[len(handshakeMagic)])
+	if idLen == 0 {
+		return "", fmt.Errorf("%w: пустой идентификатор ТС", ErrBadHandshake)
+	}
+	id := make([]byte, idLen)
+	if _, err := io.ReadFull(stream, id); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrBadHandshake, err)
+	}
+	return string(id), nil
+}
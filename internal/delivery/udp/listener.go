@@ -11,7 +11,8 @@ const (
 )
 
 type Packet struct {
-	RawData []byte
+	RawData    []byte
+	ReceivedAt time.Time // время приёма пакета по часам хоста
 }
 
 func StartUDPListener(ip string, port int, out chan<- Packet) error {
@@ -59,7 +60,7 @@ func StartUDPListener(ip string, port int, out chan<- Packet) error {
 					packet := make([]byte, packetSize)
 					copy(packet, buf[:packetSize])
 					select {
-					case out <- Packet{RawData: packet}:
+					case out <- Packet{RawData: packet, ReceivedAt: time.Now()}:
 						// успешно отправлено
 					default:
 						log.Printf("UDP: [WARN] Канал UDP перегружен, пакет отброшен\n")
@@ -0,0 +1,71 @@
+package udp
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"time"
+)
+
+const (
+	motionPacketSize = 1024 // наибольший размер сообщения о движении
+)
+
+// MotionMessage — скорость ТС в его системе координат от ИНС или одометрии шасси
+type MotionMessage struct {
+	Time    time.Time  // время приёма по часам хоста
+	Linear  [3]float64 // м/с
+	Angular [3]float64 // рад/с
+}
+
+// motionJSON — формат сообщения: {"linear": [vx, vy, vz], "angular": [wx, wy, wz]}.
+// Поле time, если передано, не используется: часы источника могут расходиться с часами сенсоров
+// и хоста, а кадры сопоставляются с измерениями по часам хоста.
+type motionJSON struct {
+	Linear  *[3]float64 `json:"linear"`
+	Angular *[3]float64 `json:"angular"`
+}
+
+// StartMotionListener принимает сообщения о скорости ТС в формате JSON, по одному на датаграмму
+func StartMotionListener(ip string, port int, out chan<- MotionMessage) error {
+	go func() {
+		for {
+			addr := net.UDPAddr{
+				IP:   net.ParseIP(ip),
+				Port: port,
+			}
+			conn, err := net.ListenUDP("udp", &addr)
+			if err != nil {
+				log.Printf("UDP: Ошибка открытия сокета движения: %v\n", err)
+				time.Sleep(1 * time.Second)
+				continue
+			}
+			log.Printf("UDP: приём движения ТС на %s:%d\n", ip, port)
+			buf := make([]byte, motionPacketSize)
+
+			for {
+				n, _, err := conn.ReadFromUDP(buf)
+				if err != nil {
+					log.Printf("UDP: Ошибка чтения движения: %v\n", err)
+					_ = conn.Close()
+					break // Перезапуск сервера
+				}
+				receivedAt := time.Now()
+
+				var msg motionJSON
+				if err := json.Unmarshal(buf[:n], &msg); err != nil || msg.Linear == nil || msg.Angular == nil {
+					log.Printf("UDP: неверное сообщение о движении (%d байт): %v\n", n, err)
+					continue
+				}
+				motion := MotionMessage{Time: receivedAt, Linear: *msg.Linear, Angular: *msg.Angular}
+				select {
+				case out <- motion:
+				default:
+					log.Printf("UDP: [WARN] Канал движения перегружен, сообщение отброшено\n")
+				}
+			}
+			time.Sleep(1 * time.Second) // Пауза перед повторным запуском
+		}
+	}()
+	return nil
+}
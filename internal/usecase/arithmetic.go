@@ -0,0 +1,184 @@
+package usecase
+
+// Адаптивное арифметическое кодирование байтов (Witten, Neal, Cleary, 1987) с 32-битными
+// границами интервала. Частоты символов подстраиваются под уже закодированные байты,
+// поэтому таблица частот не передаётся.
+const (
+	arithmeticTop       = 1<<32 - 1
+	arithmeticHalf      = 1 << 31
+	arithmeticFirstQtr  = 1 << 30
+	arithmeticThirdQtr  = 3 << 30
+	arithmeticIncrement = 32      // прибавка к частоте закодированного символа
+	arithmeticMaxTotal  = 1 << 16 // при превышении суммы частоты делятся пополам
+	// arithmeticMaxOverrun — сколько байт за концом потока может прочитать декодер целого потока:
+	// он опережает кодер на 32 бита. Дальше читают только повреждённые потоки, которые иначе
+	// порождали бы символы из нулей без конца
+	arithmeticMaxOverrun = 8
+)
+
+// byteModel — адаптивная модель частот 256 символов
+type byteModel struct {
+	freq  [256]uint32
+	total uint32
+}
+
+func newByteModel() *byteModel {
+	m := &byteModel{total: 256}
+	for i := range m.freq {
+		m.freq[i] = 1
+	}
+	return m
+}
+
+// interval возвращает накопленные частоты [low, high) символа
+func (m *byteModel) interval(sym byte) (uint32, uint32) {
+	var low uint32
+	for _, f := range m.freq[:sym] {
+		low += f
+	}
+	return low, low + m.freq[sym]
+}
+
+func (m *byteModel) update(sym byte) {
+	m.freq[sym] += arithmeticIncrement
+	m.total += arithmeticIncrement
+	if m.total > arithmeticMaxTotal {
+		m.total = 0
+		for i, f := range m.freq {
+			m.freq[i] = max(1, f/2)
+			m.total += m.freq[i]
+		}
+	}
+}
+
+type arithmeticEncoder struct {
+	model     *byteModel
+	low, high uint64
+	pending   int
+	out       []byte
+	bits      uint8 // число битов в последнем байте out
+}
+
+func newArithmeticEncoder() *arithmeticEncoder {
+	return &arithmeticEncoder{model: newByteModel(), high: arithmeticTop}
+}
+
+func (e *arithmeticEncoder) writeBit(bit uint8) {
+	if e.bits == 0 {
+		e.out = append(e.out, 0)
+	}
+	e.out[len(e.out)-1] |= bit << (7 - e.bits)
+	e.bits = (e.bits + 1) % 8
+}
+
+// emit выводит бит и отложенные противоположные биты
+func (e *arithmeticEncoder) emit(bit uint8) {
+	e.writeBit(bit)
+	for ; e.pending > 0; e.pending-- {
+		e.writeBit(bit ^ 1)
+	}
+}
+
+func (e *arithmeticEncoder) Encode(sym byte) {
+	cumLow, cumHigh := e.model.interval(sym)
+	total := uint64(e.model.total)
+	rng := e.high - e.low + 1
+	e.high = e.low + rng*uint64(cumHigh)/total - 1
+	e.low = e.low + rng*uint64(cumLow)/total
+	for {
+		switch {
+		case e.high < arithmeticHalf:
+			e.emit(0)
+		case e.low >= arithmeticHalf:
+			e.emit(1)
+			e.low -= arithmeticHalf
+			e.high -= arithmeticHalf
+		case e.low >= arithmeticFirstQtr && e.high < arithmeticThirdQtr:
+			e.pending++
+			e.low -= arithmeticFirstQtr
+			e.high -= arithmeticFirstQtr
+		default:
+			e.model.update(sym)
+			return
+		}
+		e.low = 2 * e.low
+		e.high = 2*e.high + 1
+	}
+}
+
+// Finish дописывает биты, однозначно определяющие последний интервал, и возвращает поток
+func (e *arithmeticEncoder) Finish() []byte {
+	e.pending++
+	if e.low < arithmeticFirstQtr {
+		e.emit(0)
+	} else {
+		e.emit(1)
+	}
+	return e.out
+}
+
+type arithmeticDecoder struct {
+	model            *byteModel
+	low, high, value uint64
+	in               []byte
+	pos              int // номер следующего бита; за концом потока читаются нули
+}
+
+func newArithmeticDecoder(in []byte) *arithmeticDecoder {
+	d := &arithmeticDecoder{model: newByteModel(), high: arithmeticTop, in: in}
+	for range 32 {
+		d.value = 2*d.value + d.readBit()
+	}
+	return d
+}
+
+func (d *arithmeticDecoder) readBit() uint64 {
+	i := d.pos
+	d.pos++
+	if i/8 >= len(d.in) {
+		return 0
+	}
+	return uint64(d.in[i/8]>>(7-i%8)) & 1
+}
+
+// Overrun сообщает, что декодер прочитал за концом потока больше arithmeticMaxOverrun байт
+func (d *arithmeticDecoder) Overrun() bool {
+	return d.pos > 8*(len(d.in)+arithmeticMaxOverrun)
+}
+
+func (d *arithmeticDecoder) Decode() byte {
+	total := uint64(d.model.total)
+	rng := d.high - d.low + 1
+	count := ((d.value-d.low+1)*total - 1) / rng
+	var sym byte
+	var cumLow uint32
+	for s, f := range d.model.freq {
+		if uint64(cumLow+f) > count {
+			sym = byte(s)
+			break
+		}
+		cumLow += f
+	}
+	cumHigh := cumLow + d.model.freq[sym]
+	d.high = d.low + rng*uint64(cumHigh)/total - 1
+	d.low = d.low + rng*uint64(cumLow)/total
+	for {
+		switch {
+		case d.high < arithmeticHalf:
+		case d.low >= arithmeticHalf:
+			d.low -= arithmeticHalf
+			d.high -= arithmeticHalf
+			d.value -= arithmeticHalf
+		case d.low >= arithmeticFirstQtr && d.high < arithmeticThirdQtr:
+			d.low -= arithmeticFirstQtr
+			d.high -= arithmeticFirstQtr
+			d.value -= arithmeticFirstQtr
+		default:
+			d.model.update(sym)
+			return sym
+		}
+		d.low = 2 * d.low
+		d.high = 2*d.high + 1
+		d.value = 2*d.value + d.readBit()
+	}
+}
@@ -0,0 +1,254 @@
+package usecase
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// SegmentationMode — способ разрезания потока измерений на кадры
+type SegmentationMode string
+
+const (
+	// SegmentByAzimuth режет кадр, когда азимут выстрелов пересекает CutAzimuth
+	SegmentByAzimuth SegmentationMode = "azimuth"
+	// SegmentByTime режет кадр на границах интервалов Period по времени сенсора
+	SegmentByTime SegmentationMode = "time"
+	// SegmentByPackets режет кадр каждые Packets пакетов
+	SegmentByPackets SegmentationMode = "packets"
+)
+
+// maxAzimuthBacktrack — наибольший откат азимута между соседними измерениями, градусы,
+// который считается разбросом внутри кадра, а не поворотом почти на полный оборот
+const maxAzimuthBacktrack = 20
+
+// FrameAssemblerConfig задаёт параметры сегментации
+type FrameAssemblerConfig struct {
+	Mode        SegmentationMode
+	CutAzimuth  float32       // градусы, для SegmentByAzimuth
+	Period      time.Duration // для SegmentByTime
+	Packets     int           // для SegmentByPackets
+	MinCoverage float32       // минимальный охват по азимуту, градусы; кадры с меньшим считаются неполными
+}
+
+// DefaultFrameAssemblerConfig — разрез по переходу азимута через 0°, как у одного оборота сенсора
+func DefaultFrameAssemblerConfig() FrameAssemblerConfig {
+	return FrameAssemblerConfig{
+		Mode:        SegmentByAzimuth,
+		Period:      100 * time.Millisecond,
+		Packets:     76,
+		MinCoverage: 350,
+	}
+}
+
+// AssembledFrame — собранный кадр до сериализации
+type AssembledFrame struct {
+	CaptureTime time.Time // время первого измерения кадра
+	ReceivedAt  time.Time // время приёма хостом пакета, с которого начался кадр; нулевое, если неизвестно
+	Points      []Point
+	Packets     int
+	Coverage    float32 // охват по азимуту, градусы
+	Complete    bool    // охват не меньше MinCoverage
+}
+
+// FrameAssemblerStats — счётчики собранных кадров
+type FrameAssemblerStats struct {
+	Frames     uint64 // выданные кадры
+	Incomplete uint64 // из них неполные по охвату азимута
+	Discarded  uint64 // отброшенные начальные куски до первого разреза
+}
+
+// FrameAssembler накапливает измерения и режет их на кадры согласно конфигурации
+type FrameAssembler struct {
+	cfg FrameAssemblerConfig
+
+	points      []Point
+	captureTime time.Time
+	boundary    time.Time // конец текущего интервала в режиме SegmentByTime
+	packets     int
+	coverage    [6]uint64 // 360 бит по одному на градус азимута
+	prevAzimuth float32
+	hasPrev     bool
+	toCut       float32 // сколько градусов вперёд осталось до разреза в режиме SegmentByAzimuth
+	started     bool    // был ли уже первый разрез; до него кадр неполный и отбрасывается
+
+	frames     atomic.Uint64
+	incomplete atomic.Uint64
+	discarded  atomic.Uint64
+}
+
+func NewFrameAssembler(cfg FrameAssemblerConfig) (*FrameAssembler, error) {
+	switch cfg.Mode {
+	case SegmentByAzimuth:
+		if cfg.CutAzimuth < 0 || cfg.CutAzimuth >= 360 {
+			return nil, fmt.Errorf("азимут разреза должен быть в диапазоне [0, 360): %v", cfg.CutAzimuth)
+		}
+	case SegmentByTime:
+		if cfg.Period <= 0 {
+			return nil, fmt.Errorf("период сегментации должен быть положительным: %v", cfg.Period)
+		}
+	case SegmentByPackets:
+		if cfg.Packets <= 0 {
+			return nil, fmt.Errorf("число пакетов в кадре должно быть положительным: %d", cfg.Packets)
+		}
+	default:
+		return nil, fmt.Errorf("неизвестный режим сегментации: %q", cfg.Mode)
+	}
+	return &FrameAssembler{cfg: cfg, points: make([]Point, 0, 40000)}, nil
+}
+
+// AddPacket добавляет измерения пакета и возвращает кадры, завершённые этим пакетом.
+// keep решает, попадёт ли измерение в кадр; отброшенные измерения всё равно
+// участвуют в сегментации и учёте охвата.
+func (a *FrameAssembler) AddPacket(packetTime time.Time, pkt DecodedPacket, keep func(*LaserReturn) bool) []AssembledFrame {
+	var done []AssembledFrame
+	for i := range pkt.Returns {
+		r := &pkt.Returns[i]
+		firingTime := packetTime.Add(time.Duration(r.Offset * float32(time.Microsecond)))
+		if a.shouldCut(r.Azimuth, firingTime) {
+			if frame, ok := a.cut(); ok {
+				done = append(done, frame)
+			}
+		}
+		if a.captureTime.IsZero() {
+			a.captureTime = firingTime
+			if a.cfg.Mode == SegmentByTime {
+				a.boundary = firingTime.Truncate(a.cfg.Period).Add(a.cfg.Period)
+			}
+		}
+		a.markCoverage(r.Azimuth)
+		if keep != nil && !keep(r) {
+			continue
+		}
+		// Время может откатиться назад (перезапуск сенсора, смена часа); такие измерения
+		// получают время начала кадра, а не переполненное беззнаковое смещение
+		var pointTime uint32
+		if offset := firingTime.Sub(a.captureTime); offset > 0 {
+			pointTime = uint32(min(offset.Microseconds(), math.MaxUint32))
+		}
+		a.points = append(a.points, Point{
+			X:         r.X,
+			Y:         r.Y,
+			Z:         r.Z,
+			Intensity: r.Intensity,
+			Ring:      r.Ring,
+			Time:      pointTime,
+			Return:    r.Return,
+			Distance:  r.Distance,
+			Azimuth:   r.Azimuth,
+		})
+	}
+	a.packets++
+	if a.cfg.Mode == SegmentByPackets && a.packets >= a.cfg.Packets {
+		// В этом режиме первый кадр не отбрасывается: граница не связана с положением сенсора
+		a.started = true
+		if frame, ok := a.cut(); ok {
+			done = append(done, frame)
+		}
+	}
+	return done
+}
+
+// shouldCut проверяет, начинает ли измерение новый кадр
+func (a *FrameAssembler) shouldCut(azimuth float32, firingTime time.Time) bool {
+	switch a.cfg.Mode {
+	case SegmentByAzimuth:
+		if !a.hasPrev {
+			a.prevAzimuth, a.hasPrev = azimuth, true
+			a.toCut = wrapDegrees(a.cfg.CutAzimuth - azimuth)
+			if a.toCut == 0 {
+				a.toCut = 360
+			}
+			return false
+		}
+		// Сенсор вращается только вперёд, поэтому любой шаг, кроме небольшого отката,
+		// считается движением вперёд — в том числе скачок больше 180° при потере пакетов.
+		// Откаты внутри кадра (парные блоки двойного режима, поправки лазеров) уменьшают
+		// пройденный путь и не могут повторно пересечь разрез.
+		step := wrapDegrees(azimuth - a.prevAzimuth)
+		if step > 360-maxAzimuthBacktrack {
+			step -= 360
+		}
+		a.prevAzimuth = azimuth
+		a.toCut -= step
+		if a.toCut > 0 {
+			return false
+		}
+		a.toCut += 360
+		return true
+	case SegmentByTime:
+		if a.captureTime.IsZero() {
+			return false
+		}
+		// Скачок времени назад (перезапуск сенсора, смена часа) тоже начинает новый кадр
+		return !firingTime.Before(a.boundary) || firingTime.Before(a.captureTime)
+	default:
+		return false
+	}
+}
+
+// cut завершает текущий кадр. Кадр до первого разреза отбрасывается как заведомо неполный.
+func (a *FrameAssembler) cut() (AssembledFrame, bool) {
+	frame := AssembledFrame{
+		CaptureTime: a.captureTime,
+		Points:      a.points,
+		Packets:     a.packets,
+		Coverage:    a.coveredDegrees(),
+	}
+	frame.Complete = frame.Coverage >= a.cfg.MinCoverage
+	started := a.started
+
+	a.points = make([]Point, 0, cap(a.points))
+	a.captureTime = time.Time{}
+	a.packets = 0
+	a.coverage = [6]uint64{}
+	a.started = true
+
+	if !started {
+		a.discarded.Add(1)
+		return frame, false
+	}
+	if len(frame.Points) == 0 {
+		return frame, false
+	}
+	a.frames.Add(1)
+	if !frame.Complete {
+		a.incomplete.Add(1)
+	}
+	return frame, true
+}
+
+func (a *FrameAssembler) markCoverage(azimuth float32) {
+	bin := int(azimuth) % 360
+	a.coverage[bin/64] |= 1 << (bin % 64)
+}
+
+func (a *FrameAssembler) coveredDegrees() float32 {
+	n := 0
+	for _, word := range a.coverage {
+		n += bits.OnesCount64(word)
+	}
+	return float32(n)
+}
+
+// Stats возвращает счётчики кадров; безопасно вызывать из другой горутины
+func (a *FrameAssembler) Stats() FrameAssemblerStats {
+	return FrameAssemblerStats{
+		Frames:     a.frames.Load(),
+		Incomplete: a.incomplete.Load(),
+		Discarded:  a.discarded.Load(),
+	}
+}
+
+// wrapDegrees приводит угол к диапазону [0, 360)
+func wrapDegrees(angle float32) float32 {
+	for angle < 0 {
+		angle += 360
+	}
+	for angle >= 360 {
+		angle -= 360
+	}
+	return angle
+}
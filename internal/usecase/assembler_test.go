@@ -0,0 +1,178 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+)
+
+// sweepPacket — пакет с измерениями на указанных азимутах, по 10 мкс между выстрелами
+func sweepPacket(azimuths ...float32) DecodedPacket {
+	pkt := DecodedPacket{Returns: make([]LaserReturn, len(azimuths))}
+	for i, azimuth := range azimuths {
+		pkt.Returns[i] = LaserReturn{X: 1, Azimuth: azimuth, Offset: float32(i) * 10}
+	}
+	return pkt
+}
+
+// feed подаёт азимуты пакетами по 12 измерений и возвращает выданные кадры
+func feed(a *FrameAssembler, start time.Time, azimuths []float32) []AssembledFrame {
+	var frames []AssembledFrame
+	for i := 0; i < len(azimuths); i += 12 {
+		end := min(i+12, len(azimuths))
+		packetTime := start.Add(time.Duration(i) * 10 * time.Microsecond)
+		frames = append(frames, a.AddPacket(packetTime, sweepPacket(azimuths[i:end]...), nil)...)
+	}
+	return frames
+}
+
+// revolutions строит азимуты от start с шагом step на заданное число градусов
+func revolutions(start, step, degrees float32) []float32 {
+	var azimuths []float32
+	for passed := float32(0); passed < degrees; passed += step {
+		azimuths = append(azimuths, wrapDegrees(start+passed))
+	}
+	return azimuths
+}
+
+func TestFrameAssemblerCutsAtAzimuth(t *testing.T) {
+	cfg := DefaultFrameAssemblerConfig()
+	cfg.CutAzimuth = 180
+	a, err := NewFrameAssembler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := feed(a, time.Unix(100, 0), revolutions(90, 1, 3*360))
+	// Первый кусок от 90° до 180° отбрасывается, затем два полных оборота
+	if len(frames) != 2 {
+		t.Fatalf("кадров %d, ожидалось 2", len(frames))
+	}
+	for _, frame := range frames {
+		if len(frame.Points) != 360 || !frame.Complete {
+			t.Errorf("кадр: точек %d, охват %v", len(frame.Points), frame.Coverage)
+		}
+		if first := frame.Points[0].Azimuth; first != 180 {
+			t.Errorf("кадр начинается с азимута %v", first)
+		}
+	}
+	if stats := a.Stats(); stats.Frames != 2 || stats.Discarded != 1 || stats.Incomplete != 0 {
+		t.Errorf("счётчики %+v", stats)
+	}
+}
+
+func TestFrameAssemblerForwardJumpOverCut(t *testing.T) {
+	a, err := NewFrameAssembler(DefaultFrameAssemblerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Полный оборот, затем потеря пакетов: азимут прыгает вперёд на 200° через разрез,
+	// после чего идут ещё два оборота. Каждое пересечение 0° должно резать кадр.
+	azimuths := revolutions(0, 1, 360+300)
+	azimuths = append(azimuths, revolutions(140, 1, 2*360)...)
+	frames := feed(a, time.Unix(100, 0), azimuths)
+	if len(frames) != 3 {
+		t.Fatalf("кадров %d, ожидалось 3", len(frames))
+	}
+	// Кадр с потерей обрывается на 300°, следующий начинается со 140° и тоже неполный
+	for i, want := range []float32{300, 220, 360} {
+		if frames[i].Coverage != want || frames[i].Complete != (want == 360) {
+			t.Errorf("кадр %d: охват %v, полный %v", i, frames[i].Coverage, frames[i].Complete)
+		}
+	}
+	if stats := a.Stats(); stats.Incomplete != 2 {
+		t.Errorf("неполных кадров %d", stats.Incomplete)
+	}
+}
+
+func TestFrameAssemblerIgnoresBacktrackAtCut(t *testing.T) {
+	a, err := NewFrameAssembler(DefaultFrameAssemblerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Поправки лазеров VLP-32C дают разброс ±4,2° внутри блока: у разреза азимут
+	// несколько раз переходит через 0° туда и обратно, но кадр режется один раз
+	var azimuths []float32
+	for block := float32(0); block < 3*360; block += 2 {
+		base := wrapDegrees(block + 180)
+		azimuths = append(azimuths, wrapDegrees(base+4.2), wrapDegrees(base-4.2), wrapDegrees(base+1.4), wrapDegrees(base-1.4))
+	}
+	frames := feed(a, time.Unix(100, 0), azimuths)
+	if len(frames) != 2 {
+		t.Fatalf("кадров %d, ожидалось 2", len(frames))
+	}
+	for _, frame := range frames {
+		if len(frame.Points) < 700 {
+			t.Errorf("кадр из %d точек", len(frame.Points))
+		}
+	}
+}
+
+func TestFrameAssemblerClampsPointTime(t *testing.T) {
+	cfg := DefaultFrameAssemblerConfig()
+	cfg.Mode = SegmentByPackets
+	cfg.Packets = 2
+	a, err := NewFrameAssembler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Unix(100, 0)
+	a.AddPacket(start, sweepPacket(10, 11, 12), nil)
+	frames := a.AddPacket(start.Add(-time.Millisecond), sweepPacket(13, 14), nil)
+	if len(frames) != 1 {
+		t.Fatalf("кадров %d, ожидалось 1", len(frames))
+	}
+	want := []uint32{0, 10, 20, 0, 0}
+	for i, p := range frames[0].Points {
+		if p.Time != want[i] {
+			t.Errorf("точка %d: время %d мкс, ожидалось %d", i, p.Time, want[i])
+		}
+	}
+}
+
+func TestFrameAssemblerCutsByTime(t *testing.T) {
+	cfg := DefaultFrameAssemblerConfig()
+	cfg.Mode = SegmentByTime
+	cfg.Period = 100 * time.Microsecond
+	cfg.MinCoverage = 0
+	a, err := NewFrameAssembler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Unix(100, 0)
+	var frames []AssembledFrame
+	for i := 0; i < 30; i++ {
+		frames = append(frames, a.AddPacket(start.Add(time.Duration(i)*20*time.Microsecond), sweepPacket(0), nil)...)
+	}
+	// Первый интервал отбрасывается, последний ещё не закрыт
+	if len(frames) != 4 {
+		t.Fatalf("кадров %d, ожидалось 4", len(frames))
+	}
+	for _, frame := range frames {
+		if len(frame.Points) != 5 || frame.CaptureTime.Sub(start)%cfg.Period != 0 {
+			t.Errorf("кадр: точек %d, начало %v", len(frame.Points), frame.CaptureTime.Sub(start))
+		}
+	}
+
+	// Скачок времени назад начинает новый кадр
+	frames = a.AddPacket(start, sweepPacket(0), nil)
+	if len(frames) != 1 {
+		t.Errorf("после скачка назад кадров %d, ожидалось 1", len(frames))
+	}
+}
+
+func TestNewFrameAssemblerValidates(t *testing.T) {
+	for _, cfg := range []FrameAssemblerConfig{
+		{Mode: SegmentByAzimuth, CutAzimuth: 360},
+		{Mode: SegmentByTime},
+		{Mode: SegmentByPackets},
+		{Mode: "revolution"},
+	} {
+		if _, err := NewFrameAssembler(cfg); err == nil {
+			t.Errorf("принята конфигурация %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package usecase
+
+import (
+	"fmt"
+	"github.com/chewxy/math32"
+	"sort"
+)
+
+// Obstacle — препятствие: кластер точек и охватывающий его параллелепипед в системе координат ТС
+type Obstacle struct {
+	Center   [3]float32 `json:"center"`
+	Size     [3]float32 `json:"size"` // длина вдоль Yaw, ширина, высота, метры
+	Yaw      float32    `json:"yaw"`  // поворот вокруг оси z, радианы; 0 для рамок по осям
+	Centroid [3]float32 `json:"centroid"`
+	Distance float32    `json:"distance"` // расстояние от начала координат ТС до центроида, метры
+	Points   uint32     `json:"points"`
+}
+
+// ClusterConfig задаёт параметры евклидовой кластеризации
+type ClusterConfig struct {
+	Tolerance float32 // размер ячейки сетки: точки в соседних ячейках попадают в один кластер, метры
+	MinPoints int     // кластеры меньшего размера считаются шумом
+	MaxPoints int     // кластеры большего размера (стены, не выделенная земля) отбрасываются; 0 — без ограничения
+	Oriented  bool    // строить повёрнутые по главной оси рамки вместо рамок по осям
+}
+
+func DefaultClusterConfig() ClusterConfig {
+	return ClusterConfig{
+		Tolerance: 0.5,
+		MinPoints: 10,
+		MaxPoints: 20000,
+	}
+}
+
+// cellOffsets — смещения 26 соседних ячеек и самой ячейки
+var cellOffsets = func() [][3]int32 {
+	offsets := make([][3]int32, 0, 27)
+	for dx := int32(-1); dx <= 1; dx++ {
+		for dy := int32(-1); dy <= 1; dy++ {
+			for dz := int32(-1); dz <= 1; dz++ {
+				offsets = append(offsets, [3]int32{dx, dy, dz})
+			}
+		}
+	}
+	return offsets
+}()
+
+// Clusterer выделяет препятствия евклидовой кластеризацией по воксельной сетке: точки
+// раскладываются по ячейкам со стороной Tolerance, кластер — связная область занятых
+// соседних ячеек. Точки с меткой LabelGround пропускаются, поэтому перед кластеризацией
+// нужна сегментация земли. Не безопасен для одновременного использования.
+type Clusterer struct {
+	cfg ClusterConfig
+
+	cells   map[[3]int32]int32
+	cellOf  []int32 // ячейка каждой точки, -1 для пропущенных
+	starts  []int32 // начало точек каждой ячейки в order
+	order   []int32
+	visited []bool
+	queue   []int32
+	members []int32
+}
+
+// Validate проверяет параметры кластеризации
+func (cfg ClusterConfig) Validate() error {
+	if cfg.Tolerance <= 0 {
+		return fmt.Errorf("допуск кластеризации должен быть положительным: %v", cfg.Tolerance)
+	}
+	if cfg.MinPoints < 1 || (cfg.MaxPoints > 0 && cfg.MaxPoints < cfg.MinPoints) {
+		return fmt.Errorf("недопустимые границы размера кластера [%d, %d]", cfg.MinPoints, cfg.MaxPoints)
+	}
+	return nil
+}
+
+func NewClusterer(cfg ClusterConfig) (*Clusterer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Clusterer{cfg: cfg, cells: make(map[[3]int32]int32)}, nil
+}
+
+// Cluster возвращает препятствия, упорядоченные по расстоянию от ТС
+func (c *Clusterer) Cluster(points []Point) []Obstacle {
+	c.index(points)
+
+	obstacles := []Obstacle{}
+	cellCount := len(c.starts) - 1
+	if cap(c.visited) < cellCount {
+		c.visited = make([]bool, cellCount)
+	}
+	c.visited = c.visited[:cellCount]
+	clear(c.visited)
+	for seed := range cellCount {
+		if c.visited[seed] {
+			continue
+		}
+		// Обход связной области занятых соседних ячеек
+		c.visited[seed] = true
+		c.queue = append(c.queue[:0], int32(seed))
+		c.members = c.members[:0]
+		for len(c.queue) > 0 {
+			cell := c.queue[len(c.queue)-1]
+			c.queue = c.queue[:len(c.queue)-1]
+			c.members = append(c.members, c.order[c.starts[cell]:c.starts[cell+1]]...)
+			key := c.keyOf(points[c.order[c.starts[cell]]])
+			for _, off := range cellOffsets {
+				neighbor, ok := c.cells[[3]int32{key[0] + off[0], key[1] + off[1], key[2] + off[2]}]
+				if ok && !c.visited[neighbor] {
+					c.visited[neighbor] = true
+					c.queue = append(c.queue, neighbor)
+				}
+			}
+		}
+		if len(c.members) < c.cfg.MinPoints || (c.cfg.MaxPoints > 0 && len(c.members) > c.cfg.MaxPoints) {
+			continue
+		}
+		obstacles = append(obstacles, c.bound(points, c.members))
+	}
+	sort.Slice(obstacles, func(i, j int) bool { return obstacles[i].Distance < obstacles[j].Distance })
+	return obstacles
+}
+
+func (c *Clusterer) keyOf(pt Point) [3]int32 {
+	return [3]int32{
+		int32(math32.Floor(pt.X / c.cfg.Tolerance)),
+		int32(math32.Floor(pt.Y / c.cfg.Tolerance)),
+		int32(math32.Floor(pt.Z / c.cfg.Tolerance)),
+	}
+}
+
+// index раскладывает точки по ячейкам сетки: order содержит индексы точек,
+// сгруппированные по ячейкам, starts — границы групп
+func (c *Clusterer) index(points []Point) {
+	clear(c.cells)
+	c.cellOf = c.cellOf[:0]
+	counts := c.starts[:0]
+	for _, pt := range points {
+		if pt.Label == LabelGround {
+			c.cellOf = append(c.cellOf, -1)
+			continue
+		}
+		key := c.keyOf(pt)
+		cell, ok := c.cells[key]
+		if !ok {
+			cell = int32(len(c.cells))
+			c.cells[key] = cell
+			counts = append(counts, 0)
+		}
+		counts[cell]++
+		c.cellOf = append(c.cellOf, cell)
+	}
+	// Счётчики превращаются в границы групп сдвигом префиксных сумм
+	c.starts = append(counts, 0)
+	total := int32(0)
+	for i, n := range c.starts {
+		c.starts[i] = total
+		total += n
+	}
+	if cap(c.order) < int(total) {
+		c.order = make([]int32, total)
+	}
+	c.order = c.order[:total]
+	next := append([]int32(nil), c.starts[:len(c.starts)-1]...)
+	for i, cell := range c.cellOf {
+		if cell >= 0 {
+			c.order[next[cell]] = int32(i)
+			next[cell]++
+		}
+	}
+}
+
+// bound строит охватывающий параллелепипед кластера. Для повёрнутой рамки направление
+// берётся по главной оси разброса точек в плоскости xy.
+func (c *Clusterer) bound(points []Point, members []int32) Obstacle {
+	var cx, cy, cz float32
+	for _, i := range members {
+		cx += points[i].X
+		cy += points[i].Y
+		cz += points[i].Z
+	}
+	n := float32(len(members))
+	cx, cy, cz = cx/n, cy/n, cz/n
+
+	var yaw float32
+	if c.cfg.Oriented {
+		var sxx, syy, sxy float32
+		for _, i := range members {
+			dx, dy := points[i].X-cx, points[i].Y-cy
+			sxx += dx * dx
+			syy += dy * dy
+			sxy += dx * dy
+		}
+		yaw = 0.5 * math32.Atan2(2*sxy, sxx-syy)
+	}
+	sin, cos := math32.Sincos(yaw)
+
+	// Границы в системе рамки: u вдоль Yaw, v поперёк
+	minU, minV, minZ := math32.Inf(1), math32.Inf(1), math32.Inf(1)
+	maxU, maxV, maxZ := math32.Inf(-1), math32.Inf(-1), math32.Inf(-1)
+	for _, i := range members {
+		pt := points[i]
+		u := pt.X*cos + pt.Y*sin
+		v := -pt.X*sin + pt.Y*cos
+		minU, maxU = min(minU, u), max(maxU, u)
+		minV, maxV = min(minV, v), max(maxV, v)
+		minZ, maxZ = min(minZ, pt.Z), max(maxZ, pt.Z)
+	}
+	u, v := (minU+maxU)/2, (minV+maxV)/2
+	return Obstacle{
+		Center:   [3]float32{u*cos - v*sin, u*sin + v*cos, (minZ + maxZ) / 2},
+		Size:     [3]float32{maxU - minU, maxV - minV, maxZ - minZ},
+		Yaw:      yaw,
+		Centroid: [3]float32{cx, cy, cz},
+		Distance: math32.Sqrt(cx*cx + cy*cy + cz*cz),
+		Points:   uint32(len(members)),
+	}
+}
@@ -0,0 +1,117 @@
+package usecase
+
+import (
+	"math"
+	"testing"
+)
+
+// boxPoints заполняет точками с шагом 0,1 м прямоугольник length x width высотой height,
+// повёрнутый на yaw вокруг центра (cx, cy) и стоящий на z = 0
+func boxPoints(cx, cy, length, width, height, yaw float32) []Point {
+	sin, cos := float32(math.Sin(float64(yaw))), float32(math.Cos(float64(yaw)))
+	var points []Point
+	for u := -length / 2; u <= length/2+1e-3; u += 0.1 {
+		for v := -width / 2; v <= width/2+1e-3; v += 0.1 {
+			for z := float32(0.2); z <= height+1e-3; z += 0.2 {
+				points = append(points, Point{X: cx + u*cos - v*sin, Y: cy + u*sin + v*cos, Z: z})
+			}
+		}
+	}
+	return points
+}
+
+func clusterScene() []Point {
+	var points []Point
+	points = append(points, boxPoints(20, 0, 4.4, 1.8, 1.4, 0)...)  // машина впереди
+	points = append(points, boxPoints(6, 6, 4, 2, 1, math.Pi/6)...) // машина под углом
+	points = append(points, Point{X: -5, Y: 0, Z: 1}, Point{X: -5.1, Y: 0, Z: 1})
+	for x := float32(-30); x < 30; x += 0.3 {
+		for y := float32(-30); y < 30; y += 0.3 {
+			points = append(points, Point{X: x, Y: y, Label: LabelGround})
+		}
+	}
+	return points
+}
+
+func TestClusterAxisAligned(t *testing.T) {
+	c, err := NewClusterer(DefaultClusterConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	obstacles := c.Cluster(clusterScene())
+	if len(obstacles) != 2 {
+		t.Fatalf("найдено %d препятствий: %+v", len(obstacles), obstacles)
+	}
+	near, far := obstacles[0], obstacles[1]
+	if abs32(near.Centroid[0]-6) > 0.05 || abs32(near.Centroid[1]-6) > 0.05 || near.Yaw != 0 {
+		t.Errorf("ближнее препятствие %+v", near)
+	}
+	if abs32(far.Center[0]-20) > 0.05 || abs32(far.Size[0]-4.4) > 0.05 || abs32(far.Size[1]-1.8) > 0.05 || abs32(far.Size[2]-1.2) > 0.05 {
+		t.Errorf("дальнее препятствие %+v", far)
+	}
+	if far.Points != uint32(len(boxPoints(20, 0, 4.4, 1.8, 1.4, 0))) {
+		t.Errorf("точек в дальнем препятствии %d", far.Points)
+	}
+	if d := math.Hypot(float64(far.Centroid[0]), float64(far.Centroid[1])); math.Abs(float64(far.Distance)-d) > 0.1 {
+		t.Errorf("расстояние %v, ожидалось около %v", far.Distance, d)
+	}
+}
+
+func TestClusterOriented(t *testing.T) {
+	cfg := DefaultClusterConfig()
+	cfg.Oriented = true
+	c, err := NewClusterer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obstacles := c.Cluster(clusterScene())
+	if len(obstacles) != 2 {
+		t.Fatalf("найдено %d препятствий", len(obstacles))
+	}
+	o := obstacles[0]
+	// Главная ось определена с точностью до 180°
+	yaw := math.Mod(float64(o.Yaw)+math.Pi, math.Pi)
+	if math.Abs(yaw-math.Pi/6) > 0.02 || abs32(o.Size[0]-4) > 0.1 || abs32(o.Size[1]-2) > 0.1 {
+		t.Errorf("повёрнутая рамка: yaw %v, размер %v", o.Yaw, o.Size)
+	}
+	if abs32(o.Center[0]-6) > 0.05 || abs32(o.Center[1]-6) > 0.05 {
+		t.Errorf("центр рамки %v", o.Center)
+	}
+}
+
+func TestClusterSizeLimits(t *testing.T) {
+	cfg := DefaultClusterConfig()
+	cfg.MinPoints, cfg.MaxPoints = 2, 500
+	c, err := NewClusterer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Пара точек проходит MinPoints, машины больше MaxPoints отбрасываются
+	obstacles := c.Cluster(clusterScene())
+	if len(obstacles) != 1 || obstacles[0].Points != 2 {
+		t.Fatalf("препятствия %+v", obstacles)
+	}
+	// Повторный вызов не зависит от предыдущего кадра
+	if obstacles := c.Cluster(nil); obstacles == nil || len(obstacles) != 0 {
+		t.Fatalf("пустой кадр: %+v", obstacles)
+	}
+}
+
+func TestClusterConfigValidate(t *testing.T) {
+	for _, cfg := range []ClusterConfig{
+		{Tolerance: 0, MinPoints: 1},
+		{Tolerance: 0.5, MinPoints: 0},
+		{Tolerance: 0.5, MinPoints: 10, MaxPoints: 5},
+	} {
+		if _, err := NewClusterer(cfg); err == nil {
+			t.Errorf("конфигурация %+v принята", cfg)
+		}
+	}
+}
+
+func abs32(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -1,9 +1,47 @@
 package usecase
 
+import "errors"
+
+// Пределы декомпрессии: повреждённый или подделанный кадр не должен заставить сервер
+// выделить лишнюю память
+const (
+	// MaxDecodedSize — наибольший размер данных, восстановленных компрессорами общего
+	// назначения (gzip, zstd, LZ4), по умолчанию, байт
+	MaxDecodedSize = 256 << 20
+	// maxDecodedPoints ограничивает число точек, которые восстанавливают кодеки с энтропийным
+	// кодированием: несколько килобайт потока описывают миллионы точек
+	maxDecodedPoints = 1 << 20
+)
+
 // PointCloudCompressor описывает методы для сжатия/разжатия облака точек
 // Compress принимает []byte (например, сериализованные точки), возвращает []byte (сжатые данные)
 // Decompress принимает []byte (сжатые данные), возвращает []byte (десериализованные точки)
+// Codec возвращает идентификатор ступени, который записывается в заголовок кадра
 type PointCloudCompressor interface {
+	Codec() CodecID
 	Compress(data []byte) ([]byte, error)
 	Decompress(data []byte) ([]byte, error)
 }
+
+// QuantizingCompressor — компрессор, огрубляющий координаты точек. Ошибка координат после
+// декомпрессии не превышает Resolution; Tx записывает её в заголовок кадра.
+type QuantizingCompressor interface {
+	PointCloudCompressor
+	Resolution() float32
+}
+
+// PointCloudEncoder — компрессор, который упаковывает точки кадра сам, вместо SerializePoints:
+// ему нужны измерения сенсоров, а не только координаты. Tx вызывает Encode, если компрессор
+// стоит в цепочке первым; Decompress возвращает точки в формате SerializePoints.
+type PointCloudEncoder interface {
+	PointCloudCompressor
+	Encode(points []Point, fields PointFields, sensors []SensorGeometry) ([]byte, error)
+}
+
+// ErrDeltaBaseMissing — кадр передан изменениями относительно опорного кадра, который не был
+// восстановлен. Rx в этом случае запрашивает у ТС опорный кадр (см. SetKeyframeRequester).
+var ErrDeltaBaseMissing = errors.New("нет опорного кадра для дельты")
+
+// ErrDeltaStale — дельта старше последнего восстановленного кадра (пришла после более новых).
+// Такой кадр отбрасывается без потери опорного состояния и без запроса опорного кадра.
+var ErrDeltaStale = errors.New("устаревшая дельта")
@@ -0,0 +1,269 @@
+package compressor
+
+import (
+	"dispatcher/internal/usecase"
+	"encoding/binary"
+	"fmt"
+	"github.com/chewxy/math32"
+	"sort"
+	"sync/atomic"
+)
+
+// Типы кадров дельта-кодека
+const (
+	deltaKeyframe = 0 // полный набор вокселей
+	deltaUpdate   = 1 // воксели, добавленные и удалённые относительно опорного кадра
+)
+
+// headerSize — тип, номер кадра, номер опорного кадра, размер вокселя, маска полей
+const headerSize = 1 + 4 + 4 + 4 + 1
+
+// DeltaCompressor передаёт облако вокселями: периодически полным опорным кадром, а между ними —
+// только вокселями, появившимися и исчезнувшими относительно предыдущего кадра. Каждый воксель
+// представлен усреднённой точкой кадра, в котором он появился.
+//
+// Кодек хранит состояние: на клиенте — воксели, известные серверу, на сервере — воксели
+// последнего восстановленного кадра. Поэтому у каждого ТС на сервере должен быть свой экземпляр.
+// Compress и Decompress не безопасны для одновременного вызова, RequestKeyframe — безопасен.
+type DeltaCompressor struct {
+	VoxelSize        float32
+	KeyframeInterval int // опорный кадр не реже чем через столько кадров
+
+	forceKeyframe atomic.Bool
+
+	// Состояние кодера
+	sent     map[[3]int32]usecase.Point
+	nextID   uint32
+	sinceKey int
+
+	// Состояние декодера
+	received map[[3]int32]usecase.Point
+	fields   usecase.PointFields
+	lastID   uint32
+	hasBase  bool
+}
+
+// NewDeltaCompressor создаёт дельта-кодек. Для декомпрессии параметры не нужны: размер
+// вокселя записан в данных кадра.
+func NewDeltaCompressor(voxelSize float32, keyframeInterval int) *DeltaCompressor {
+	return &DeltaCompressor{VoxelSize: voxelSize, KeyframeInterval: keyframeInterval}
+}
+
+func (c *DeltaCompressor) Codec() usecase.CodecID {
+	return usecase.CodecDelta
+}
+
+// RequestKeyframe требует передать следующий кадр опорным, например по запросу сервера
+func (c *DeltaCompressor) RequestKeyframe() {
+	c.forceKeyframe.Store(true)
+}
+
+// Compress записывает кадр в виде (little-endian):
+//
+//	kind     uint8   deltaKeyframe или deltaUpdate
+//	id       uint32  номер кадра кодека
+//	base     uint32  номер опорного кадра (deltaUpdate), для deltaKeyframe 0
+//	voxel    float32 размер вокселя, метры
+//	fields   uint8
+//	added    uint32, затем added точек: x, y, z float32 и необязательные поля (см. usecase.AppendPointAttributes)
+//	removed  uint32, затем removed индексов вокселей: разности с предыдущим индексом по x, y, z (zigzag varint)
+//
+// Опорный кадр передаётся каждые KeyframeInterval кадров, по RequestKeyframe и когда изменений
+// больше, чем вокселей в кадре.
+func (c *DeltaCompressor) Compress(data []byte) ([]byte, error) {
+	if !(c.VoxelSize > 0) || math32.IsInf(c.VoxelSize, 1) {
+		return nil, fmt.Errorf("размер вокселя дельта-кодека должен быть положительным: %v", c.VoxelSize)
+	}
+	pts, fields, err := usecase.DeserializePoints(data)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[[3]int32]usecase.Point, len(pts))
+	for _, pt := range usecase.VoxelDownsample(pts, c.VoxelSize) {
+		key := voxelKey(pt, c.VoxelSize)
+		if _, ok := current[key]; !ok {
+			current[key] = pt
+		}
+	}
+
+	var added []usecase.Point
+	var removed [][3]int32
+	for key, pt := range current {
+		if _, ok := c.sent[key]; !ok {
+			added = append(added, pt)
+		}
+	}
+	for key := range c.sent {
+		if _, ok := current[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	// Запрос сбрасывается и при первом кадре: он и так опорный
+	forced := c.forceKeyframe.Swap(false)
+	keyframe := c.sent == nil || forced ||
+		(c.KeyframeInterval > 0 && c.sinceKey+1 >= c.KeyframeInterval) ||
+		len(added)+len(removed) > len(current)
+
+	c.nextID++
+	kind, base := uint8(deltaUpdate), c.nextID-1
+	if keyframe {
+		kind, base = deltaKeyframe, 0
+		added, removed = added[:0], nil
+		for _, pt := range current {
+			added = append(added, pt)
+		}
+		c.sent = current
+		c.sinceKey = 0
+	} else {
+		for _, pt := range added {
+			c.sent[voxelKey(pt, c.VoxelSize)] = pt
+		}
+		for _, key := range removed {
+			delete(c.sent, key)
+		}
+		c.sinceKey++
+	}
+	sortPoints(added, c.VoxelSize)
+	sortKeys(removed)
+
+	buf := make([]byte, 0, headerSize+8+len(added)*(12+usecase.PointAttributesSize(fields))+len(removed)*3)
+	buf = append(buf, kind)
+	buf = binary.LittleEndian.AppendUint32(buf, c.nextID)
+	buf = binary.LittleEndian.AppendUint32(buf, base)
+	buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(c.VoxelSize))
+	buf = append(buf, byte(fields))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(added)))
+	for _, pt := range added {
+		buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(pt.X))
+		buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(pt.Y))
+		buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(pt.Z))
+		buf = usecase.AppendPointAttributes(buf, pt, fields)
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(removed)))
+	var prev [3]int32
+	for _, key := range removed {
+		for axis := range 3 {
+			buf = binary.AppendVarint(buf, int64(key[axis])-int64(prev[axis]))
+		}
+		prev = key
+	}
+	return buf, nil
+}
+
+// Decompress восстанавливает все воксели кадра в формате SerializePoints. Дельта, опорный кадр
+// которой не был восстановлен последним, отклоняется с usecase.ErrDeltaBaseMissing; следующие
+// дельты отклоняются так же, пока не придёт опорный кадр. Дельта не новее последнего
+// восстановленного кадра отклоняется с usecase.ErrDeltaStale и состояние не сбрасывает.
+// Опорный кадр принимается всегда: после перезапуска клиента нумерация начинается заново.
+func (c *DeltaCompressor) Decompress(data []byte) ([]byte, error) {
+	if len(data) < headerSize+4 {
+		return nil, fmt.Errorf("слишком короткие данные дельта-кодека: %d байт", len(data))
+	}
+	kind := data[0]
+	id := binary.LittleEndian.Uint32(data[1:])
+	base := binary.LittleEndian.Uint32(data[5:])
+	voxelSize := math32.Float32frombits(binary.LittleEndian.Uint32(data[9:]))
+	fields := usecase.PointFields(data[13])
+	if kind != deltaKeyframe && kind != deltaUpdate {
+		return nil, fmt.Errorf("неизвестный тип кадра дельта-кодека: %d", kind)
+	}
+	if fields&^usecase.AllPointFields != 0 {
+		return nil, fmt.Errorf("неизвестные поля точки: 0x%02x", uint8(fields))
+	}
+	if !(voxelSize > 0) {
+		return nil, fmt.Errorf("недопустимый размер вокселя: %v", voxelSize)
+	}
+	if kind == deltaUpdate && c.hasBase && int32(id-c.lastID) <= 0 {
+		return nil, fmt.Errorf("%w: кадр %d, последний восстановленный %d", usecase.ErrDeltaStale, id, c.lastID)
+	}
+	if kind == deltaUpdate && (!c.hasBase || base != c.lastID || fields != c.fields) {
+		c.hasBase = false
+		return nil, fmt.Errorf("%w: кадр %d ссылается на %d", usecase.ErrDeltaBaseMissing, id, base)
+	}
+
+	r := data[headerSize:]
+	count := int(binary.LittleEndian.Uint32(r))
+	r = r[4:]
+	size := 12 + usecase.PointAttributesSize(fields)
+	if count > len(r)/size {
+		return nil, fmt.Errorf("ожидалось %d байт на %d точек, получено %d", count*size, count, len(r))
+	}
+	added := make([]usecase.Point, count)
+	for i := range added {
+		rec := r[i*size:]
+		pt := &added[i]
+		pt.X = math32.Float32frombits(binary.LittleEndian.Uint32(rec[0:]))
+		pt.Y = math32.Float32frombits(binary.LittleEndian.Uint32(rec[4:]))
+		pt.Z = math32.Float32frombits(binary.LittleEndian.Uint32(rec[8:]))
+		usecase.ReadPointAttributes(rec[12:], pt, fields)
+	}
+	r = r[count*size:]
+	if len(r) < 4 {
+		return nil, fmt.Errorf("дельта-кодек: нет списка удалённых вокселей")
+	}
+	removedCount := int(binary.LittleEndian.Uint32(r))
+	r = r[4:]
+	removed := make([][3]int32, 0, min(removedCount, len(r)/3))
+	var prev [3]int32
+	for range removedCount {
+		var key [3]int32
+		for axis := range 3 {
+			d, n := binary.Varint(r)
+			if n <= 0 {
+				return nil, fmt.Errorf("дельта-кодек: повреждён список удалённых вокселей")
+			}
+			r = r[n:]
+			key[axis] = int32(int64(prev[axis]) + d)
+		}
+		removed = append(removed, key)
+		prev = key
+	}
+	if len(r) != 0 {
+		return nil, fmt.Errorf("дельта-кодек: лишние %d байт", len(r))
+	}
+
+	if kind == deltaKeyframe {
+		c.received = make(map[[3]int32]usecase.Point, len(added))
+	}
+	for _, key := range removed {
+		delete(c.received, key)
+	}
+	for _, pt := range added {
+		c.received[voxelKey(pt, voxelSize)] = pt
+	}
+	c.lastID, c.fields, c.hasBase = id, fields, true
+
+	pts := make([]usecase.Point, 0, len(c.received))
+	for _, pt := range c.received {
+		pts = append(pts, pt)
+	}
+	sortPoints(pts, voxelSize)
+	return usecase.SerializePoints(pts, fields), nil
+}
+
+func voxelKey(pt usecase.Point, size float32) [3]int32 {
+	return [3]int32{
+		int32(math32.Floor(pt.X / size)),
+		int32(math32.Floor(pt.Y / size)),
+		int32(math32.Floor(pt.Z / size)),
+	}
+}
+
+func keyLess(a, b [3]int32) bool {
+	if a[0] != b[0] {
+		return a[0] < b[0]
+	}
+	if a[1] != b[1] {
+		return a[1] < b[1]
+	}
+	return a[2] < b[2]
+}
+
+// sortPoints упорядочивает точки по индексам вокселей, чтобы следующая ступень сжимала их лучше
+func sortPoints(pts []usecase.Point, size float32) {
+	sort.Slice(pts, func(i, j int) bool { return keyLess(voxelKey(pts[i], size), voxelKey(pts[j], size)) })
+}
+
+func sortKeys(keys [][3]int32) {
+	sort.Slice(keys, func(i, j int) bool { return keyLess(keys[i], keys[j]) })
+}
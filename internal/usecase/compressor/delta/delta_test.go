@@ -0,0 +1,165 @@
+package compressor
+
+import (
+	"dispatcher/internal/usecase"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// deltaScene — облако из стационарных точек, в каждом кадре видна случайная половина
+type deltaScene struct {
+	rng   *rand.Rand
+	world [][3]float32
+}
+
+func newDeltaScene(seed int64) *deltaScene {
+	s := &deltaScene{rng: rand.New(rand.NewSource(seed))}
+	for range 2000 {
+		s.world = append(s.world, [3]float32{s.rng.Float32()*40 - 20, s.rng.Float32()*40 - 20, s.rng.Float32() * 3})
+	}
+	return s
+}
+
+func (s *deltaScene) frame() []byte {
+	var points []usecase.Point
+	for _, w := range s.world {
+		if s.rng.Intn(10) < 2 {
+			continue
+		}
+		points = append(points, usecase.Point{X: w[0], Y: w[1], Z: w[2], Intensity: 10})
+	}
+	return usecase.SerializePoints(points, usecase.FieldIntensity)
+}
+
+func encodeDeltas(t *testing.T, c *DeltaCompressor, s *deltaScene, n int) [][]byte {
+	t.Helper()
+	var frames [][]byte
+	for range n {
+		encoded, err := c.Compress(s.frame())
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, encoded)
+	}
+	return frames
+}
+
+// voxels возвращает множество вокселей восстановленного кадра
+func voxels(t *testing.T, data []byte, size float32) map[[3]int32]bool {
+	t.Helper()
+	points, _, err := usecase.DeserializePoints(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := make(map[[3]int32]bool, len(points))
+	for _, p := range points {
+		set[voxelKey(p, size)] = true
+	}
+	return set
+}
+
+func TestDeltaRoundTrip(t *testing.T) {
+	const voxel = 0.5
+	scene := newDeltaScene(1)
+	encoder := NewDeltaCompressor(voxel, 5)
+	decoder := NewDeltaCompressor(0, 0)
+	for k := range 12 {
+		frame := scene.frame()
+		encoded, err := encoder.Compress(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wantKey := k%5 == 0; (encoded[0] == deltaKeyframe) != wantKey {
+			t.Fatalf("кадр %d: тип %d", k, encoded[0])
+		}
+		decoded, err := decoder.Decompress(encoded)
+		if err != nil {
+			t.Fatalf("кадр %d: %v", k, err)
+		}
+		points, _, _ := usecase.DeserializePoints(frame)
+		want := make(map[[3]int32]bool)
+		for _, p := range usecase.VoxelDownsample(points, voxel) {
+			want[voxelKey(p, voxel)] = true
+		}
+		got := voxels(t, decoded, voxel)
+		if len(got) != len(want) {
+			t.Fatalf("кадр %d: восстановлено %d вокселей, ожидалось %d", k, len(got), len(want))
+		}
+		for key := range want {
+			if !got[key] {
+				t.Fatalf("кадр %d: нет вокселя %v", k, key)
+			}
+		}
+	}
+}
+
+func TestDeltaLostFrameNeedsKeyframe(t *testing.T) {
+	scene := newDeltaScene(2)
+	encoder := NewDeltaCompressor(0.5, 100)
+	frames := encodeDeltas(t, encoder, scene, 4)
+	decoder := NewDeltaCompressor(0, 0)
+	if _, err := decoder.Decompress(frames[0]); err != nil {
+		t.Fatal(err)
+	}
+	// Кадр 1 потерян: дельты после него отклоняются до опорного кадра
+	for _, frame := range frames[2:] {
+		if _, err := decoder.Decompress(frame); !errors.Is(err, usecase.ErrDeltaBaseMissing) {
+			t.Fatalf("ожидалась ErrDeltaBaseMissing, получено %v", err)
+		}
+	}
+	encoder.RequestKeyframe()
+	keyframe := encodeDeltas(t, encoder, scene, 2)
+	if keyframe[0][0] != deltaKeyframe {
+		t.Fatal("RequestKeyframe не дал опорного кадра")
+	}
+	for _, frame := range keyframe {
+		if _, err := decoder.Decompress(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDeltaKeyframeRequestBeforeFirstFrame(t *testing.T) {
+	// Первый кадр и так опорный: запрос до него не даёт второго опорного кадра
+	encoder := NewDeltaCompressor(0.5, 100)
+	encoder.RequestKeyframe()
+	frames := encodeDeltas(t, encoder, newDeltaScene(4), 2)
+	if frames[0][0] != deltaKeyframe || frames[1][0] != deltaUpdate {
+		t.Fatalf("типы кадров %d, %d", frames[0][0], frames[1][0])
+	}
+}
+
+func TestDeltaStaleKeepsBase(t *testing.T) {
+	scene := newDeltaScene(3)
+	frames := encodeDeltas(t, NewDeltaCompressor(0.5, 100), scene, 4)
+	decoder := NewDeltaCompressor(0, 0)
+	for _, frame := range frames[:3] {
+		if _, err := decoder.Decompress(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Опоздавшая дельта отклоняется, но следующая за последним кадром принимается
+	if _, err := decoder.Decompress(frames[1]); !errors.Is(err, usecase.ErrDeltaStale) {
+		t.Fatalf("ожидалась ErrDeltaStale, получено %v", err)
+	}
+	if _, err := decoder.Decompress(frames[3]); err != nil {
+		t.Fatalf("дельта после устаревшей отклонена: %v", err)
+	}
+}
+
+func TestDeltaKeyframeAfterRestart(t *testing.T) {
+	scene := newDeltaScene(4)
+	decoder := NewDeltaCompressor(0, 0)
+	for _, frame := range encodeDeltas(t, NewDeltaCompressor(0.5, 100), scene, 5) {
+		if _, err := decoder.Decompress(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// После перезапуска клиента нумерация начинается с 1, опорный кадр принимается
+	for _, frame := range encodeDeltas(t, NewDeltaCompressor(0.5, 100), scene, 3) {
+		if _, err := decoder.Decompress(frame); err != nil {
+			t.Fatalf("кадр после перезапуска отклонён: %v", err)
+		}
+	}
+}
@@ -4,18 +4,35 @@ import (
 	"bytes"
 	"compress/gzip"
 	"dispatcher/internal/usecase"
+	"fmt"
 	"io"
+	"sync"
 )
 
-type GzipCompressor struct{}
+// writers переиспользует кодеры gzip между кадрами: каждый держит буферы на сотни килобайт
+var writers = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(nil)
+	},
+}
+
+type GzipCompressor struct {
+	MaxDecodedSize int // наибольший размер восстановленных данных, байт
+}
 
 func NewGzipCompressor() usecase.PointCloudCompressor {
-	return &GzipCompressor{}
+	return &GzipCompressor{MaxDecodedSize: usecase.MaxDecodedSize}
+}
+
+func (c *GzipCompressor) Codec() usecase.CodecID {
+	return usecase.CodecGzip
 }
 
 func (c *GzipCompressor) Compress(data []byte) ([]byte, error) {
 	var out bytes.Buffer
-	zw := gzip.NewWriter(&out)
+	zw := writers.Get().(*gzip.Writer)
+	defer writers.Put(zw)
+	zw.Reset(&out)
 	_, err := zw.Write(data)
 	if err != nil {
 		return nil, err
@@ -32,9 +49,12 @@ func (c *GzipCompressor) Decompress(data []byte) ([]byte, error) {
 		return nil, err
 	}
 	defer zr.Close()
-	raw, err := io.ReadAll(zr)
+	raw, err := io.ReadAll(io.LimitReader(zr, int64(c.MaxDecodedSize)+1))
 	if err != nil {
 		return nil, err
 	}
+	if len(raw) > c.MaxDecodedSize {
+		return nil, fmt.Errorf("данные gzip больше допустимых %d байт", c.MaxDecodedSize)
+	}
 	return raw, nil
 }
@@ -0,0 +1,43 @@
+package compressor
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGzipRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("lidar frame "), 10000)
+	c := NewGzipCompressor()
+	// Кодеры берутся из пула, поэтому сжимаем несколько раз
+	for range 3 {
+		encoded, err := c.Compress(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := c.Decompress(encoded)
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Fatalf("данные не восстановлены: %v", err)
+		}
+	}
+}
+
+func TestGzipLimitsDecodedSize(t *testing.T) {
+	c := NewGzipCompressor().(*GzipCompressor)
+	c.MaxDecodedSize = 4 << 10
+	data := make([]byte, c.MaxDecodedSize)
+	encoded, err := c.Compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err := c.Decompress(encoded); err != nil || len(decoded) != len(data) {
+		t.Fatalf("данные размером с предел не восстановлены: %d байт, %v", len(decoded), err)
+	}
+	// Поток нулей на байт больше предела
+	encoded, err = c.Compress(append(data, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Decompress(encoded); err == nil {
+		t.Fatal("распакованы данные больше предела")
+	}
+}
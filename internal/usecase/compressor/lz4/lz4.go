@@ -0,0 +1,108 @@
+package compressor
+
+import (
+	"dispatcher/internal/usecase"
+	"encoding/binary"
+	"fmt"
+	"github.com/pierrec/lz4/v4"
+)
+
+// Способ хранения данных в блоке
+const (
+	blockRaw        = 0 // данные не сжимаются и хранятся как есть
+	blockCompressed = 1
+)
+
+// headerSize — способ хранения и размер исходных данных
+const headerSize = 1 + 4
+
+const (
+	// maxRatio — наибольшая степень сжатия блока LZ4; по ней проверяется заявленный размер
+	maxRatio = 255
+	// maxLevel — наибольший уровень LZ4 HC
+	maxLevel = 9
+)
+
+// Lz4Compressor сжимает данные одним блоком LZ4: быстрый кодер при Level 0, иначе LZ4 HC.
+// Кодер переиспользуется между кадрами; Compress не безопасен для одновременного вызова.
+type Lz4Compressor struct {
+	Level          int // 0 — быстрый кодер, 1..9 — уровень LZ4 HC
+	MaxDecodedSize int // наибольший размер восстановленных данных, байт
+
+	fast lz4.Compressor
+	hc   lz4.CompressorHC
+	buf  []byte
+}
+
+// NewLz4Compressor создаёт компрессор LZ4 и проверяет уровень. Для декомпрессии уровень не нужен.
+func NewLz4Compressor(level int) (usecase.PointCloudCompressor, error) {
+	if level < 0 || level > maxLevel {
+		return nil, fmt.Errorf("уровень LZ4 должен быть от 0 до %d: %d", maxLevel, level)
+	}
+	return &Lz4Compressor{Level: level, MaxDecodedSize: usecase.MaxDecodedSize}, nil
+}
+
+func (c *Lz4Compressor) Codec() usecase.CodecID {
+	return usecase.CodecLZ4
+}
+
+// Compress записывает блок в виде: способ хранения uint8, размер исходных данных uint32
+// (little-endian), затем блок LZ4 или исходные данные, если они не сжимаются
+func (c *Lz4Compressor) Compress(data []byte) ([]byte, error) {
+	if bound := lz4.CompressBlockBound(len(data)); cap(c.buf) < bound {
+		c.buf = make([]byte, bound)
+	}
+	var n int
+	var err error
+	if c.Level == 0 {
+		n, err = c.fast.CompressBlock(data, c.buf[:cap(c.buf)])
+	} else {
+		c.hc.Level = lz4.CompressionLevel(1 << (8 + c.Level))
+		n, err = c.hc.CompressBlock(data, c.buf[:cap(c.buf)])
+	}
+	if err != nil {
+		return nil, err
+	}
+	mode, block := byte(blockCompressed), c.buf[:n]
+	if n == 0 || n >= len(data) {
+		mode, block = blockRaw, data
+	}
+	out := make([]byte, 0, headerSize+len(block))
+	out = append(out, mode)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(data)))
+	return append(out, block...), nil
+}
+
+func (c *Lz4Compressor) Decompress(data []byte) ([]byte, error) {
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("слишком короткие данные LZ4: %d байт", len(data))
+	}
+	mode := data[0]
+	size := int(binary.LittleEndian.Uint32(data[1:]))
+	block := data[headerSize:]
+	if size > c.MaxDecodedSize {
+		return nil, fmt.Errorf("заявленный размер %d байт больше допустимого %d", size, c.MaxDecodedSize)
+	}
+	switch mode {
+	case blockRaw:
+		if len(block) != size {
+			return nil, fmt.Errorf("ожидалось %d байт несжатых данных, получено %d", size, len(block))
+		}
+		return append([]byte(nil), block...), nil
+	case blockCompressed:
+		if size > len(block)*maxRatio {
+			return nil, fmt.Errorf("заявленный размер %d байт невозможен для блока LZ4 из %d байт", size, len(block))
+		}
+		out := make([]byte, size)
+		n, err := lz4.UncompressBlock(block, out)
+		if err != nil {
+			return nil, err
+		}
+		if n != size {
+			return nil, fmt.Errorf("ожидалось %d байт после LZ4, получено %d", size, n)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("неизвестный способ хранения блока LZ4: %d", mode)
+	}
+}
@@ -0,0 +1,102 @@
+package compressor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+func TestLz4RoundTrip(t *testing.T) {
+	data := make([]byte, 64<<10)
+	for i := range data {
+		data[i] = byte(i % 16 * 7)
+	}
+	decoder, err := NewLz4Compressor(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, level := range []int{0, 1, 9} {
+		c, err := NewLz4Compressor(level)
+		if err != nil {
+			t.Fatalf("уровень %d: %v", level, err)
+		}
+		for range 2 {
+			encoded, err := c.Compress(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if encoded[0] != blockCompressed || len(encoded) >= len(data)/2 {
+				t.Errorf("уровень %d: способ %d, %d -> %d байт", level, encoded[0], len(data), len(encoded))
+			}
+			decoded, err := decoder.Decompress(encoded)
+			if err != nil || !bytes.Equal(decoded, data) {
+				t.Fatalf("уровень %d: данные не восстановлены: %v", level, err)
+			}
+		}
+	}
+}
+
+func TestLz4StoresIncompressibleRaw(t *testing.T) {
+	data := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(data)
+	c, _ := NewLz4Compressor(0)
+	encoded, err := c.Compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded[0] != blockRaw || len(encoded) != headerSize+len(data) {
+		t.Fatalf("способ %d, %d байт", encoded[0], len(encoded))
+	}
+	decoded, err := c.Decompress(encoded)
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Fatalf("данные не восстановлены: %v", err)
+	}
+}
+
+func TestLz4LimitsDecodedSize(t *testing.T) {
+	compressor, err := NewLz4Compressor(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := compressor.(*Lz4Compressor)
+	c.MaxDecodedSize = 4 << 10
+	data := make([]byte, c.MaxDecodedSize)
+	encoded, err := c.Compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err := c.Decompress(encoded); err != nil || len(decoded) != len(data) {
+		t.Fatalf("данные размером с предел не восстановлены: %d байт, %v", len(decoded), err)
+	}
+	// Поток нулей на байт больше предела
+	encoded, err = c.Compress(append(data, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Decompress(encoded); err == nil {
+		t.Fatal("распакованы данные больше предела")
+	}
+}
+
+func TestLz4RejectsBadInput(t *testing.T) {
+	for _, level := range []int{-1, 10} {
+		if _, err := NewLz4Compressor(level); err == nil {
+			t.Errorf("принят уровень %d", level)
+		}
+	}
+	c, _ := NewLz4Compressor(0)
+	header := func(mode byte, size uint32) []byte {
+		return binary.LittleEndian.AppendUint32([]byte{mode}, size)
+	}
+	for name, data := range map[string][]byte{
+		"короткий заголовок":           {blockCompressed, 0},
+		"размер больше степени сжатия": append(header(blockCompressed, 1<<20), make([]byte, 16)...),
+		"несовпадение размера":         append(header(blockRaw, 10), make([]byte, 9)...),
+		"неизвестный способ":           append(header(7, 1), 0),
+	} {
+		if _, err := c.Decompress(data); err == nil {
+			t.Errorf("%s: данные приняты", name)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package compressor
+
+import (
+	"dispatcher/internal/usecase"
+)
+
+// OctreeCompressor заменяет облако октодеревом с листьями LeafSize (см. usecase.EncodeOctree).
+// Decompress восстанавливает центры листьев; ошибка координат не превышает половины листа.
+type OctreeCompressor struct {
+	LeafSize   float32
+	Arithmetic bool // сжимать байты занятости адаптивным арифметическим кодером
+}
+
+// NewOctreeCompressor создаёт компрессор октодерева. Для декомпрессии параметры не нужны:
+// они записаны в данных кадра.
+func NewOctreeCompressor(leafSize float32, arithmetic bool) usecase.PointCloudCompressor {
+	return &OctreeCompressor{LeafSize: leafSize, Arithmetic: arithmetic}
+}
+
+func (c *OctreeCompressor) Codec() usecase.CodecID {
+	return usecase.CodecOctree
+}
+
+// Resolution — наибольшая ошибка каждой координаты: точка листа восстанавливается его центром
+func (c *OctreeCompressor) Resolution() float32 {
+	return c.LeafSize / 2
+}
+
+func (c *OctreeCompressor) Compress(data []byte) ([]byte, error) {
+	pts, fields, err := usecase.DeserializePoints(data)
+	if err != nil {
+		return nil, err
+	}
+	return usecase.EncodeOctree(pts, fields, c.LeafSize, c.Arithmetic)
+}
+
+func (c *OctreeCompressor) Decompress(data []byte) ([]byte, error) {
+	pts, fields, err := usecase.DecodeOctree(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	return usecase.SerializePoints(pts, fields), nil
+}
@@ -0,0 +1,38 @@
+package compressor
+
+import (
+	"dispatcher/internal/usecase"
+	"testing"
+)
+
+func TestOctreeCompressor(t *testing.T) {
+	const leaf = 0.2
+	var c usecase.PointCloudCompressor = NewOctreeCompressor(leaf, true)
+	if c.Codec() != usecase.CodecOctree {
+		t.Fatalf("кодек %v", c.Codec())
+	}
+	if resolution := c.(usecase.QuantizingCompressor).Resolution(); resolution != leaf/2 {
+		t.Fatalf("Resolution %v, ожидалась половина листа", resolution)
+	}
+
+	points := []usecase.Point{
+		{X: 0.1, Y: 0.1, Z: 0.1, Intensity: 10},
+		{X: 1.1, Y: 0.1, Z: 0.1, Intensity: 20},
+		{X: 0.1, Y: 2.1, Z: 0.5, Intensity: 30},
+	}
+	compressed, err := c.Compress(usecase.SerializePoints(points, usecase.FieldIntensity))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.Decompress(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, fields, err := usecase.DeserializePoints(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields != usecase.FieldIntensity || len(decoded) != len(points) {
+		t.Fatalf("поля %v, %d точек из %d", fields, len(decoded), len(points))
+	}
+}
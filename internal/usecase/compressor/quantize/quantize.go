@@ -0,0 +1,175 @@
+package compressor
+
+import (
+	"dispatcher/internal/usecase"
+	"encoding/binary"
+	"fmt"
+	"github.com/chewxy/math32"
+	"math"
+)
+
+// headerSize — маска полей, число точек, шаг, начало координат и охват
+const headerSize = 1 + 4 + 4 + 3*4 + 3*4
+
+// QuantizeCompressor переводит координаты точек в целые числа шагов Step от угла
+// охватывающего параллелепипеда кадра. Ошибка координат не превышает половины шага.
+type QuantizeCompressor struct {
+	Step float32
+}
+
+// NewQuantizeCompressor создаёт компрессор с шагом resolution. Для декомпрессии шаг
+// не нужен: он записан в данных кадра.
+func NewQuantizeCompressor(resolution float32) usecase.PointCloudCompressor {
+	return &QuantizeCompressor{Step: resolution}
+}
+
+func (c *QuantizeCompressor) Codec() usecase.CodecID {
+	return usecase.CodecQuantize
+}
+
+// Resolution — наибольшая ошибка каждой координаты: координата округляется до ближайшего шага
+func (c *QuantizeCompressor) Resolution() float32 {
+	return c.Step / 2
+}
+
+// Compress записывает облако в виде (little-endian):
+//
+//	fields  uint8, count uint32  как в SerializePoints
+//	step    float32              шаг квантования, метры
+//	origin  [3]float32           наименьшие x, y, z кадра
+//	extent  [3]uint32            размер охватывающего параллелепипеда в шагах по x, y, z
+//	x, y, z столбцы по count чисел: uint16, если охват по оси не больше 65535 шагов, иначе uint32
+//	count записей необязательных полей (см. usecase.AppendPointAttributes)
+//
+// Столбцы координат хорошо сжимаются следующей ступенью (gzip).
+func (c *QuantizeCompressor) Compress(data []byte) ([]byte, error) {
+	if !(c.Step > 0) || math32.IsInf(c.Step, 1) {
+		return nil, fmt.Errorf("шаг квантования должен быть положительным: %v", c.Step)
+	}
+	pts, fields, err := usecase.DeserializePoints(data)
+	if err != nil {
+		return nil, err
+	}
+	var origin, upper [3]float32
+	for i, pt := range pts {
+		for axis, v := range [3]float32{pt.X, pt.Y, pt.Z} {
+			if math32.IsNaN(v) || math32.IsInf(v, 0) {
+				return nil, fmt.Errorf("точка #%d с недопустимой координатой: %v", i, v)
+			}
+			if i == 0 {
+				origin[axis], upper[axis] = v, v
+			}
+			origin[axis], upper[axis] = min(origin[axis], v), max(upper[axis], v)
+		}
+	}
+	step := float64(c.Step)
+	var extent [3]uint32
+	for axis := range 3 {
+		steps := math.Round((float64(upper[axis]) - float64(origin[axis])) / step)
+		if steps > math.MaxUint32 {
+			return nil, fmt.Errorf("охват кадра %v..%v не помещается в %d шагов %v", origin[axis], upper[axis], uint32(math.MaxUint32), c.Step)
+		}
+		extent[axis] = uint32(steps)
+	}
+
+	size := headerSize + len(pts)*usecase.PointAttributesSize(fields)
+	for axis := range 3 {
+		size += len(pts) * axisWidth(extent[axis])
+	}
+	buf := make([]byte, 0, size)
+	buf = append(buf, byte(fields))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(pts)))
+	buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(c.Step))
+	for axis := range 3 {
+		buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(origin[axis]))
+	}
+	for axis := range 3 {
+		buf = binary.LittleEndian.AppendUint32(buf, extent[axis])
+	}
+	for axis := range 3 {
+		wide := axisWidth(extent[axis]) == 4
+		for _, pt := range pts {
+			v := [3]float32{pt.X, pt.Y, pt.Z}[axis]
+			q := uint32(math.Round((float64(v) - float64(origin[axis])) / step))
+			if wide {
+				buf = binary.LittleEndian.AppendUint32(buf, q)
+			} else {
+				buf = binary.LittleEndian.AppendUint16(buf, uint16(q))
+			}
+		}
+	}
+	for _, pt := range pts {
+		buf = usecase.AppendPointAttributes(buf, pt, fields)
+	}
+	return buf, nil
+}
+
+// Decompress восстанавливает облако в формате SerializePoints
+func (c *QuantizeCompressor) Decompress(data []byte) ([]byte, error) {
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("слишком короткие квантованные данные: %d байт", len(data))
+	}
+	fields := usecase.PointFields(data[0])
+	if fields&^usecase.AllPointFields != 0 {
+		return nil, fmt.Errorf("неизвестные поля точки: 0x%02x", uint8(fields))
+	}
+	count := int(binary.LittleEndian.Uint32(data[1:]))
+	step := float64(math32.Float32frombits(binary.LittleEndian.Uint32(data[5:])))
+	var origin [3]float64
+	var extent [3]uint32
+	for axis := range 3 {
+		origin[axis] = float64(math32.Float32frombits(binary.LittleEndian.Uint32(data[9+axis*4:])))
+		extent[axis] = binary.LittleEndian.Uint32(data[21+axis*4:])
+	}
+	if !(step > 0) {
+		return nil, fmt.Errorf("недопустимый шаг квантования: %v", step)
+	}
+	attrSize := usecase.PointAttributesSize(fields)
+	expected := headerSize + count*attrSize
+	for axis := range 3 {
+		expected += count * axisWidth(extent[axis])
+	}
+	if len(data) != expected {
+		return nil, fmt.Errorf("ожидалось %d байт на %d точек, получено %d", expected, count, len(data))
+	}
+
+	pts := make([]usecase.Point, count)
+	pos := headerSize
+	for axis := range 3 {
+		width := axisWidth(extent[axis])
+		for i := range pts {
+			var q uint32
+			if width == 4 {
+				q = binary.LittleEndian.Uint32(data[pos:])
+			} else {
+				q = uint32(binary.LittleEndian.Uint16(data[pos:]))
+			}
+			pos += width
+			if q > extent[axis] {
+				return nil, fmt.Errorf("точка #%d вне охвата кадра", i)
+			}
+			v := float32(origin[axis] + float64(q)*step)
+			switch axis {
+			case 0:
+				pts[i].X = v
+			case 1:
+				pts[i].Y = v
+			case 2:
+				pts[i].Z = v
+			}
+		}
+	}
+	for i := range pts {
+		usecase.ReadPointAttributes(data[pos:], &pts[i], fields)
+		pos += attrSize
+	}
+	return usecase.SerializePoints(pts, fields), nil
+}
+
+// axisWidth возвращает размер квантованной координаты в байтах по охвату оси в шагах
+func axisWidth(extent uint32) int {
+	if extent <= math.MaxUint16 {
+		return 2
+	}
+	return 4
+}
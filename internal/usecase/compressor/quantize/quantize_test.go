@@ -0,0 +1,110 @@
+package compressor
+
+import (
+	"dispatcher/internal/usecase"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestQuantizeRoundTrip(t *testing.T) {
+	const fields = usecase.FieldIntensity | usecase.FieldRing | usecase.FieldTime
+	// Кольца сенсора вокруг ТС; к ним в каждом случае добавляется одна дальняя точка
+	rng := rand.New(rand.NewSource(1))
+	var rings []usecase.Point
+	for ring := range 16 {
+		elevation := float64(-15+2*ring) * math.Pi / 180
+		for step := range 900 {
+			azimuth := float64(step) * 0.4 * math.Pi / 180
+			r := 2 + 40*rng.Float64()
+			rings = append(rings, usecase.Point{
+				X:         float32(r * math.Cos(elevation) * math.Cos(azimuth)),
+				Y:         float32(r * math.Cos(elevation) * math.Sin(azimuth)),
+				Z:         float32(r * math.Sin(elevation)),
+				Intensity: uint8(rng.Intn(256)),
+				Ring:      uint8(ring),
+				Time:      uint32(step * 110),
+			})
+		}
+	}
+	for _, c := range []struct {
+		step float32
+		far  float32
+	}{
+		{0.01, 100},   // все оси в uint16
+		{0.002, 200},  // охват по x и y больше 65535 шагов — uint32
+		{0.05, 10000}, // дальняя точка
+	} {
+		points := append(slices.Clone(rings), usecase.Point{X: c.far, Y: -c.far, Z: 3})
+		q := NewQuantizeCompressor(c.step)
+		encoded, err := q.Compress(usecase.SerializePoints(points, fields))
+		if err != nil {
+			t.Fatalf("шаг %v: %v", c.step, err)
+		}
+		decoded, err := NewQuantizeCompressor(0).Decompress(encoded)
+		if err != nil {
+			t.Fatalf("шаг %v: %v", c.step, err)
+		}
+		got, gotFields, err := usecase.DeserializePoints(decoded)
+		if err != nil || gotFields != fields || len(got) != len(points) {
+			t.Fatalf("шаг %v: %d точек, поля %v, %v", c.step, len(got), gotFields, err)
+		}
+		if resolution := q.(usecase.QuantizingCompressor).Resolution(); resolution != c.step/2 {
+			t.Fatalf("шаг %v: Resolution %v, ожидалась половина шага", c.step, resolution)
+		}
+		// Ошибка не больше половины шага с запасом на округление float32 дальних координат
+		limit := float64(c.step)/2 + float64(c.far)*1e-6
+		worst := 0.0
+		for i, p := range points {
+			g := got[i]
+			for _, d := range [...]float32{g.X - p.X, g.Y - p.Y, g.Z - p.Z} {
+				worst = max(worst, math.Abs(float64(d)))
+			}
+			if g.Intensity != p.Intensity || g.Ring != p.Ring || g.Time != p.Time {
+				t.Fatalf("шаг %v: поля точки #%d: %+v, ожидалось %+v", c.step, i, g, p)
+			}
+		}
+		if worst > limit {
+			t.Errorf("шаг %v: ошибка %v больше %v", c.step, worst, limit)
+		}
+	}
+}
+
+func TestQuantizeEmptyCloud(t *testing.T) {
+	q := NewQuantizeCompressor(0.01)
+	encoded, err := q.Compress(usecase.SerializePoints(nil, usecase.FieldIntensity))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := q.Decompress(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if points, fields, err := usecase.DeserializePoints(decoded); err != nil || len(points) != 0 || fields != usecase.FieldIntensity {
+		t.Fatalf("%d точек, поля %v, %v", len(points), fields, err)
+	}
+}
+
+func TestQuantizeRejectsInvalid(t *testing.T) {
+	raw := usecase.SerializePoints([]usecase.Point{{X: 1}, {X: 2}}, 0)
+	for _, step := range []float32{0, -0.01, float32(math.Inf(1)), float32(math.NaN())} {
+		if _, err := NewQuantizeCompressor(step).Compress(raw); err == nil {
+			t.Errorf("шаг %v принят", step)
+		}
+	}
+	nan := usecase.SerializePoints([]usecase.Point{{X: float32(math.NaN())}}, 0)
+	if _, err := NewQuantizeCompressor(0.01).Compress(nan); err == nil {
+		t.Error("координата NaN принята")
+	}
+	encoded, err := NewQuantizeCompressor(0.01).Compress(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewQuantizeCompressor(0).Decompress(encoded[:len(encoded)-1]); err == nil {
+		t.Error("обрезанные данные приняты")
+	}
+	if _, err := NewQuantizeCompressor(0).Decompress(encoded[:headerSize-1]); err == nil {
+		t.Error("обрезанный заголовок принят")
+	}
+}
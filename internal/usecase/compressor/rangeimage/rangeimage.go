@@ -0,0 +1,41 @@
+package compressor
+
+import (
+	"dispatcher/internal/usecase"
+	"fmt"
+)
+
+// RangeImageCompressor передаёт кадр изображениями дальностей сенсоров (см. usecase.EncodeRangeImage)
+// вместо координат точек. Он упаковывает точки сам и должен стоять в цепочке Tx первым;
+// Decompress восстанавливает координаты по модели сенсора, записанной в данных кадра.
+type RangeImageCompressor struct {
+	AzimuthStep float32 // ширина столбца изображения, градусы
+	RangeUnit   float32 // единица дальности, метры
+}
+
+// NewRangeImageCompressor создаёт кодек изображения дальностей. Для декомпрессии параметры
+// не нужны: они записаны в данных кадра.
+func NewRangeImageCompressor(azimuthStep, rangeUnit float32) usecase.PointCloudEncoder {
+	return &RangeImageCompressor{AzimuthStep: azimuthStep, RangeUnit: rangeUnit}
+}
+
+func (c *RangeImageCompressor) Codec() usecase.CodecID {
+	return usecase.CodecRangeImage
+}
+
+func (c *RangeImageCompressor) Encode(points []usecase.Point, fields usecase.PointFields, sensors []usecase.SensorGeometry) ([]byte, error) {
+	return usecase.EncodeRangeImage(points, fields, sensors, c.AzimuthStep, c.RangeUnit)
+}
+
+// Compress недоступен: в сериализованных точках нет измерений сенсора
+func (c *RangeImageCompressor) Compress([]byte) ([]byte, error) {
+	return nil, fmt.Errorf("изображение дальностей строится по измерениям сенсора и должно быть первой ступенью")
+}
+
+func (c *RangeImageCompressor) Decompress(data []byte) ([]byte, error) {
+	pts, fields, err := usecase.DecodeRangeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	return usecase.SerializePoints(pts, fields), nil
+}
@@ -0,0 +1,117 @@
+package compressor
+
+import (
+	"dispatcher/internal/usecase"
+	"math"
+	"testing"
+)
+
+// ringScene — оборот сенсора из 16 колец с шагом 0,2° и пропусками без отражения
+func ringScene(geometry usecase.SensorGeometry) []usecase.Point {
+	var points []usecase.Point
+	for step := range 1800 {
+		azimuth := float32(step)*0.2 + 0.05
+		for ring := range geometry.Model.Lasers {
+			if (step+ring)%13 == 0 {
+				continue
+			}
+			distance := float32(8 + 3*math.Sin(float64(azimuth)*math.Pi/60) + float64(ring)*0.2)
+			x, y, z := geometry.Model.Project(uint8(ring), azimuth, distance)
+			x, y, z = geometry.Transform.Apply(x, y, z)
+			points = append(points, usecase.Point{
+				X: x, Y: y, Z: z,
+				Intensity: uint8(10 + ring),
+				Ring:      uint8(ring),
+				Sensor:    geometry.ID,
+				Distance:  distance,
+				Azimuth:   azimuth,
+			})
+		}
+	}
+	return points
+}
+
+func ringModel() usecase.SensorModel {
+	lasers := make([]usecase.LaserModel, 16)
+	for i := range lasers {
+		lasers[i] = usecase.LaserModel{Elevation: float32(-15 + 2*i)}
+	}
+	return usecase.SensorModel{Lasers: lasers}
+}
+
+func TestRangeImageCompressorRoundTrip(t *testing.T) {
+	const fields = usecase.FieldIntensity | usecase.FieldRing | usecase.FieldSensor
+	sensors := []usecase.SensorGeometry{
+		{ID: 0, Model: ringModel(), Transform: usecase.IdentityTransform()},
+		{ID: 3, Model: ringModel(), Transform: usecase.NewTransformRPY([3]float32{-3.5, 0, 1.6}, 0, 0, 180)},
+	}
+	var points []usecase.Point
+	for _, geometry := range sensors {
+		points = append(points, ringScene(geometry)...)
+	}
+
+	c := NewRangeImageCompressor(0.2, 0.002)
+	if c.Codec() != usecase.CodecRangeImage {
+		t.Fatalf("кодек %v", c.Codec())
+	}
+	data, err := c.Encode(points, fields, sensors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serialized, err := c.Decompress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Decompress отдаёт точки в формате SerializePoints, как остальные кодеки цепочки
+	if ratio := float64(len(serialized)) / float64(len(data)); ratio < 4 {
+		t.Errorf("степень сжатия %.1f, ожидалось не меньше 4", ratio)
+	}
+	decoded, gotFields, err := usecase.DeserializePoints(serialized)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotFields != fields || len(decoded) != len(points) {
+		t.Fatalf("поля %v, точек %d; ожидалось %v и %d", gotFields, len(decoded), fields, len(points))
+	}
+
+	// Азимута в сериализованных точках нет: точку сравниваем с ближайшей исходной точкой того же кольца
+	byRing := make(map[[2]uint8][]usecase.Point)
+	for _, p := range points {
+		key := [2]uint8{p.Sensor, p.Ring}
+		byRing[key] = append(byRing[key], p)
+	}
+	for i, got := range decoded {
+		if i%97 != 0 {
+			continue
+		}
+		best := math.Inf(1)
+		var nearest usecase.Point
+		for _, p := range byRing[[2]uint8{got.Sensor, got.Ring}] {
+			dx, dy, dz := float64(got.X-p.X), float64(got.Y-p.Y), float64(got.Z-p.Z)
+			if d := math.Sqrt(dx*dx + dy*dy + dz*dz); d < best {
+				best, nearest = d, p
+			}
+		}
+		if best > 0.003 || got.Intensity != nearest.Intensity {
+			t.Fatalf("точка %+v: ближайшая исходная %+v на %.4f м", got, nearest, best)
+		}
+	}
+}
+
+func TestRangeImageCompressorRejects(t *testing.T) {
+	c := NewRangeImageCompressor(0.2, 0.002)
+	if _, err := c.Compress(usecase.SerializePoints([]usecase.Point{{X: 1}}, 0)); err == nil {
+		t.Error("Compress принял сериализованные точки")
+	}
+	sensors := []usecase.SensorGeometry{{ID: 0, Model: ringModel(), Transform: usecase.IdentityTransform()}}
+	data, err := c.Encode(ringScene(sensors[0]), usecase.FieldRing, sensors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Decompress(data[:len(data)/2]); err == nil {
+		t.Error("принято обрезанное изображение")
+	}
+	if _, err := NewRangeImageCompressor(0, 0.002).Encode(nil, 0, sensors); err == nil {
+		t.Error("принята нулевая ширина столбца")
+	}
+}
@@ -0,0 +1,89 @@
+package compressor
+
+import (
+	"dispatcher/internal/usecase"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+)
+
+// maxLevel — наибольший уровень zstd
+const maxLevel = 22
+
+// ZstdCompressor сжимает данные Zstandard. Кодер и декодер создаются конструктором
+// и переиспользуются между кадрами; оба безопасны для одновременного вызова.
+// Декодер распаковывает до GOMAXPROCS кадров параллельно, поэтому один компрессор
+// можно разделить между сессиями сервера.
+type ZstdCompressor struct {
+	Level        int      // уровень zstd, см. NewZstdCompressor
+	Dictionaries [][]byte // словари zstd (zstd --train); кодер использует первый, декодер — любой по его номеру в кадре
+	// Наибольший размер восстановленных данных, байт. Декодер в любом случае
+	// не выделяет больше usecase.MaxDecodedSize.
+	MaxDecodedSize int
+
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+// NewZstdCompressor создаёт компрессор zstd и проверяет уровень и словари.
+//
+// Кодер на Go реализует четыре ступени сжатия, и уровни zstd 1..22 отображаются на них:
+// 1–2 — fastest (≈ zstd 1), 3–5 — default (≈ zstd 3), 6–9 — better (≈ zstd 7),
+// 10–22 — best (≈ zstd 11). Уровень 0 означает ступень по умолчанию.
+//
+// Для декомпрессии уровень не нужен, а словари нужны все, которыми пользуются клиенты:
+// номер словаря записан в данных кадра.
+func NewZstdCompressor(level int, dictionaries ...[]byte) (usecase.PointCloudCompressor, error) {
+	if level < 0 || level > maxLevel {
+		return nil, fmt.Errorf("уровень zstd должен быть от 0 (по умолчанию) до %d: %d", maxLevel, level)
+	}
+	opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+	if level > 0 {
+		opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	}
+	if len(dictionaries) > 0 {
+		opts = append(opts, zstd.WithEncoderDict(dictionaries[0]))
+	}
+	encoder, err := zstd.NewWriter(nil, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("невозможно создать кодер zstd: %w", err)
+	}
+	decoder, err := zstd.NewReader(nil,
+		zstd.WithDecoderConcurrency(0),
+		zstd.WithDecoderMaxMemory(usecase.MaxDecodedSize),
+		zstd.WithDecoderDicts(dictionaries...),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("невозможно создать декодер zstd: %w", err)
+	}
+	return &ZstdCompressor{
+		Level:          level,
+		Dictionaries:   dictionaries,
+		MaxDecodedSize: usecase.MaxDecodedSize,
+		encoder:        encoder,
+		decoder:        decoder,
+	}, nil
+}
+
+func (c *ZstdCompressor) Codec() usecase.CodecID {
+	return usecase.CodecZstd
+}
+
+func (c *ZstdCompressor) Compress(data []byte) ([]byte, error) {
+	return c.encoder.EncodeAll(data, nil), nil
+}
+
+func (c *ZstdCompressor) Decompress(data []byte) ([]byte, error) {
+	// Размер, заявленный в заголовке кадра zstd, проверяется до распаковки
+	var header zstd.Header
+	if err := header.Decode(data); err == nil && header.HasFCS && header.FrameContentSize > uint64(c.MaxDecodedSize) {
+		return nil, fmt.Errorf("заявленный размер %d байт больше допустимого %d", header.FrameContentSize, c.MaxDecodedSize)
+	}
+	out, err := c.decoder.DecodeAll(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > c.MaxDecodedSize {
+		return nil, fmt.Errorf("данные zstd больше допустимых %d байт", c.MaxDecodedSize)
+	}
+	return out, nil
+}
@@ -0,0 +1,130 @@
+package compressor
+
+import (
+	"bytes"
+	"github.com/klauspost/compress/zstd"
+	"math/rand"
+	"testing"
+)
+
+// sample — данные, похожие на сериализованное облако: повторяющиеся записи с шумом
+func sample(seed int64, size int) []byte {
+	r := rand.New(rand.NewSource(seed))
+	data := make([]byte, size)
+	for i := range data {
+		switch i % 16 {
+		case 0, 1, 2, 3:
+			data[i] = byte(r.Intn(4))
+		default:
+			data[i] = byte(i % 16 * 7)
+		}
+	}
+	return data
+}
+
+func TestZstdRoundTrip(t *testing.T) {
+	data := sample(1, 64<<10)
+	decoder, err := NewZstdCompressor(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, level := range []int{0, 1, 3, 7, 11, 22} {
+		c, err := NewZstdCompressor(level)
+		if err != nil {
+			t.Fatalf("уровень %d: %v", level, err)
+		}
+		// Кодер переиспользуется: второй кадр сжимается тем же кодером
+		for range 2 {
+			encoded, err := c.Compress(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(encoded) >= len(data)/2 {
+				t.Errorf("уровень %d: %d -> %d байт", level, len(data), len(encoded))
+			}
+			decoded, err := decoder.Decompress(encoded)
+			if err != nil || !bytes.Equal(decoded, data) {
+				t.Fatalf("уровень %d: данные не восстановлены: %v", level, err)
+			}
+		}
+	}
+}
+
+func TestZstdDictionary(t *testing.T) {
+	var samples [][]byte
+	for i := range 64 {
+		samples = append(samples, sample(int64(i), 4096))
+	}
+	dict, err := zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       1234,
+		Contents: samples,
+		History:  samples[0][:2048],
+		Level:    zstd.SpeedDefault,
+		Offsets:  [3]int{1, 4, 8},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := sample(99, 4096)
+	withDict, err := NewZstdCompressor(3, dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := withDict.Compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Сервер со списком словарей выбирает нужный по номеру из кадра
+	other := append([]byte(nil), dict...)
+	other[4]++ // другой номер словаря
+	server, err := NewZstdCompressor(0, other, dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := server.Decompress(encoded)
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Fatalf("данные со словарём не восстановлены: %v", err)
+	}
+
+	withoutDict, _ := NewZstdCompressor(0)
+	if _, err := withoutDict.Decompress(encoded); err == nil {
+		t.Error("кадр со словарём распакован без словаря")
+	}
+}
+
+func TestZstdLimitsDecodedSize(t *testing.T) {
+	compressor, err := NewZstdCompressor(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := compressor.(*ZstdCompressor)
+	c.MaxDecodedSize = 4 << 10
+	data := make([]byte, c.MaxDecodedSize)
+	encoded, err := c.Compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err := c.Decompress(encoded); err != nil || len(decoded) != len(data) {
+		t.Fatalf("данные размером с предел не восстановлены: %d байт, %v", len(decoded), err)
+	}
+	// Поток нулей на байт больше предела
+	encoded, err = c.Compress(append(data, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Decompress(encoded); err == nil {
+		t.Fatal("распакованы данные больше предела")
+	}
+}
+
+func TestNewZstdCompressorValidates(t *testing.T) {
+	for _, level := range []int{-1, 23} {
+		if _, err := NewZstdCompressor(level); err == nil {
+			t.Errorf("принят уровень %d", level)
+		}
+	}
+	if _, err := NewZstdCompressor(3, []byte("не словарь zstd")); err == nil {
+		t.Error("принят повреждённый словарь")
+	}
+}
@@ -0,0 +1,148 @@
+package usecase
+
+import (
+	"dispatcher/internal/delivery/udp"
+	"fmt"
+	"github.com/chewxy/math32"
+	"time"
+)
+
+// Decoder превращает UDP пакет сенсора в измерения лазеров.
+// Измерения добавляются к переданному срезу returns, чтобы его можно было переиспользовать.
+type Decoder interface {
+	Decode(packet udp.Packet, returns []LaserReturn) (DecodedPacket, error)
+}
+
+// ReturnMode — режим возврата из заводского байта пакета.
+// Те же значения используются как тип отражения у отдельного измерения:
+// ReturnDual у измерения означает, что сильнейшее и последнее отражения совпали.
+type ReturnMode uint8
+
+const (
+	ReturnStrongest ReturnMode = 0x37
+	ReturnLast      ReturnMode = 0x38
+	ReturnDual      ReturnMode = 0x39
+)
+
+func (m ReturnMode) String() string {
+	switch m {
+	case ReturnStrongest:
+		return "strongest"
+	case ReturnLast:
+		return "last"
+	case ReturnDual:
+		return "dual"
+	default:
+		return fmt.Sprintf("return(0x%02x)", uint8(m))
+	}
+}
+
+// ReturnSelection — какие отражения оставлять, если сенсор работает в двойном режиме
+type ReturnSelection uint8
+
+const (
+	SelectBoth ReturnSelection = iota
+	SelectStrongest
+	SelectLast
+)
+
+// ParseReturnSelection разбирает значение из конфигурации: strongest, last или both
+func ParseReturnSelection(name string) (ReturnSelection, error) {
+	switch name {
+	case "", "both":
+		return SelectBoth, nil
+	case "strongest":
+		return SelectStrongest, nil
+	case "last":
+		return SelectLast, nil
+	default:
+		return 0, fmt.Errorf("неизвестный выбор отражений: %q", name)
+	}
+}
+
+// Keeps сообщает, оставлять ли измерение с указанным типом отражения
+func (s ReturnSelection) Keeps(r ReturnMode) bool {
+	switch s {
+	case SelectStrongest:
+		return r != ReturnLast
+	case SelectLast:
+		return r != ReturnStrongest
+	default:
+		return true
+	}
+}
+
+// ProductID — модель сенсора из заводского байта пакета
+type ProductID uint8
+
+const (
+	ProductHDL32E    ProductID = 0x21
+	ProductVLP16     ProductID = 0x22
+	ProductPuckHiRes ProductID = 0x24
+	ProductVLP32C    ProductID = 0x28
+)
+
+// LaserReturn — одно измерение лазера, декодированное из пакета
+type LaserReturn struct {
+	X, Y, Z   float32
+	Distance  float32 // метры
+	Azimuth   float32 // градусы, интерполированные для конкретного выстрела
+	Intensity uint8
+	Ring      uint8      // номер кольца снизу вверх
+	Return    ReturnMode // тип отражения
+	Offset    float32    // время выстрела относительно метки времени пакета, мкс
+}
+
+// DecodedPacket — декодированный пакет данных
+type DecodedPacket struct {
+	Timestamp  uint32 // мкс от начала часа по часам сенсора (GPS, если подключён)
+	ReturnMode ReturnMode
+	ProductID  ProductID
+	Returns    []LaserReturn
+}
+
+// SensorTime восстанавливает абсолютное время по метке сенсора (мкс от начала часа),
+// беря час по часам хоста. Если метка и часы хоста оказались по разные стороны
+// границы часа, час корректируется.
+func SensorTime(host time.Time, usPastHour uint32) time.Time {
+	t := host.Truncate(time.Hour).Add(time.Duration(usPastHour) * time.Microsecond)
+	switch {
+	case t.Sub(host) > 30*time.Minute:
+		t = t.Add(-time.Hour)
+	case host.Sub(t) > 30*time.Minute:
+		t = t.Add(time.Hour)
+	}
+	return t
+}
+
+// LaserModel — геометрия лазера одного кольца
+type LaserModel struct {
+	Elevation   float32 // вертикальный угол, градусы
+	VertOffset  float32 // смещение лазера от оси вращения по вертикали, метры
+	HorizOffset float32 // смещение лазера от оси вращения по горизонтали, метры
+}
+
+// SensorModel — геометрия лазеров сенсора по номерам колец. По ней дальность и азимут
+// измерения переводятся в координаты системы сенсора.
+type SensorModel struct {
+	Lasers []LaserModel
+}
+
+// Project переводит измерение кольца ring в координаты системы сенсора так же, как декодер.
+// Дальность и азимут должны уже содержать поправки калибровки.
+func (m SensorModel) Project(ring uint8, azimuth, distance float32) (float32, float32, float32) {
+	laser := m.Lasers[ring]
+	sinElevation := math32.Sin(laser.Elevation * degreesToRad)
+	cosElevation := math32.Cos(laser.Elevation * degreesToRad)
+	sinAzimuth, cosAzimuth := math32.Sincos(azimuth * degreesToRad)
+	xyDistance := distance*cosElevation - laser.VertOffset*sinElevation
+	return xyDistance*sinAzimuth - laser.HorizOffset*cosAzimuth,
+		xyDistance*cosAzimuth + laser.HorizOffset*sinAzimuth,
+		distance*sinElevation + laser.VertOffset*cosElevation
+}
+
+// SensorModeler — декодер, знающий геометрию лазеров сенсора. Второе значение false,
+// пока модель не определена (например, до первого пакета при автоопределении модели).
+type SensorModeler interface {
+	SensorModel() (SensorModel, bool)
+}
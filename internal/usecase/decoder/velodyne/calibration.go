@@ -0,0 +1,149 @@
+package decoder
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/chewxy/math32"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LaserCalibration — заводские поправки одного лазера.
+// Углы в градусах, расстояния в метрах.
+type LaserCalibration struct {
+	ID             int
+	RotCorrection  float32 // поправка азимута (вычитается из азимута)
+	VertCorrection float32 // вертикальный угол лазера
+	DistCorrection float32 // поправка дальности
+	VertOffset     float32 // вертикальное смещение лазера от оси
+	HorizOffset    float32 // горизонтальное смещение лазера от оси
+}
+
+// Calibration — заводская калибровка экземпляра сенсора
+type Calibration struct {
+	Lasers []LaserCalibration
+	// DistanceResolution — метры на единицу дальности; 0 — по умолчанию для модели
+	DistanceResolution float32
+}
+
+// LoadCalibration читает файл калибровки Velodyne: db.xml (углы в градусах, расстояния в см)
+// или YAML в формате ROS velodyne_pointcloud (углы в радианах, расстояния в метрах)
+func LoadCalibration(path string) (*Calibration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("невозможно прочитать файл калибровки: %w", err)
+	}
+	var calibration *Calibration
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xml":
+		calibration, err = parseDBXML(data)
+	case ".yaml", ".yml":
+		calibration, err = parseROSYAML(data)
+	default:
+		return nil, fmt.Errorf("неизвестный формат файла калибровки: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("невозможно разобрать файл калибровки %s: %w", path, err)
+	}
+	if len(calibration.Lasers) == 0 {
+		return nil, fmt.Errorf("файл калибровки %s не содержит лазеров", path)
+	}
+	seen := make([]bool, len(calibration.Lasers))
+	for i, laser := range calibration.Lasers {
+		if laser.ID < 0 || laser.ID >= len(calibration.Lasers) || seen[laser.ID] {
+			return nil, fmt.Errorf("лазер #%d: недопустимый или повторный идентификатор %d", i, laser.ID)
+		}
+		seen[laser.ID] = true
+	}
+	return calibration, nil
+}
+
+type dbXML struct {
+	DistLSB float32 `xml:"DB>distLSB_"`
+	Points  []struct {
+		ID                    int     `xml:"id_"`
+		RotCorrection         float32 `xml:"rotCorrection_"`
+		VertCorrection        float32 `xml:"vertCorrection_"`
+		DistCorrection        float32 `xml:"distCorrection_"`
+		VertOffsetCorrection  float32 `xml:"vertOffsetCorrection_"`
+		HorizOffsetCorrection float32 `xml:"horizOffsetCorrection_"`
+	} `xml:"DB>points_>item>px"`
+}
+
+func parseDBXML(data []byte) (*Calibration, error) {
+	var db dbXML
+	if err := xml.Unmarshal(data, &db); err != nil {
+		return nil, err
+	}
+	const cm = 0.01
+	calibration := &Calibration{DistanceResolution: db.DistLSB * cm}
+	for _, p := range db.Points {
+		calibration.Lasers = append(calibration.Lasers, LaserCalibration{
+			ID:             p.ID,
+			RotCorrection:  p.RotCorrection,
+			VertCorrection: p.VertCorrection,
+			DistCorrection: p.DistCorrection * cm,
+			VertOffset:     p.VertOffsetCorrection * cm,
+			HorizOffset:    p.HorizOffsetCorrection * cm,
+		})
+	}
+	return calibration, nil
+}
+
+type rosYAML struct {
+	DistanceResolution float32 `yaml:"distance_resolution"`
+	Lasers             []struct {
+		LaserID               int     `yaml:"laser_id"`
+		RotCorrection         float32 `yaml:"rot_correction"`
+		VertCorrection        float32 `yaml:"vert_correction"`
+		DistCorrection        float32 `yaml:"dist_correction"`
+		VertOffsetCorrection  float32 `yaml:"vert_offset_correction"`
+		HorizOffsetCorrection float32 `yaml:"horiz_offset_correction"`
+	} `yaml:"lasers"`
+}
+
+func parseROSYAML(data []byte) (*Calibration, error) {
+	var ros rosYAML
+	if err := yaml.Unmarshal(data, &ros); err != nil {
+		return nil, err
+	}
+	calibration := &Calibration{DistanceResolution: ros.DistanceResolution}
+	for _, l := range ros.Lasers {
+		calibration.Lasers = append(calibration.Lasers, LaserCalibration{
+			ID:             l.LaserID,
+			RotCorrection:  l.RotCorrection / degreesToRad,
+			VertCorrection: l.VertCorrection / degreesToRad,
+			DistCorrection: l.DistCorrection,
+			VertOffset:     l.VertOffsetCorrection,
+			HorizOffset:    l.HorizOffsetCorrection,
+		})
+	}
+	return calibration, nil
+}
+
+// apply заменяет номинальную геометрию лазеров поправками из калибровки
+func (c *Calibration) apply(d *VelodyneDecoder) error {
+	if len(c.Lasers) != len(d.lasers) {
+		return fmt.Errorf("калибровка на %d лазеров не подходит к модели %s (%d лазеров)",
+			len(c.Lasers), d.model.name, len(d.lasers))
+	}
+	elevation := make([]float32, len(c.Lasers))
+	for _, l := range c.Lasers {
+		laser := &d.lasers[l.ID]
+		laser.azimuthOffset = -l.RotCorrection
+		laser.elevation = l.VertCorrection
+		laser.sinElevation = math32.Sin(l.VertCorrection * degreesToRad)
+		laser.cosElevation = math32.Cos(l.VertCorrection * degreesToRad)
+		laser.distCorrection = l.DistCorrection
+		laser.vertOffset = l.VertOffset
+		laser.horizOffset = l.HorizOffset
+		elevation[l.ID] = l.VertCorrection
+	}
+	assignRings(d.lasers, elevation)
+	if c.DistanceResolution > 0 {
+		d.distanceUnit = c.DistanceResolution
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package decoder
+
+import (
+	"dispatcher/internal/delivery/udp"
+	"dispatcher/internal/usecase"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Один и тот же набор поправок двух лазеров в обоих форматах: лазеры перечислены не по порядку,
+// в db.xml углы в градусах и расстояния в сантиметрах, в YAML — радианы и метры
+const dbXMLFixture = `<?xml version="1.0" encoding="UTF-8" standalone="yes" ?>
+<!DOCTYPE boost_serialization>
+<boost_serialization signature="serialization::archive" version="4">
+<DB class_id="0" tracking_level="0" version="0">
+	<distLSB_>0.2</distLSB_>
+	<points_ class_id="1" tracking_level="0" version="0">
+		<count>2</count>
+		<item_version>1</item_version>
+		<item class_id="2" tracking_level="0" version="1">
+			<px class_id="3" tracking_level="0" version="1">
+				<id_>1</id_>
+				<rotCorrection_>-1.5</rotCorrection_>
+				<vertCorrection_>-15</vertCorrection_>
+				<distCorrection_>120</distCorrection_>
+				<vertOffsetCorrection_>1.5</vertOffsetCorrection_>
+				<horizOffsetCorrection_>2.6</horizOffsetCorrection_>
+			</px>
+		</item>
+		<item>
+			<px>
+				<id_>0</id_>
+				<rotCorrection_>3</rotCorrection_>
+				<vertCorrection_>10</vertCorrection_>
+				<distCorrection_>-40</distCorrection_>
+				<vertOffsetCorrection_>0</vertOffsetCorrection_>
+				<horizOffsetCorrection_>-2.6</horizOffsetCorrection_>
+			</px>
+		</item>
+	</points_>
+</DB>
+</boost_serialization>
+`
+
+const rosYAMLFixture = `distance_resolution: 0.002
+num_lasers: 2
+lasers:
+- {laser_id: 1, rot_correction: -0.02617994, vert_correction: -0.26179939, dist_correction: 1.2,
+   vert_offset_correction: 0.015, horiz_offset_correction: 0.026}
+- {laser_id: 0, rot_correction: 0.05235988, vert_correction: 0.17453293, dist_correction: -0.4,
+   vert_offset_correction: 0, horiz_offset_correction: -0.026}
+`
+
+var fixtureLasers = []LaserCalibration{
+	{ID: 1, RotCorrection: -1.5, VertCorrection: -15, DistCorrection: 1.2, VertOffset: 0.015, HorizOffset: 0.026},
+	{ID: 0, RotCorrection: 3, VertCorrection: 10, DistCorrection: -0.4, VertOffset: 0, HorizOffset: -0.026},
+}
+
+func writeCalibration(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadCalibration(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		content    string
+		resolution float32
+		wantErr    string
+	}{
+		{name: "db.xml", file: "db.xml", content: dbXMLFixture, resolution: 0.002},
+		{name: "ROS YAML", file: "vlp.yaml", content: rosYAMLFixture, resolution: 0.002},
+		{name: "расширение в верхнем регистре", file: "VLP.YML", content: rosYAMLFixture, resolution: 0.002},
+		{name: "неизвестный формат", file: "db.json", content: "{}", wantErr: "неизвестный формат"},
+		{name: "оборванный XML", file: "db.xml", content: dbXMLFixture[:len(dbXMLFixture)/2], wantErr: "невозможно разобрать"},
+		{name: "оборванный YAML", file: "vlp.yaml", content: "lasers:\n- {laser_id: 0, rot_correction", wantErr: "невозможно разобрать"},
+		{name: "XML без лазеров", file: "db.xml", content: "<boost_serialization><DB><distLSB_>0.2</distLSB_></DB></boost_serialization>", wantErr: "не содержит лазеров"},
+		{name: "пустой YAML", file: "vlp.yaml", content: "distance_resolution: 0.002\n", wantErr: "не содержит лазеров"},
+		{name: "повторный идентификатор", file: "vlp.yaml", content: "lasers:\n- {laser_id: 0}\n- {laser_id: 0}\n", wantErr: "идентификатор 0"},
+		{name: "идентификатор вне диапазона", file: "vlp.yaml", content: "lasers:\n- {laser_id: 0}\n- {laser_id: 2}\n", wantErr: "идентификатор 2"},
+		{name: "отрицательный идентификатор", file: "db.xml", content: "<b><DB><points_><item><px><id_>-1</id_></px></item></points_></DB></b>", wantErr: "идентификатор -1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calibration, err := LoadCalibration(writeCalibration(t, tt.file, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ошибка %v, ожидалась содержащая %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !near(calibration.DistanceResolution, tt.resolution, 1e-6) {
+				t.Errorf("разрешение дальности %v, ожидалось %v", calibration.DistanceResolution, tt.resolution)
+			}
+			if len(calibration.Lasers) != len(fixtureLasers) {
+				t.Fatalf("лазеров %d, ожидалось %d", len(calibration.Lasers), len(fixtureLasers))
+			}
+			for i, want := range fixtureLasers {
+				got := calibration.Lasers[i]
+				if got.ID != want.ID || !near(got.RotCorrection, want.RotCorrection, 1e-4) ||
+					!near(got.VertCorrection, want.VertCorrection, 1e-4) || !near(got.DistCorrection, want.DistCorrection, 1e-6) ||
+					!near(got.VertOffset, want.VertOffset, 1e-6) || !near(got.HorizOffset, want.HorizOffset, 1e-6) {
+					t.Errorf("лазер #%d: получено %+v, ожидалось %+v", i, got, want)
+				}
+			}
+		})
+	}
+
+	if _, err := LoadCalibration(filepath.Join(t.TempDir(), "missing.xml")); err == nil {
+		t.Error("прочитан несуществующий файл")
+	}
+}
+
+// vlp16Calibration переворачивает номинальную геометрию VLP-16: лазер i смотрит на 15−2i градусов,
+// поэтому кольца идут в обратном порядке номеров
+func vlp16Calibration() *Calibration {
+	calibration := &Calibration{DistanceResolution: 0.004}
+	for i := 15; i >= 0; i-- {
+		calibration.Lasers = append(calibration.Lasers, LaserCalibration{
+			ID:             i,
+			RotCorrection:  1,
+			VertCorrection: float32(15 - 2*i),
+			DistCorrection: 0.5,
+			VertOffset:     0.01 * float32(i),
+		})
+	}
+	return calibration
+}
+
+func TestCalibrationApply(t *testing.T) {
+	d := newVelodyneDecoder(models[ModelVLP16])
+	if err := vlp16Calibration().apply(d); err != nil {
+		t.Fatal(err)
+	}
+	if d.distanceUnit != 0.004 {
+		t.Errorf("единица дальности %v, ожидалось 0.004", d.distanceUnit)
+	}
+	for i, laser := range d.lasers {
+		elevation := float32(15 - 2*i)
+		if laser.ring != uint8(15-i) || laser.elevation != elevation || laser.azimuthOffset != -1 ||
+			laser.distCorrection != 0.5 || !near(laser.vertOffset, 0.01*float32(i), 1e-6) ||
+			!near(laser.sinElevation, float32(math.Sin(float64(elevation)*math.Pi/180)), 1e-6) {
+			t.Errorf("лазер %d: %+v", i, laser)
+		}
+	}
+
+	// Нулевой канал первого блока: 5000 единиц по 4 мм плюс 0,5 м, азимут 359,80° минус 1°, угол 15°
+	pkt, err := d.Decode(udp.Packet{RawData: goldenPacket(usecase.ProductVLP16, usecase.ReturnStrongest, false)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := pkt.Returns[0]
+	distance, azimuth, elevation := 20.5, 358.8*math.Pi/180, 15*math.Pi/180
+	wantX := float32(distance * math.Cos(elevation) * math.Sin(azimuth))
+	wantY := float32(distance * math.Cos(elevation) * math.Cos(azimuth))
+	wantZ := float32(distance * math.Sin(elevation))
+	if got.Ring != 15 || !near(got.Azimuth, 358.8, 1e-3) || !near(got.X, wantX, 1e-3) || !near(got.Y, wantY, 1e-3) || !near(got.Z, wantZ, 1e-3) {
+		t.Errorf("измерение %+v, ожидалось кольцо 15 и (%.4f, %.4f, %.4f)", got, wantX, wantY, wantZ)
+	}
+
+	hdl32 := newVelodyneDecoder(models[ModelHDL32E])
+	if err := vlp16Calibration().apply(hdl32); err == nil {
+		t.Error("калибровка на 16 лазеров применена к HDL-32E")
+	}
+	if _, err := NewVelodyneDecoder(ModelHDL32E, vlp16Calibration()); err == nil {
+		t.Error("создан декодер HDL-32E с калибровкой на 16 лазеров")
+	}
+}
+
+func TestAutoDecoderRejectsMismatchedCalibration(t *testing.T) {
+	if _, err := NewVelodyneDecoder(ModelAuto, &Calibration{Lasers: make([]LaserCalibration, 7)}); err == nil {
+		t.Error("принята калибровка, не подходящая ни к одной модели")
+	}
+
+	d, err := NewVelodyneDecoder(ModelAuto, vlp16Calibration())
+	if err != nil {
+		t.Fatal(err)
+	}
+	vlp16 := udp.Packet{RawData: goldenPacket(usecase.ProductVLP16, usecase.ReturnStrongest, false)}
+	hdl32 := udp.Packet{RawData: goldenPacket(usecase.ProductHDL32E, usecase.ReturnStrongest, false)}
+
+	pkt, err := d.Decode(vlp16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkt.Returns[0].Ring != 15 {
+		t.Errorf("калибровка не применена: кольцо нулевого лазера %d", pkt.Returns[0].Ring)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := d.Decode(hdl32, nil); err == nil {
+			t.Fatal("пакет HDL-32E разобран по номинальной геометрии вместо калибровки")
+		}
+	}
+	if _, err := d.Decode(vlp16, nil); err != nil {
+		t.Errorf("после пакетов другой модели VLP-16 не разбирается: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package decoder
+
+import (
+	"dispatcher/internal/usecase"
+)
+
+// model описывает геометрию и тайминг выстрелов конкретной модели Velodyne
+type model struct {
+	name    string
+	product usecase.ProductID
+	// elevation — вертикальный угол по номеру лазера, градусы
+	elevation []float32
+	// azimuthOffset — поправка азимута по номеру лазера, градусы (nil — без поправки)
+	azimuthOffset []float32
+	// firingsPerBlock — сколько последовательностей выстрелов записано в одном блоке
+	firingsPerBlock int
+	// blocksPerFiring — сколько блоков занимает одна последовательность (HDL-64E: верхний и нижний)
+	blocksPerFiring int
+	// firingCycle — длительность одной последовательности выстрелов, мкс
+	firingCycle float32
+	// channelDelay — задержка выстрела лазера внутри последовательности, мкс
+	channelDelay func(channel int) float32
+	// distanceUnit — метры на единицу дальности в пакете
+	distanceUnit float32
+}
+
+const (
+	ModelAuto       = "auto"
+	ModelVLP16      = "vlp16"
+	ModelPuckHiRes  = "puck-hires"
+	ModelVLP32C     = "vlp32c"
+	ModelHDL32E     = "hdl32e"
+	ModelHDL64E     = "hdl64e"
+	hdl64LowerFlag  = 0xDDFF // флаг блока нижних лазеров HDL-64E (0xFF 0xDD)
+	hdl64LaserCount = 64
+)
+
+var models = map[string]*model{
+	ModelVLP16: &model{
+		name:    ModelVLP16,
+		product: usecase.ProductVLP16,
+		elevation: []float32{
+			-15, 1, -13, 3, -11, 5, -9, 7,
+			-7, 9, -5, 11, -3, 13, -1, 15,
+		},
+		firingsPerBlock: 2,
+		blocksPerFiring: 1,
+		firingCycle:     55.296,
+		channelDelay:    func(channel int) float32 { return float32(channel) * 2.304 },
+		distanceUnit:    0.002,
+	},
+	ModelPuckHiRes: &model{
+		name:    ModelPuckHiRes,
+		product: usecase.ProductPuckHiRes,
+		elevation: []float32{
+			-10.00, 0.67, -8.67, 2.00, -7.33, 3.33, -6.00, 4.67,
+			-4.67, 6.00, -3.33, 7.33, -2.00, 8.67, -0.67, 10.00,
+		},
+		firingsPerBlock: 2,
+		blocksPerFiring: 1,
+		firingCycle:     55.296,
+		channelDelay:    func(channel int) float32 { return float32(channel) * 2.304 },
+		distanceUnit:    0.002,
+	},
+	ModelVLP32C: &model{
+		name:    ModelVLP32C,
+		product: usecase.ProductVLP32C,
+		elevation: []float32{
+			-25, -1, -1.667, -15.639, -11.31, 0, -0.667, -8.843,
+			-7.254, 0.333, -0.333, -6.148, -5.333, 1.333, 0.667, -4,
+			-4.667, 1.667, 1, -3.667, -3.333, 3.333, 2.333, -2.667,
+			-3, 7, 4.667, -2.333, -2, 15, 10.333, -1.333,
+		},
+		azimuthOffset: []float32{
+			1.4, -4.2, 1.4, -1.4, 1.4, -1.4, 4.2, -1.4,
+			1.4, -4.2, 1.4, -1.4, 4.2, -1.4, 4.2, -1.4,
+			1.4, -4.2, 1.4, -4.2, 4.2, -1.4, 1.4, -1.4,
+			1.4, -1.4, 1.4, -4.2, 4.2, -1.4, 1.4, -1.4,
+		},
+		firingsPerBlock: 1,
+		blocksPerFiring: 1,
+		firingCycle:     55.296,
+		// лазеры VLP-32C стреляют парами
+		channelDelay: func(channel int) float32 { return float32(channel/2) * 2.304 },
+		distanceUnit: 0.004,
+	},
+	ModelHDL32E: &model{
+		name:    ModelHDL32E,
+		product: usecase.ProductHDL32E,
+		elevation: []float32{
+			-30.67, -9.33, -29.33, -8.00, -28.00, -6.67, -26.67, -5.33,
+			-25.33, -4.00, -24.00, -2.67, -22.67, -1.33, -21.33, 0.00,
+			-20.00, 1.33, -18.67, 2.67, -17.33, 4.00, -16.00, 5.33,
+			-14.67, 6.67, -13.33, 8.00, -12.00, 9.33, -10.67, 10.67,
+		},
+		firingsPerBlock: 1,
+		blocksPerFiring: 1,
+		firingCycle:     46.08,
+		channelDelay:    func(channel int) float32 { return float32(channel) * 1.152 },
+		distanceUnit:    0.002,
+	},
+	// У HDL-64E нет фиксированной таблицы углов: здесь номинальные значения,
+	// для точной геометрии нужна заводская калибровка. Задержки внутри
+	// последовательности не документированы, лазеры блока считаются одновременными.
+	ModelHDL64E: &model{
+		name:            ModelHDL64E,
+		elevation:       hdl64NominalElevation(),
+		firingsPerBlock: 1,
+		blocksPerFiring: 2,
+		firingCycle:     48,
+		channelDelay:    func(int) float32 { return 0 },
+		distanceUnit:    0.002,
+	},
+}
+
+// hdl64NominalElevation строит номинальные углы HDL-64E: верхний блок от +2° до -8.33°,
+// нижний от -8.83° до -24.33°
+func hdl64NominalElevation() []float32 {
+	elevation := make([]float32, hdl64LaserCount)
+	for i := 0; i < 32; i++ {
+		elevation[i] = 2 - float32(i)*(10.33/31)
+		elevation[32+i] = -8.83 - float32(i)*(15.5/31)
+	}
+	return elevation
+}
+
+// modelByProduct ищет модель по заводскому байту пакета
+func modelByProduct(product usecase.ProductID) *model {
+	for _, m := range models {
+		if m.product != 0 && m.product == product {
+			return m
+		}
+	}
+	return nil
+}
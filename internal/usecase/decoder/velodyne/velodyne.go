@@ -0,0 +1,310 @@
+package decoder
+
+import (
+	"dispatcher/internal/delivery/udp"
+	"dispatcher/internal/usecase"
+	"encoding/binary"
+	"fmt"
+	"github.com/chewxy/math32"
+	"log"
+	"sort"
+)
+
+// Раскладка пакета данных Velodyne (1206 байт): 12 блоков по 100 байт,
+// затем 4 байта метки времени (мкс от начала часа), байт режима возврата и байт модели.
+const (
+	packetSize     = 1206
+	blockCount     = 12
+	blockSize      = 100
+	blockFlag      = 0xEEFF // 0xFF 0xEE в little-endian
+	blockChannels  = 32     // измерений в одном блоке
+	timestampAt    = 1200
+	returnModeAt   = 1204
+	productIDAt    = 1205
+	degreesToRad   = math32.Pi / 180.0
+	fullRevolution = 360
+)
+
+// laserGeometry — геометрия одного лазера конкретного экземпляра сенсора
+type laserGeometry struct {
+	azimuthOffset  float32 // градусы
+	elevation      float32 // градусы
+	sinElevation   float32
+	cosElevation   float32
+	distCorrection float32 // метры
+	vertOffset     float32 // метры
+	horizOffset    float32 // метры
+	ring           uint8
+}
+
+// VelodyneDecoder декодирует пакеты одной модели Velodyne
+type VelodyneDecoder struct {
+	model        *model
+	lasers       []laserGeometry
+	distanceUnit float32
+}
+
+// NewVelodyneDecoder создаёт декодер для модели из конфигурации.
+// Пустая строка или "auto" — определить модель по первому пакету.
+// Если задана калибровка, её поправки заменяют номинальную геометрию лазеров.
+func NewVelodyneDecoder(modelName string, calibration *Calibration) (usecase.Decoder, error) {
+	if modelName == "" || modelName == ModelAuto {
+		if calibration != nil && !calibrationFitsAnyModel(calibration) {
+			return nil, fmt.Errorf("калибровка на %d лазеров не подходит ни к одной модели Velodyne", len(calibration.Lasers))
+		}
+		return &autoDecoder{
+			decoders:    make(map[string]*VelodyneDecoder),
+			failed:      make(map[string]error),
+			calibration: calibration,
+		}, nil
+	}
+	m, ok := models[modelName]
+	if !ok {
+		return nil, fmt.Errorf("неизвестная модель LiDAR: %q", modelName)
+	}
+	d := newVelodyneDecoder(m)
+	if calibration != nil {
+		if err := calibration.apply(d); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+func newVelodyneDecoder(m *model) *VelodyneDecoder {
+	d := &VelodyneDecoder{
+		model:        m,
+		lasers:       make([]laserGeometry, len(m.elevation)),
+		distanceUnit: m.distanceUnit,
+	}
+	for i, elevation := range m.elevation {
+		d.lasers[i].elevation = elevation
+		d.lasers[i].sinElevation = math32.Sin(elevation * degreesToRad)
+		d.lasers[i].cosElevation = math32.Cos(elevation * degreesToRad)
+		if m.azimuthOffset != nil {
+			d.lasers[i].azimuthOffset = m.azimuthOffset[i]
+		}
+	}
+	assignRings(d.lasers, m.elevation)
+	return d
+}
+
+// assignRings нумерует лазеры по возрастанию вертикального угла
+func assignRings(lasers []laserGeometry, elevation []float32) {
+	order := make([]int, len(elevation))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return elevation[order[a]] < elevation[order[b]] })
+	for ring, laser := range order {
+		lasers[laser].ring = uint8(ring)
+	}
+}
+
+func (d *VelodyneDecoder) Decode(packet udp.Packet, returns []usecase.LaserReturn) (usecase.DecodedPacket, error) {
+	raw := packet.RawData
+	if len(raw) != packetSize {
+		return usecase.DecodedPacket{}, fmt.Errorf("неверный размер пакета: %d байт", len(raw))
+	}
+	pkt := usecase.DecodedPacket{
+		Timestamp:  binary.LittleEndian.Uint32(raw[timestampAt:]),
+		ReturnMode: usecase.ReturnMode(raw[returnModeAt]),
+		ProductID:  usecase.ProductID(raw[productIDAt]),
+	}
+	if d.model.name == ModelHDL64E {
+		// В HDL-64E последние байты — статус, а не заводские байты
+		pkt.ReturnMode = usecase.ReturnStrongest
+		pkt.ProductID = 0
+	}
+
+	m := d.model
+	var azimuths [blockCount]float32
+	var laserBase [blockCount]int
+	for block := 0; block < blockCount; block++ {
+		start := block * blockSize
+		switch flag := binary.LittleEndian.Uint16(raw[start:]); {
+		case flag == blockFlag:
+		case flag == hdl64LowerFlag && m.name == ModelHDL64E:
+			laserBase[block] = blockChannels
+		default:
+			return pkt, fmt.Errorf("блок %d: неверный флаг 0x%04x", block, flag)
+		}
+		azimuths[block] = float32(binary.LittleEndian.Uint16(raw[start+2:])) / 100.0
+	}
+
+	// В двойном режиме каждая последовательность занимает вдвое больше блоков:
+	// чётный блок пары — последнее отражение, нечётный — сильнейшее
+	dual := pkt.ReturnMode == usecase.ReturnDual
+	blocksPerFiring := m.blocksPerFiring
+	if dual {
+		if m.blocksPerFiring > 1 {
+			return pkt, fmt.Errorf("двойной режим не поддерживается для модели %s", m.name)
+		}
+		blocksPerFiring *= 2
+	}
+
+	lasersPerFiring := blockChannels / m.firingsPerBlock
+	groupDuration := float32(m.firingsPerBlock) * m.firingCycle
+	for block := 0; block < blockCount; block++ {
+		start := block * blockSize
+		group := block / blocksPerFiring
+		gap := azimuthGap(&azimuths, block, blocksPerFiring)
+		for channel := 0; channel < blockChannels; channel++ {
+			offset := start + 4 + channel*3
+			rawDistance := binary.LittleEndian.Uint16(raw[offset:])
+			if rawDistance == 0 {
+				continue
+			}
+			returnType := pkt.ReturnMode
+			if dual {
+				// Совпавшие отражения передаются один раз — из блока последнего отражения
+				pair := binary.LittleEndian.Uint16(raw[offset+blockSize*(1-2*(block%2)):])
+				switch {
+				case block%2 == 1 && pair == rawDistance:
+					continue
+				case block%2 == 1:
+					returnType = usecase.ReturnStrongest
+				case pair == rawDistance:
+					returnType = usecase.ReturnDual
+				default:
+					returnType = usecase.ReturnLast
+				}
+			}
+			firing := channel / lasersPerFiring
+			inFiring := channel % lasersPerFiring
+			laser := &d.lasers[laserBase[block]+inFiring]
+			distance := float32(rawDistance)*d.distanceUnit + laser.distCorrection
+
+			// Время выстрела внутри блока и азимут, интерполированный пропорционально ему
+			inBlock := float32(firing)*m.firingCycle + m.channelDelay(inFiring)
+			azimuth := normalizeAzimuth(azimuths[block] + gap*inBlock/groupDuration + laser.azimuthOffset)
+
+			r := usecase.LaserReturn{
+				Distance:  distance,
+				Azimuth:   azimuth,
+				Intensity: raw[offset+2],
+				Ring:      laser.ring,
+				Return:    returnType,
+				Offset:    float32(group)*groupDuration + inBlock,
+			}
+			// Смещения лазера от оси вращения (нулевые без калибровки)
+			sinAzimuth, cosAzimuth := math32.Sincos(azimuth * degreesToRad)
+			xyDistance := distance*laser.cosElevation - laser.vertOffset*laser.sinElevation
+			r.X = xyDistance*sinAzimuth - laser.horizOffset*cosAzimuth
+			r.Y = xyDistance*cosAzimuth + laser.horizOffset*sinAzimuth
+			r.Z = distance*laser.sinElevation + laser.vertOffset*laser.cosElevation
+			returns = append(returns, r)
+		}
+	}
+	pkt.Returns = returns
+	return pkt, nil
+}
+
+// SensorModel возвращает геометрию лазеров по номерам колец. Поправки дальности и азимута
+// в модель не входят: они уже учтены в измерениях.
+func (d *VelodyneDecoder) SensorModel() (usecase.SensorModel, bool) {
+	lasers := make([]usecase.LaserModel, len(d.lasers))
+	for _, laser := range d.lasers {
+		lasers[laser.ring] = usecase.LaserModel{
+			Elevation:   laser.elevation,
+			VertOffset:  laser.vertOffset,
+			HorizOffset: laser.horizOffset,
+		}
+	}
+	return usecase.SensorModel{Lasers: lasers}, true
+}
+
+// azimuthGap возвращает приращение азимута от блока до следующей последовательности выстрелов,
+// занимающей step блоков. Для последней последовательности используется приращение предыдущей.
+func azimuthGap(azimuths *[blockCount]float32, block, step int) float32 {
+	first := block - block%step
+	if first+step >= blockCount {
+		first -= step
+	}
+	gap := azimuths[first+step] - azimuths[first]
+	if gap < 0 {
+		gap += fullRevolution
+	}
+	return gap
+}
+
+func normalizeAzimuth(azimuth float32) float32 {
+	for azimuth >= fullRevolution {
+		azimuth -= fullRevolution
+	}
+	for azimuth < 0 {
+		azimuth += fullRevolution
+	}
+	return azimuth
+}
+
+// autoDecoder определяет модель по заводскому байту пакета (HDL-64E — по флагу нижнего блока)
+// и переключается, если модель в потоке сменилась. Если калибровка не подходит к определённой
+// модели, пакеты этой модели отклоняются: номинальная геометрия вместо заданной исказила бы облако.
+type autoDecoder struct {
+	decoders    map[string]*VelodyneDecoder
+	failed      map[string]error // модели, к которым калибровка не подошла
+	current     *VelodyneDecoder
+	calibration *Calibration
+}
+
+func (a *autoDecoder) Decode(packet udp.Packet, returns []usecase.LaserReturn) (usecase.DecodedPacket, error) {
+	m, err := detectModel(packet.RawData)
+	if err != nil {
+		return usecase.DecodedPacket{}, err
+	}
+	if err := a.failed[m.name]; err != nil {
+		return usecase.DecodedPacket{}, err
+	}
+	if a.current == nil || a.current.model != m {
+		d, ok := a.decoders[m.name]
+		if !ok {
+			d = newVelodyneDecoder(m)
+			if a.calibration != nil {
+				if err := a.calibration.apply(d); err != nil {
+					err = fmt.Errorf("определена модель %s, но калибровка не применима: %w", m.name, err)
+					log.Printf("Decoder: %v", err)
+					a.failed[m.name] = err
+					return usecase.DecodedPacket{}, err
+				}
+			}
+			a.decoders[m.name] = d
+		}
+		log.Printf("Decoder: определена модель LiDAR %s", m.name)
+		a.current = d
+	}
+	return a.current.Decode(packet, returns)
+}
+
+func (a *autoDecoder) SensorModel() (usecase.SensorModel, bool) {
+	if a.current == nil {
+		return usecase.SensorModel{}, false
+	}
+	return a.current.SensorModel()
+}
+
+func detectModel(raw []byte) (*model, error) {
+	if len(raw) != packetSize {
+		return nil, fmt.Errorf("неверный размер пакета: %d байт", len(raw))
+	}
+	for block := 0; block < blockCount; block++ {
+		if binary.LittleEndian.Uint16(raw[block*blockSize:]) == hdl64LowerFlag {
+			return models[ModelHDL64E], nil
+		}
+	}
+	product := usecase.ProductID(raw[productIDAt])
+	if m := modelByProduct(product); m != nil {
+		return m, nil
+	}
+	return nil, fmt.Errorf("неизвестный идентификатор модели 0x%02x", uint8(product))
+}
+
+// calibrationFitsAnyModel проверяет, что число лазеров калибровки совпадает хотя бы с одной моделью
+func calibrationFitsAnyModel(c *Calibration) bool {
+	for _, m := range models {
+		if len(m.elevation) == len(c.Lasers) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package decoder
+
+import (
+	"dispatcher/internal/delivery/udp"
+	"dispatcher/internal/usecase"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// Эталонные пакеты: азимут первого блока 359,80°, шаг 0,20° на последовательность выстрелов
+// (в двойном режиме и у нижних блоков HDL-64E азимут повторяется), дальность канала c —
+// 5000+10·c единиц, интенсивность — номер канала, метка времени 123456 мкс.
+// В двойном режиме нулевой канал блока сильнейшего отражения отличается (4000 единиц).
+// Ожидаемые значения посчитаны по таблицам углов и таймингов из документации Velodyne.
+const (
+	goldenAzimuth   = 35980
+	goldenStep      = 20
+	goldenTimestamp = 123456
+)
+
+func goldenPacket(product usecase.ProductID, mode usecase.ReturnMode, hdl64 bool) []byte {
+	dual := mode == usecase.ReturnDual
+	raw := make([]byte, packetSize)
+	for block := 0; block < blockCount; block++ {
+		start := block * blockSize
+		binary.LittleEndian.PutUint16(raw[start:], blockFlag)
+		if hdl64 && block%2 == 1 {
+			binary.LittleEndian.PutUint16(raw[start:], hdl64LowerFlag)
+		}
+		group := block
+		if dual || hdl64 {
+			group = block / 2
+		}
+		binary.LittleEndian.PutUint16(raw[start+2:], uint16((goldenAzimuth+group*goldenStep)%36000))
+		for channel := 0; channel < blockChannels; channel++ {
+			distance := uint16(5000 + 10*channel)
+			if dual && block%2 == 1 && channel == 0 {
+				distance = 4000
+			}
+			binary.LittleEndian.PutUint16(raw[start+4+channel*3:], distance)
+			raw[start+4+channel*3+2] = byte(channel)
+		}
+	}
+	binary.LittleEndian.PutUint32(raw[timestampAt:], goldenTimestamp)
+	raw[returnModeAt] = byte(mode)
+	raw[productIDAt] = byte(product)
+	return raw
+}
+
+type goldenReturn struct {
+	block, channel  int
+	x, y, z         float32
+	azimuth, offset float32
+	ring            uint8
+}
+
+// goldenIndex — позиция измерения в выходе декодера. В двойном режиме из блока
+// сильнейшего отражения выходит только отличающийся нулевой канал.
+func goldenIndex(block, channel int, dual bool) int {
+	if !dual {
+		return block*blockChannels + channel
+	}
+	index := block / 2 * (blockChannels + 1)
+	if block%2 == 1 {
+		return index + blockChannels
+	}
+	return index + channel
+}
+
+func TestVelodyneDecoderGolden(t *testing.T) {
+	tests := []struct {
+		model   string
+		product usecase.ProductID
+		mode    usecase.ReturnMode
+		returns []goldenReturn
+	}{
+		{ModelVLP16, usecase.ProductVLP16, usecase.ReturnStrongest, []goldenReturn{
+			{block: 0, channel: 0, x: -0.0337, y: 9.6592, z: -2.5882, azimuth: 359.8000, offset: 0.000, ring: 0},
+			{block: 0, channel: 17, x: -0.0173, y: 10.3384, z: 0.1805, azimuth: 359.9042, offset: 57.600, ring: 8},
+			{block: 1, channel: 15, x: 0.0109, y: 9.9490, z: 2.6658, azimuth: 0.0625, offset: 145.152, ring: 15},
+			{block: 11, channel: 31, x: 0.3871, y: 10.2508, z: 2.7487, azimuth: 2.1625, offset: 1306.368, ring: 15},
+		}},
+		{ModelPuckHiRes, usecase.ProductPuckHiRes, usecase.ReturnLast, []goldenReturn{
+			{block: 0, channel: 1, x: -0.0342, y: 10.0193, z: 0.1172, azimuth: 359.8042, offset: 2.304, ring: 8},
+			{block: 5, channel: 30, x: 0.1773, y: 10.5978, z: -0.1240, azimuth: 0.9583, offset: 640.512, ring: 7},
+		}},
+		{ModelVLP32C, usecase.ProductVLP32C, usecase.ReturnStrongest, []goldenReturn{
+			{block: 0, channel: 0, x: 0.3796, y: 18.1222, z: -8.4524, azimuth: 1.2000, offset: 0.000, ring: 0},
+			{block: 0, channel: 1, x: -1.5372, y: 19.9779, z: -0.3497, azimuth: 355.6000, offset: 0.000, ring: 17},
+			{block: 6, channel: 13, x: -0.1253, y: 20.5141, z: 0.4774, azimuth: 359.6500, offset: 345.600, ring: 24},
+			{block: 11, channel: 31, x: 0.2687, y: 21.2326, z: -0.4941, azimuth: 0.7250, offset: 642.816, ring: 16},
+		}},
+		{ModelHDL32E, usecase.ProductHDL32E, usecase.ReturnStrongest, []goldenReturn{
+			{block: 0, channel: 0, x: -0.0300, y: 8.6011, z: -5.1009, azimuth: 359.8000, offset: 0.000, ring: 0},
+			{block: 3, channel: 31, x: 0.1011, y: 10.4359, z: 1.9663, azimuth: 0.5550, offset: 173.952, ring: 31},
+			{block: 11, channel: 16, x: 0.3520, y: 9.6912, z: -3.5296, azimuth: 2.0800, offset: 525.312, ring: 8},
+		}},
+		{ModelHDL64E, 0, usecase.ReturnStrongest, []goldenReturn{
+			{block: 0, channel: 0, x: -0.0349, y: 9.9938, z: 0.3490, azimuth: 359.8000, offset: 0.000, ring: 63},
+			{block: 1, channel: 0, x: -0.0345, y: 9.8814, z: -1.5350, azimuth: 359.8000, offset: 0.000, ring: 31},
+			{block: 1, channel: 31, x: -0.0338, y: 9.6768, z: -4.3753, azimuth: 359.8000, offset: 0.000, ring: 0},
+			{block: 10, channel: 5, x: 0.1410, y: 10.0988, z: 0.0589, azimuth: 0.8000, offset: 240.000, ring: 58},
+		}},
+		{ModelVLP16, usecase.ProductVLP16, usecase.ReturnDual, []goldenReturn{
+			{block: 0, channel: 0, x: -0.0337, y: 9.6592, z: -2.5882, azimuth: 359.8000, offset: 0.000, ring: 0},
+			{block: 0, channel: 1, x: -0.0342, y: 10.0184, z: 0.1749, azimuth: 359.8042, offset: 2.304, ring: 8},
+			{block: 1, channel: 0, x: -0.0270, y: 7.7274, z: -2.0706, azimuth: 359.8000, offset: 0.000, ring: 0},
+			{block: 10, channel: 20, x: 0.1633, y: 10.2076, z: -1.9844, azimuth: 0.9167, offset: 617.472, ring: 2},
+		}},
+		{ModelVLP32C, usecase.ProductVLP32C, usecase.ReturnDual, []goldenReturn{
+			{block: 0, channel: 0, x: 0.3796, y: 18.1222, z: -8.4524, azimuth: 1.2000, offset: 0.000, ring: 0},
+			{block: 1, channel: 0, x: 0.3037, y: 14.4977, z: -6.7619, azimuth: 1.2000, offset: 0.000, ring: 0},
+			{block: 2, channel: 7, x: -0.4809, y: 20.0332, z: -3.1176, azimuth: 358.6250, offset: 62.208, ring: 3},
+		}},
+		{ModelHDL32E, usecase.ProductHDL32E, usecase.ReturnDual, []goldenReturn{
+			{block: 0, channel: 5, x: -0.0306, y: 10.0316, z: -1.1731, azimuth: 359.8250, offset: 5.760, ring: 18},
+			{block: 1, channel: 0, x: -0.0240, y: 6.8809, z: -4.0807, azimuth: 359.8000, offset: 0.000, ring: 0},
+			{block: 10, channel: 31, x: 0.1739, y: 10.4349, z: 1.9663, azimuth: 0.9550, offset: 266.112, ring: 31},
+		}},
+	}
+
+	for _, tt := range tests {
+		for _, name := range []string{tt.model, ModelAuto} {
+			t.Run(tt.model+"/"+tt.mode.String()+"/"+name, func(t *testing.T) {
+				d, err := NewVelodyneDecoder(name, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				raw := goldenPacket(tt.product, tt.mode, tt.model == ModelHDL64E)
+				pkt, err := d.Decode(udp.Packet{RawData: raw}, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				dual := tt.mode == usecase.ReturnDual
+				wantMode, wantProduct, wantCount := tt.mode, tt.product, blockCount*blockChannels
+				if tt.model == ModelHDL64E {
+					wantMode = usecase.ReturnStrongest
+				}
+				if dual {
+					wantCount = blockCount / 2 * (blockChannels + 1)
+				}
+				if pkt.Timestamp != goldenTimestamp || pkt.ReturnMode != wantMode || pkt.ProductID != wantProduct {
+					t.Errorf("заголовок: метка %d, режим %v, модель 0x%02x", pkt.Timestamp, pkt.ReturnMode, uint8(pkt.ProductID))
+				}
+				if len(pkt.Returns) != wantCount {
+					t.Fatalf("измерений %d, ожидалось %d", len(pkt.Returns), wantCount)
+				}
+
+				for _, want := range tt.returns {
+					got := pkt.Returns[goldenIndex(want.block, want.channel, dual)]
+					if got.Intensity != uint8(want.channel) {
+						t.Errorf("блок %d канал %d: найдено измерение канала %d", want.block, want.channel, got.Intensity)
+						continue
+					}
+					if !near(got.X, want.x, 1e-3) || !near(got.Y, want.y, 1e-3) || !near(got.Z, want.z, 1e-3) ||
+						!near(got.Azimuth, want.azimuth, 1e-3) || !near(got.Offset, want.offset, 1e-3) || got.Ring != want.ring {
+						t.Errorf("блок %d канал %d: получено %+v, ожидалось %+v", want.block, want.channel, got, want)
+					}
+					if dual {
+						wantReturn := usecase.ReturnDual
+						switch {
+						case want.channel == 0 && want.block%2 == 0:
+							wantReturn = usecase.ReturnLast
+						case want.channel == 0:
+							wantReturn = usecase.ReturnStrongest
+						}
+						if got.Return != wantReturn {
+							t.Errorf("блок %d канал %d: отражение %v, ожидалось %v", want.block, want.channel, got.Return, wantReturn)
+						}
+					} else if got.Return != wantMode {
+						t.Errorf("блок %d канал %d: отражение %v, ожидалось %v", want.block, want.channel, got.Return, wantMode)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestVelodyneDecoderRejectsBadPackets(t *testing.T) {
+	d, err := NewVelodyneDecoder(ModelVLP16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := goldenPacket(usecase.ProductVLP16, usecase.ReturnStrongest, false)
+	if _, err := d.Decode(udp.Packet{RawData: raw[:packetSize-1]}, nil); err == nil {
+		t.Error("принят укороченный пакет")
+	}
+	raw[5*blockSize] = 0
+	if _, err := d.Decode(udp.Packet{RawData: raw}, nil); err == nil {
+		t.Error("принят блок с неверным флагом")
+	}
+
+	hdl64, err := NewVelodyneDecoder(ModelHDL64E, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw = goldenPacket(0, usecase.ReturnDual, true)
+	raw[returnModeAt] = 0
+	if _, err := hdl64.Decode(udp.Packet{RawData: raw}, nil); err != nil {
+		t.Errorf("HDL-64E: байт статуса принят за режим возврата: %v", err)
+	}
+
+	if _, err := NewVelodyneDecoder("vlp64", nil); err == nil {
+		t.Error("принята неизвестная модель")
+	}
+	auto, _ := NewVelodyneDecoder(ModelAuto, nil)
+	raw = goldenPacket(0x7f, usecase.ReturnStrongest, false)
+	if _, err := auto.Decode(udp.Packet{RawData: raw}, nil); err == nil {
+		t.Error("автоопределение приняло неизвестную модель")
+	}
+}
+
+func near(got, want, tolerance float32) bool {
+	return math.Abs(float64(got-want)) <= float64(tolerance)
+}
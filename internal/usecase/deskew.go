@@ -0,0 +1,161 @@
+package usecase
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Velocity — скорость ТС в его собственной системе координат
+type Velocity struct {
+	Linear  [3]float64 // м/с
+	Angular [3]float64 // рад/с
+}
+
+// MotionSource даёт скорость ТС для компенсации движения за время кадра
+type MotionSource interface {
+	// Velocity возвращает скорость ТС в момент t по часам хоста; false — скорость неизвестна
+	Velocity(t time.Time) (Velocity, bool)
+	// Observe получает каждый кадр после компенсации и фильтров. Источники,
+	// не оценивающие движение по облаку, кадр игнорируют.
+	Observe(captureTime time.Time, points []Point)
+}
+
+// Deskew переводит точки кадра в положение ТС на момент последнего выстрела, считая скорость
+// постоянной за время кадра, и возвращает этот момент в мкс от начала кадра.
+// Точка, снятая на Δ раньше, переходит в p' = exp(ω·Δ)·p + v·Δ при Δ < 0.
+func Deskew(points []Point, v Velocity) uint32 {
+	var end uint32
+	for _, pt := range points {
+		end = max(end, pt.Time)
+	}
+	for i := range points {
+		pt := &points[i]
+		dt := -float64(end-pt.Time) / 1e6
+		motion := rigid{
+			r: rotationExp([3]float64{v.Angular[0] * dt, v.Angular[1] * dt, v.Angular[2] * dt}),
+			t: [3]float64{v.Linear[0] * dt, v.Linear[1] * dt, v.Linear[2] * dt},
+		}
+		p := motion.apply([3]float64{float64(pt.X), float64(pt.Y), float64(pt.Z)})
+		pt.X, pt.Y, pt.Z = float32(p[0]), float32(p[1]), float32(p[2])
+	}
+	return end
+}
+
+// VelocitySample — измерение скорости от внешнего источника (ИНС, одометрия шасси)
+type VelocitySample struct {
+	Time     time.Time // время приёма по часам хоста: часы источника и сенсоров могут не совпадать
+	Velocity Velocity
+}
+
+// velocityFeedSize — сколько последних измерений хранит VelocityFeed
+const velocityFeedSize = 256
+
+// VelocityFeed хранит измерения скорости от внешнего источника и отдаёт ближайшее по времени.
+// Безопасен для одновременного использования.
+type VelocityFeed struct {
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	samples []VelocitySample // по возрастанию времени
+}
+
+// NewVelocityFeed создаёт хранилище измерений; измерения дальше maxAge от запрошенного
+// момента не используются
+func NewVelocityFeed(maxAge time.Duration) *VelocityFeed {
+	return &VelocityFeed{maxAge: maxAge}
+}
+
+// Push добавляет измерение; измерения должны поступать в порядке времени
+func (f *VelocityFeed) Push(sample VelocitySample) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.samples) == velocityFeedSize {
+		copy(f.samples, f.samples[1:])
+		f.samples = f.samples[:velocityFeedSize-1]
+	}
+	f.samples = append(f.samples, sample)
+}
+
+func (f *VelocityFeed) Velocity(t time.Time) (Velocity, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var best Velocity
+	bestAge := f.maxAge + 1
+	for _, s := range f.samples {
+		age := s.Time.Sub(t)
+		if age < 0 {
+			age = -age
+		}
+		if age < bestAge {
+			best, bestAge = s.Velocity, age
+		}
+	}
+	return best, bestAge <= f.maxAge
+}
+
+func (f *VelocityFeed) Observe(time.Time, []Point) {}
+
+// OdometryMotion оценивает скорость ТС одометрией по предыдущим кадрам
+// и считает её постоянной на время следующего кадра
+type OdometryMotion struct {
+	odometry *Odometry
+	last     time.Time
+	lastPose rigid
+	velocity Velocity
+	valid    bool
+}
+
+func NewOdometryMotion(odometry *Odometry) *OdometryMotion {
+	return &OdometryMotion{odometry: odometry}
+}
+
+func (m *OdometryMotion) Velocity(time.Time) (Velocity, bool) {
+	return m.velocity, m.valid
+}
+
+// Observe сопоставляет кадр с предыдущим и пересчитывает скорость по перемещению между ними
+func (m *OdometryMotion) Observe(captureTime time.Time, points []Point) {
+	pose := m.odometry.Update(points)
+	current := poseRigid(pose)
+	dt := captureTime.Sub(m.last).Seconds()
+	if pose.Converged && !m.last.IsZero() && dt > 0 {
+		// Перемещение за кадр в системе ТС на момент предыдущего кадра
+		delta := m.lastPose.inverse().then(current)
+		omega := rotationLog(delta.r)
+		m.velocity = Velocity{
+			Linear:  [3]float64{delta.t[0] / dt, delta.t[1] / dt, delta.t[2] / dt},
+			Angular: [3]float64{omega[0] / dt, omega[1] / dt, omega[2] / dt},
+		}
+		m.valid = true
+	} else if !pose.Converged {
+		m.valid = false
+	}
+	m.last, m.lastPose = captureTime, current
+}
+
+// inverse возвращает обратное перемещение
+func (a rigid) inverse() rigid {
+	var inv rigid
+	for i := range 3 {
+		for j := range 3 {
+			inv.r[i][j] = a.r[j][i]
+		}
+	}
+	for i := range 3 {
+		inv.t[i] = -(inv.r[i][0]*a.t[0] + inv.r[i][1]*a.t[1] + inv.r[i][2]*a.t[2])
+	}
+	return inv
+}
+
+// rotationLog возвращает вектор поворота (ось × угол), обратный rotationExp
+func rotationLog(r [3][3]float64) [3]float64 {
+	cos := max(-1, min(1, (r[0][0]+r[1][1]+r[2][2]-1)/2))
+	theta := math.Acos(cos)
+	w := [3]float64{r[2][1] - r[1][2], r[0][2] - r[2][0], r[1][0] - r[0][1]}
+	if theta < 1e-9 {
+		return [3]float64{w[0] / 2, w[1] / 2, w[2] / 2}
+	}
+	k := theta / (2 * math.Sin(theta))
+	return [3]float64{w[0] * k, w[1] * k, w[2] * k}
+}
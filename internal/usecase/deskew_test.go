@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// movingWall — стена x = 10 м, снятая за 100 мс с ТС, едущего вперёд со скоростью speed
+func movingWall(speed float64) []Point {
+	var points []Point
+	for i := 0; i <= 100; i++ {
+		t := uint32(i * 1000)
+		points = append(points, Point{
+			X:    float32(10 - speed*float64(t)/1e6),
+			Y:    float32(i%7) - 3,
+			Time: t,
+		})
+	}
+	return points
+}
+
+func TestDeskewTranslation(t *testing.T) {
+	points := movingWall(10)
+	end := Deskew(points, Velocity{Linear: [3]float64{10, 0, 0}})
+	if end != 100000 {
+		t.Fatalf("время конца кадра %d мкс, ожидалось 100000", end)
+	}
+	// На момент последнего выстрела ТС проехало 1 м, стена на 9 м
+	for _, p := range points {
+		if math.Abs(float64(p.X)-9) > 1e-4 {
+			t.Fatalf("точка %+v не на стене x = 9", p)
+		}
+	}
+}
+
+func TestDeskewRotation(t *testing.T) {
+	// Неподвижная точка (10, 0), ТС поворачивается на 1 рад/с
+	var points []Point
+	for i := 0; i <= 100; i++ {
+		angle := -float64(i) / 1000
+		points = append(points, Point{X: float32(10 * math.Cos(angle)), Y: float32(10 * math.Sin(angle)), Time: uint32(i * 1000)})
+	}
+	Deskew(points, Velocity{Angular: [3]float64{0, 0, 1}})
+	for _, p := range points {
+		if math.Hypot(float64(p.X)-10*math.Cos(-0.1), float64(p.Y)-10*math.Sin(-0.1)) > 1e-4 {
+			t.Fatalf("точка %+v не в положении на конец кадра", p)
+		}
+	}
+}
+
+func TestProcessorDeskewMovesCaptureTimeToFrameEnd(t *testing.T) {
+	start := time.Unix(1000, 0)
+	feed := NewVelocityFeed(time.Second)
+	feed.Push(VelocitySample{Time: start, Velocity: Velocity{Linear: [3]float64{10, 0, 0}}})
+	p := NewPointCloudProcessor()
+	p.SetMotion(feed)
+
+	frame := FusedFrame{CaptureTime: start, Points: movingWall(10)}
+	p.deskew(&frame)
+	if want := start.Add(100 * time.Millisecond); !frame.CaptureTime.Equal(want) {
+		t.Errorf("время кадра %v, ожидалось %v", frame.CaptureTime, want)
+	}
+	for _, pt := range frame.Points {
+		if pt.Time != 0 || math.Abs(float64(pt.X)-9) > 1e-4 {
+			t.Fatalf("точка %+v", pt)
+		}
+	}
+
+	// Без известной скорости кадр не меняется
+	frame = FusedFrame{CaptureTime: start.Add(time.Hour), Points: movingWall(10)}
+	p.deskew(&frame)
+	if !frame.CaptureTime.Equal(start.Add(time.Hour)) || frame.Points[100].Time != 100000 {
+		t.Errorf("кадр без скорости изменён: %v, %+v", frame.CaptureTime, frame.Points[100])
+	}
+}
+
+func TestProcessorDeskewUsesHostClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	feed := NewVelocityFeed(200 * time.Millisecond)
+	feed.Push(VelocitySample{Time: start, Velocity: Velocity{Linear: [3]float64{10, 0, 0}}})
+	p := NewPointCloudProcessor()
+	p.SetMotion(feed)
+
+	// Часы сенсора без PPS ушли на 17 минут от часов хоста, по которым принята скорость
+	sensorTime := start.Add(17 * time.Minute)
+	frame := FusedFrame{CaptureTime: sensorTime, ReceivedAt: start.Add(time.Millisecond), Points: movingWall(10)}
+	p.deskew(&frame)
+	if frame.Points[0].Time != 0 || math.Abs(float64(frame.Points[0].X)-9) > 1e-4 {
+		t.Errorf("кадр не скомпенсирован по времени приёма: %+v", frame.Points[0])
+	}
+	if want := sensorTime.Add(100 * time.Millisecond); !frame.CaptureTime.Equal(want) {
+		t.Errorf("время кадра %v, ожидалось %v по часам сенсора", frame.CaptureTime, want)
+	}
+
+	frame = FusedFrame{CaptureTime: start, ReceivedAt: start.Add(time.Second), Points: movingWall(10)}
+	p.deskew(&frame)
+	if compensated, missing := p.DeskewStats(); compensated != 1 || missing != 1 {
+		t.Errorf("кадров с компенсацией %d, без неё %d; ожидалось 1 и 1", compensated, missing)
+	}
+}
@@ -0,0 +1,77 @@
+package usecase
+
+import (
+	"github.com/chewxy/math32"
+	"sort"
+)
+
+// voxelAccumulator накапливает точки одного вокселя
+type voxelAccumulator struct {
+	key       [3]int
+	x, y, z   float32
+	intensity uint32
+	time      uint64
+	ring      uint8      // кольцо первой точки вокселя
+	ret       ReturnMode // тип отражения первой точки вокселя
+	sensor    uint8      // сенсор первой точки вокселя
+	label     uint8      // класс первой точки вокселя
+	distance  float32    // измерение первой точки вокселя
+	azimuth   float32
+	count     uint32
+}
+
+// VoxelDownsample заменяет точки каждого вокселя со стороной voxelSize одной усреднённой точкой.
+// Интенсивность и время усредняются, кольцо, тип отражения, сенсор, класс и измерение сенсора берутся от первой точки вокселя.
+// Точки выдаются по возрастанию индексов вокселей (x, y, z), поэтому результат не зависит от порядка обхода map
+// и одинаковые кадры дают одинаковые байты.
+func VoxelDownsample(points []Point, voxelSize float32) []Point {
+	voxelIndex := make(map[[3]int]int)
+	var voxels []voxelAccumulator
+	for _, pt := range points {
+		vx := int(math32.Floor(pt.X / voxelSize))
+		vy := int(math32.Floor(pt.Y / voxelSize))
+		vz := int(math32.Floor(pt.Z / voxelSize))
+		key := [3]int{vx, vy, vz}
+		i, ok := voxelIndex[key]
+		if !ok {
+			i = len(voxels)
+			voxelIndex[key] = i
+			voxels = append(voxels, voxelAccumulator{key: key, ring: pt.Ring, ret: pt.Return, sensor: pt.Sensor, label: pt.Label, distance: pt.Distance, azimuth: pt.Azimuth})
+		}
+		acc := &voxels[i]
+		acc.x += pt.X
+		acc.y += pt.Y
+		acc.z += pt.Z
+		acc.intensity += uint32(pt.Intensity)
+		acc.time += uint64(pt.Time)
+		acc.count++
+	}
+	sort.Slice(voxels, func(i, j int) bool {
+		a, b := voxels[i].key, voxels[j].key
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		if a[1] != b[1] {
+			return a[1] < b[1]
+		}
+		return a[2] < b[2]
+	})
+	averaged := make([]Point, 0, len(voxels))
+	for _, acc := range voxels {
+		cnt := float32(acc.count)
+		averaged = append(averaged, Point{
+			X:         acc.x / cnt,
+			Y:         acc.y / cnt,
+			Z:         acc.z / cnt,
+			Intensity: uint8(acc.intensity / acc.count),
+			Ring:      acc.ring,
+			Time:      uint32(acc.time / uint64(acc.count)),
+			Return:    acc.ret,
+			Sensor:    acc.sensor,
+			Label:     acc.label,
+			Distance:  acc.distance,
+			Azimuth:   acc.azimuth,
+		})
+	}
+	return averaged
+}
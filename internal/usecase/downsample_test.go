@@ -0,0 +1,56 @@
+package usecase
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestVoxelDownsampleAverages(t *testing.T) {
+	points := []Point{
+		{X: 0.1, Y: 0.1, Z: 0.1, Intensity: 10, Time: 100, Ring: 3},
+		{X: 0.3, Y: 0.3, Z: 0.3, Intensity: 20, Time: 300, Ring: 4},
+		{X: -0.1, Y: 0.1, Z: 0.1, Intensity: 50},
+	}
+	got := VoxelDownsample(points, 0.5)
+	want := []Point{
+		{X: -0.1, Y: 0.1, Z: 0.1, Intensity: 50},
+		{X: 0.2, Y: 0.2, Z: 0.2, Intensity: 15, Time: 200, Ring: 3},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("точек %d, ожидалось %d", len(got), len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if abs32(g.X-w.X) > 1e-6 || abs32(g.Y-w.Y) > 1e-6 || abs32(g.Z-w.Z) > 1e-6 ||
+			g.Intensity != w.Intensity || g.Time != w.Time || g.Ring != w.Ring {
+			t.Errorf("точка %d: %+v, ожидалось %+v", i, g, w)
+		}
+	}
+}
+
+func TestVoxelDownsampleIsDeterministic(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	points := make([]Point, 5000)
+	for i := range points {
+		points[i] = Point{X: r.Float32()*40 - 20, Y: r.Float32()*40 - 20, Z: r.Float32() * 3}
+	}
+	first := VoxelDownsample(points, 0.5)
+	for range 5 {
+		if again := VoxelDownsample(points, 0.5); !reflect.DeepEqual(first, again) {
+			t.Fatal("порядок точек меняется от вызова к вызову")
+		}
+	}
+	// Порядок по индексам вокселей не зависит и от порядка входных точек
+	shuffled := append([]Point(nil), points...)
+	r.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	got := VoxelDownsample(shuffled, 0.5)
+	if len(got) != len(first) {
+		t.Fatalf("точек %d, ожидалось %d", len(got), len(first))
+	}
+	for i := range got {
+		if abs32(got[i].X-first[i].X) > 1e-5 || abs32(got[i].Y-first[i].Y) > 1e-5 || abs32(got[i].Z-first[i].Z) > 1e-5 {
+			t.Fatalf("точка %d: %+v, ожидалось %+v", i, got[i], first[i])
+		}
+	}
+}
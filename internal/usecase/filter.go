@@ -0,0 +1,10 @@
+package usecase
+
+// PointCloudFilter описывает ступень очистки облака точек.
+// Filter принимает точки кадра в системе координат ТС и возвращает оставшиеся;
+// исходный срез может переиспользоваться.
+// Name возвращает название ступени для журнала.
+type PointCloudFilter interface {
+	Name() string
+	Filter(points []Point) []Point
+}
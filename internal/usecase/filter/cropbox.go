@@ -0,0 +1,39 @@
+package filter
+
+import (
+	"dispatcher/internal/usecase"
+	"fmt"
+)
+
+// CropBoxFilter оставляет точки внутри параллелепипеда [Min, Max] в системе координат ТС,
+// а с Negative — наоборот, удаляет их (например, отражения от кузова самого ТС)
+type CropBoxFilter struct {
+	Min, Max [3]float32
+	Negative bool
+}
+
+func NewCropBoxFilter(min, max [3]float32, negative bool) (usecase.PointCloudFilter, error) {
+	for i := range min {
+		if min[i] > max[i] {
+			return nil, fmt.Errorf("min больше max по оси %d", i)
+		}
+	}
+	return &CropBoxFilter{Min: min, Max: max, Negative: negative}, nil
+}
+
+func (f *CropBoxFilter) Name() string {
+	return "cropBox"
+}
+
+func (f *CropBoxFilter) Filter(points []usecase.Point) []usecase.Point {
+	kept := points[:0]
+	for _, pt := range points {
+		inside := pt.X >= f.Min[0] && pt.X <= f.Max[0] &&
+			pt.Y >= f.Min[1] && pt.Y <= f.Max[1] &&
+			pt.Z >= f.Min[2] && pt.Z <= f.Max[2]
+		if inside != f.Negative {
+			kept = append(kept, pt)
+		}
+	}
+	return kept
+}
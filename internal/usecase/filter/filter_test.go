@@ -0,0 +1,18 @@
+package filter
+
+import "testing"
+
+func TestFiltersRejectInvalidParameters(t *testing.T) {
+	if _, err := NewCropBoxFilter([3]float32{1, 0, 0}, [3]float32{}, false); err == nil {
+		t.Error("параллелепипед с min больше max принят")
+	}
+	if _, err := NewRangeFilter(10, 5); err == nil {
+		t.Error("диапазон дальности с min больше max принят")
+	}
+	if _, err := NewRangeFilter(-1, 0); err == nil {
+		t.Error("отрицательная дальность принята")
+	}
+	if _, err := NewVoxelFilter(0); err == nil {
+		t.Error("нулевой размер вокселя принят")
+	}
+}
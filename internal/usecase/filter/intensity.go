@@ -0,0 +1,30 @@
+package filter
+
+import (
+	"dispatcher/internal/usecase"
+)
+
+// IntensityFilter удаляет точки с интенсивностью ниже Min. В облаке без поля
+// интенсивности все точки имеют нулевую интенсивность, поэтому на сервере фильтр
+// имеет смысл, только если клиент передаёт это поле.
+type IntensityFilter struct {
+	Min uint8
+}
+
+func NewIntensityFilter(min uint8) usecase.PointCloudFilter {
+	return &IntensityFilter{Min: min}
+}
+
+func (f *IntensityFilter) Name() string {
+	return "intensity"
+}
+
+func (f *IntensityFilter) Filter(points []usecase.Point) []usecase.Point {
+	kept := points[:0]
+	for _, pt := range points {
+		if pt.Intensity >= f.Min {
+			kept = append(kept, pt)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,210 @@
+package filter
+
+import (
+	"dispatcher/internal/usecase"
+	"fmt"
+	"github.com/chewxy/math32"
+	"runtime"
+	"sync"
+)
+
+// OutlierFilter — статистическое удаление выбросов: для каждой точки считается среднее
+// расстояние до K ближайших соседей, точки со средним больше mean + StdDevMul·σ по облаку
+// отбрасываются. Убирает одиночные отражения от дождя, пыли и переотражения.
+type OutlierFilter struct {
+	K         int
+	StdDevMul float32
+
+	tree      kdTree
+	distances []float32
+}
+
+func NewOutlierFilter(k int, stdDevMul float32) (usecase.PointCloudFilter, error) {
+	if k <= 0 {
+		return nil, fmt.Errorf("число соседей должно быть положительным: %d", k)
+	}
+	if stdDevMul <= 0 {
+		return nil, fmt.Errorf("множитель σ должен быть положительным: %v", stdDevMul)
+	}
+	return &OutlierFilter{K: k, StdDevMul: stdDevMul}, nil
+}
+
+func (f *OutlierFilter) Name() string {
+	return "outlier"
+}
+
+// Filter возвращает точки без выбросов. Исходный срез переиспользуется.
+func (f *OutlierFilter) Filter(points []usecase.Point) []usecase.Point {
+	if len(points) <= f.K {
+		return points
+	}
+	f.tree.build(points)
+
+	if cap(f.distances) < len(points) {
+		f.distances = make([]float32, len(points))
+	}
+	distances := f.distances[:len(points)]
+
+	// Поиск соседей только читает дерево, поэтому точки делятся между ядрами.
+	// Внутри части точки перебираются в порядке дерева: соседние запросы проходят по одним и тем же узлам.
+	workers := runtime.GOMAXPROCS(0)
+	chunk := (len(points) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for lo := 0; lo < len(points); lo += chunk {
+		hi := min(lo+chunk, len(points))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			neighbors := make([]float32, 0, f.K+1)
+			for pos := lo; pos < hi; pos++ {
+				// Сама точка находится на нулевом расстоянии, поэтому ищем K+1 соседей
+				neighbors = f.tree.nearest(f.tree.coords[pos], f.K+1, neighbors[:0])
+				var mean float32
+				for _, d := range neighbors[1:] {
+					mean += math32.Sqrt(d)
+				}
+				distances[f.tree.index[pos]] = mean / float32(len(neighbors)-1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var sum, sumSq float64
+	for _, d := range distances {
+		sum += float64(d)
+		sumSq += float64(d) * float64(d)
+	}
+	n := float64(len(points))
+	mean := sum / n
+	variance := sumSq/n - mean*mean
+	threshold := float32(mean) + f.StdDevMul*math32.Sqrt(float32(max(variance, 0)))
+
+	kept := points[:0]
+	for i, pt := range points {
+		if distances[i] <= threshold {
+			kept = append(kept, pt)
+		}
+	}
+	return kept
+}
+
+// kdLeafSize — наибольшее число точек в листе k-d дерева; листья просматриваются перебором
+const kdLeafSize = 12
+
+// kdTree — k-d дерево над точками облака. Узлы хранятся неявно: корень поддиапазона
+// coords[lo:hi] лежит в его середине, ось разбиения чередуется x, y, z по глубине.
+// Координаты копируются в порядке дерева, чтобы обход шёл по соседним адресам памяти.
+type kdTree struct {
+	coords [][3]float32
+	index  []int32 // индекс исходной точки для каждой позиции дерева
+}
+
+func (t *kdTree) build(points []usecase.Point) {
+	if cap(t.coords) < len(points) {
+		t.coords = make([][3]float32, len(points))
+	}
+	if cap(t.index) < len(points) {
+		t.index = make([]int32, len(points))
+	}
+	t.coords = t.coords[:len(points)]
+	t.index = t.index[:len(points)]
+	for i, pt := range points {
+		t.coords[i] = [3]float32{pt.X, pt.Y, pt.Z}
+		t.index[i] = int32(i)
+	}
+	t.split(0, len(points), 0)
+}
+
+func (t *kdTree) split(lo, hi, axis int) {
+	if hi-lo <= kdLeafSize {
+		return
+	}
+	mid := (lo + hi) / 2
+	t.selectNth(lo, hi, mid, axis)
+	next := (axis + 1) % 3
+	t.split(lo, mid, next)
+	t.split(mid+1, hi, next)
+}
+
+// selectNth переставляет coords[lo:hi] так, что на месте n оказывается точка
+// с n-й по величине координатой, меньшие слева, большие справа (quickselect)
+func (t *kdTree) selectNth(lo, hi, n, axis int) {
+	c, index := t.coords, t.index
+	for hi-lo > 1 {
+		pivot := c[(lo+hi)/2][axis]
+		i, j := lo, hi-1
+		for i <= j {
+			for c[i][axis] < pivot {
+				i++
+			}
+			for c[j][axis] > pivot {
+				j--
+			}
+			if i <= j {
+				c[i], c[j] = c[j], c[i]
+				index[i], index[j] = index[j], index[i]
+				i++
+				j--
+			}
+		}
+		switch {
+		case n <= j:
+			hi = j + 1
+		case n >= i:
+			lo = i
+		default:
+			return
+		}
+	}
+}
+
+// nearest возвращает квадраты расстояний до k ближайших к q точек по возрастанию
+func (t *kdTree) nearest(q [3]float32, k int, best []float32) []float32 {
+	return t.search(q, k, 0, len(t.coords), 0, best)
+}
+
+func (t *kdTree) search(q [3]float32, k, lo, hi, axis int, best []float32) []float32 {
+	if hi-lo <= kdLeafSize {
+		for _, p := range t.coords[lo:hi] {
+			dx, dy, dz := p[0]-q[0], p[1]-q[1], p[2]-q[2]
+			best = insertNearest(best, dx*dx+dy*dy+dz*dz, k)
+		}
+		return best
+	}
+	mid := (lo + hi) / 2
+	p := t.coords[mid]
+	dx, dy, dz := p[0]-q[0], p[1]-q[1], p[2]-q[2]
+	best = insertNearest(best, dx*dx+dy*dy+dz*dz, k)
+
+	diff := q[axis] - p[axis]
+	next := (axis + 1) % 3
+	nearLo, nearHi, farLo, farHi := lo, mid, mid+1, hi
+	if diff > 0 {
+		nearLo, nearHi, farLo, farHi = mid+1, hi, lo, mid
+	}
+	best = t.search(q, k, nearLo, nearHi, next, best)
+	// Дальнее поддерево может содержать более близкие точки, только если плоскость разбиения
+	// ближе текущего k-го соседа
+	if len(best) < k || diff*diff < best[len(best)-1] {
+		best = t.search(q, k, farLo, farHi, next, best)
+	}
+	return best
+}
+
+// insertNearest вставляет расстояние в отсортированный список из не более чем k элементов
+func insertNearest(best []float32, d float32, k int) []float32 {
+	if len(best) == k {
+		if d >= best[k-1] {
+			return best
+		}
+		best = best[:k-1]
+	}
+	i := len(best)
+	best = append(best, d)
+	for i > 0 && best[i-1] > d {
+		best[i] = best[i-1]
+		i--
+	}
+	best[i] = d
+	return best
+}
@@ -0,0 +1,115 @@
+package filter
+
+import (
+	"dispatcher/internal/usecase"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// vlp16Frame строит кадр VLP-16 на 0,2° по азимуту (1800 × 16 = 28 800 точек):
+// стены на 15–25 м, земля на 1,8 м ниже сенсора, шум дальности 2 см и редкие ложные отражения.
+func vlp16Frame(seed int64) []usecase.Point {
+	r := rand.New(rand.NewSource(seed))
+	points := make([]usecase.Point, 0, 1800*16)
+	for step := 0; step < 1800; step++ {
+		azimuth := float64(step) * 0.2 * math.Pi / 180
+		for ring := 0; ring < 16; ring++ {
+			elevation := float64(ring*2-15) * math.Pi / 180
+			distance := 20 + 5*math.Sin(azimuth*3)
+			if elevation < 0 {
+				distance = math.Min(distance, 1.8/math.Sin(-elevation))
+			}
+			distance += r.NormFloat64() * 0.02
+			if r.Intn(200) == 0 {
+				distance *= r.Float64()
+			}
+			points = append(points, usecase.Point{
+				X:    float32(distance * math.Cos(elevation) * math.Sin(azimuth)),
+				Y:    float32(distance * math.Cos(elevation) * math.Cos(azimuth)),
+				Z:    float32(distance * math.Sin(elevation)),
+				Ring: uint8(ring),
+			})
+		}
+	}
+	return points
+}
+
+// bruteForceOutliers считает число оставшихся точек полным перебором соседей
+func bruteForceOutliers(points []usecase.Point, k int, stdDevMul float64) int {
+	means := make([]float64, len(points))
+	distances := make([]float64, 0, len(points))
+	var sum, sumSq float64
+	for i, p := range points {
+		distances = distances[:0]
+		for j, q := range points {
+			if i == j {
+				continue
+			}
+			dx, dy, dz := float64(p.X-q.X), float64(p.Y-q.Y), float64(p.Z-q.Z)
+			distances = append(distances, math.Sqrt(dx*dx+dy*dy+dz*dz))
+		}
+		sort.Float64s(distances)
+		for _, d := range distances[:k] {
+			means[i] += d
+		}
+		means[i] /= float64(k)
+		sum += means[i]
+		sumSq += means[i] * means[i]
+	}
+	n := float64(len(points))
+	mean := sum / n
+	threshold := mean + stdDevMul*math.Sqrt(sumSq/n-mean*mean)
+	kept := 0
+	for _, m := range means {
+		if m <= threshold {
+			kept++
+		}
+	}
+	return kept
+}
+
+func TestOutlierFilterMatchesBruteForce(t *testing.T) {
+	points := vlp16Frame(1)[:3000]
+	want := bruteForceOutliers(points, 8, 1)
+
+	f, err := NewOutlierFilter(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := f.Filter(append([]usecase.Point(nil), points...))
+	if len(got) != want {
+		t.Fatalf("осталось %d точек, полный перебор оставляет %d", len(got), want)
+	}
+	if len(got) == len(points) {
+		t.Fatal("фильтр не удалил ни одной точки")
+	}
+}
+
+func TestOutlierFilterRejectsInvalidConfig(t *testing.T) {
+	if _, err := NewOutlierFilter(0, 1); err == nil {
+		t.Error("K = 0 принят")
+	}
+	if _, err := NewOutlierFilter(8, 0); err == nil {
+		t.Error("множитель σ = 0 принят")
+	}
+}
+
+// BenchmarkSOR30k — фильтр на полном кадре VLP-16 (~30 тыс. точек)
+func BenchmarkSOR30k(b *testing.B) {
+	points := vlp16Frame(2)
+	f, err := NewOutlierFilter(8, 1)
+	if err != nil {
+		b.Fatal(err)
+	}
+	buf := make([]usecase.Point, len(points))
+	b.ReportAllocs()
+	b.ResetTimer()
+	kept := 0
+	for i := 0; i < b.N; i++ {
+		copy(buf, points)
+		kept = len(f.Filter(buf))
+	}
+	b.ReportMetric(float64(kept), "kept")
+}
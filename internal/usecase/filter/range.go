@@ -0,0 +1,35 @@
+package filter
+
+import (
+	"dispatcher/internal/usecase"
+	"fmt"
+)
+
+// RangeFilter оставляет точки, удалённые от начала координат ТС на расстояние от Min до Max метров.
+// Max = 0 — без ограничения сверху.
+type RangeFilter struct {
+	Min, Max float32
+}
+
+func NewRangeFilter(min, max float32) (usecase.PointCloudFilter, error) {
+	if min < 0 || max < 0 || (max > 0 && min > max) {
+		return nil, fmt.Errorf("недопустимый диапазон дальности [%v, %v]", min, max)
+	}
+	return &RangeFilter{Min: min, Max: max}, nil
+}
+
+func (f *RangeFilter) Name() string {
+	return "range"
+}
+
+func (f *RangeFilter) Filter(points []usecase.Point) []usecase.Point {
+	minSq, maxSq := f.Min*f.Min, f.Max*f.Max
+	kept := points[:0]
+	for _, pt := range points {
+		distSq := pt.X*pt.X + pt.Y*pt.Y + pt.Z*pt.Z
+		if distSq >= minSq && (f.Max == 0 || distSq <= maxSq) {
+			kept = append(kept, pt)
+		}
+	}
+	return kept
+}
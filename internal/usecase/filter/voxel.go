@@ -0,0 +1,26 @@
+package filter
+
+import (
+	"dispatcher/internal/usecase"
+	"fmt"
+)
+
+// VoxelFilter прореживает облако, заменяя точки каждого вокселя одной усреднённой точкой
+type VoxelFilter struct {
+	VoxelSize float32
+}
+
+func NewVoxelFilter(voxelSize float32) (usecase.PointCloudFilter, error) {
+	if voxelSize <= 0 {
+		return nil, fmt.Errorf("размер вокселя должен быть положительным: %v", voxelSize)
+	}
+	return &VoxelFilter{VoxelSize: voxelSize}, nil
+}
+
+func (f *VoxelFilter) Name() string {
+	return "voxel"
+}
+
+func (f *VoxelFilter) Filter(points []usecase.Point) []usecase.Point {
+	return usecase.VoxelDownsample(points, f.VoxelSize)
+}
@@ -0,0 +1,337 @@
+package usecase
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/chewxy/math32"
+	"hash/crc32"
+	"math"
+	"time"
+)
+
+// Формат кадра на проводе (little-endian):
+//
+//	magic       [4]byte  "LDRF"
+//	version     uint8
+//	vehicleLen  uint8
+//	vehicleID   [vehicleLen]byte
+//	sensorID    uint16
+//	frameLen    uint8
+//	frameID     [frameLen]byte  система координат точек (например, base_link)
+//	seq         uint32
+//	captureTime int64    время съёмки, наносекунды Unix
+//	pointCount  uint32   число точек в кадре до компрессии
+//	codecCount  uint8
+//	codecs      [codecCount]uint8  в порядке применения при сжатии
+//	resolution  float32  наибольшая ошибка координат после квантования, метры; 0 — координаты без потерь
+//	flags       uint8    наличие необязательных секций (FrameHas*)
+//	payloadLen  uint32
+//	payload     [payloadLen]byte  облако точек; пусто без FrameHasPoints
+//	obstacles   если FrameHasObstacles: count uint16, затем count записей по 48 байт:
+//	            center, size [3]float32, yaw float32, centroid [3]float32, points uint32
+//	grid        если FrameHasGrid: gridLen uint32, затем сетка высот (см. SerializeGrid)
+//	crc         uint32   CRC-32 (IEEE) всех предыдущих байт
+const (
+	FrameMagic   = "LDRF"
+	FrameVersion = 6
+
+	frameFixedSize = len(FrameMagic) + 1 + 1 + 2 + 1 + 4 + 8 + 4 + 1 + 4 + 1 + 4 + 4
+	obstacleSize   = 11*4 + 4
+)
+
+// Флаги необязательных секций кадра
+const (
+	FrameHasPoints uint8 = 1 << iota
+	FrameHasObstacles
+	FrameHasGrid
+)
+
+var (
+	ErrBadMagic           = errors.New("неверная сигнатура кадра")
+	ErrUnsupportedVersion = errors.New("неподдерживаемая версия кадра")
+	ErrBadChecksum        = errors.New("неверная контрольная сумма кадра")
+	ErrTruncatedFrame     = errors.New("кадр обрезан")
+)
+
+// CodecID идентифицирует ступень компрессии в заголовке кадра
+type CodecID uint8
+
+const (
+	CodecVoxel      CodecID = 1
+	CodecGzip       CodecID = 2
+	CodecQuantize   CodecID = 3
+	CodecOctree     CodecID = 4
+	CodecDelta      CodecID = 5
+	CodecRangeImage CodecID = 6
+	CodecZstd       CodecID = 7
+	CodecLZ4        CodecID = 8
+)
+
+func (c CodecID) String() string {
+	switch c {
+	case CodecVoxel:
+		return "voxel"
+	case CodecGzip:
+		return "gzip"
+	case CodecQuantize:
+		return "quantize"
+	case CodecOctree:
+		return "octree"
+	case CodecDelta:
+		return "delta"
+	case CodecRangeImage:
+		return "rangeimage"
+	case CodecZstd:
+		return "zstd"
+	case CodecLZ4:
+		return "lz4"
+	default:
+		return fmt.Sprintf("codec(%d)", uint8(c))
+	}
+}
+
+// FrameHeader описывает содержимое кадра
+type FrameHeader struct {
+	Version     uint8     `json:"version"`
+	VehicleID   string    `json:"vehicleID"`
+	SensorID    uint16    `json:"sensorID"`
+	FrameID     string    `json:"frameID"`
+	Seq         uint32    `json:"seq"`
+	CaptureTime time.Time `json:"captureTime"`
+	PointCount  uint32    `json:"pointCount"`
+	Codecs      []CodecID `json:"codecs"`
+	Resolution  float32   `json:"resolution"` // наибольшая ошибка координат после квантования, метры
+}
+
+// Frame — декодированный кадр облака точек
+type Frame struct {
+	Header    FrameHeader
+	Fields    PointFields    // необязательные поля, присутствующие в точках
+	Points    []Point        // nil, если кадр передан без облака точек
+	Obstacles []Obstacle     // nil, если кластеризация не выполнялась
+	Tracks    []Track        // nil, если сопровождение препятствий отключено
+	Grid      *OccupancyGrid // nil, если сетка высот не передавалась
+	Pose      *Pose          // nil, если одометрия отключена или кадр без облака точек
+	MapUpdate *MapUpdate     // nil, если карта не ведётся или кадр в неё не добавлен
+	Levels    *OctreeLevels  // облака пониженной детализации; nil без кодека octree
+}
+
+// FrameSections — необязательные секции кадра помимо облака точек
+type FrameSections struct {
+	Obstacles []Obstacle // записываются, если не nil (пустой список — препятствий не найдено)
+	Grid      *OccupancyGrid
+}
+
+// EncodeFrame упаковывает полезную нагрузку и необязательные секции в кадр с заголовком
+// и контрольной суммой. payload nil — кадр без облака точек.
+func EncodeFrame(h FrameHeader, payload []byte, sections FrameSections) ([]byte, error) {
+	obstacles := sections.Obstacles
+	if len(h.VehicleID) > 255 {
+		return nil, fmt.Errorf("слишком длинный идентификатор ТС: %d байт", len(h.VehicleID))
+	}
+	if len(h.FrameID) > 255 {
+		return nil, fmt.Errorf("слишком длинный идентификатор системы координат: %d байт", len(h.FrameID))
+	}
+	if len(h.Codecs) > 255 {
+		return nil, fmt.Errorf("слишком много ступеней компрессии: %d", len(h.Codecs))
+	}
+	if len(obstacles) > math.MaxUint16 {
+		return nil, fmt.Errorf("слишком много препятствий: %d", len(obstacles))
+	}
+	var flags uint8
+	if payload != nil {
+		flags |= FrameHasPoints
+	}
+	if obstacles != nil {
+		flags |= FrameHasObstacles
+	}
+	var grid []byte
+	if sections.Grid != nil {
+		flags |= FrameHasGrid
+		grid = SerializeGrid(sections.Grid)
+	}
+	buf := make([]byte, 0, frameFixedSize+len(h.VehicleID)+len(h.FrameID)+len(h.Codecs)+len(payload)+2+len(obstacles)*obstacleSize+4+len(grid))
+	buf = append(buf, FrameMagic...)
+	buf = append(buf, FrameVersion)
+	buf = append(buf, byte(len(h.VehicleID)))
+	buf = append(buf, h.VehicleID...)
+	buf = binary.LittleEndian.AppendUint16(buf, h.SensorID)
+	buf = append(buf, byte(len(h.FrameID)))
+	buf = append(buf, h.FrameID...)
+	buf = binary.LittleEndian.AppendUint32(buf, h.Seq)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(h.CaptureTime.UnixNano()))
+	buf = binary.LittleEndian.AppendUint32(buf, h.PointCount)
+	buf = append(buf, byte(len(h.Codecs)))
+	for _, c := range h.Codecs {
+		buf = append(buf, byte(c))
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(h.Resolution))
+	buf = append(buf, flags)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(payload)))
+	buf = append(buf, payload...)
+	if flags&FrameHasObstacles != 0 {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(obstacles)))
+		for _, o := range obstacles {
+			for _, v := range [...]float32{
+				o.Center[0], o.Center[1], o.Center[2],
+				o.Size[0], o.Size[1], o.Size[2],
+				o.Yaw,
+				o.Centroid[0], o.Centroid[1], o.Centroid[2],
+			} {
+				buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(v))
+			}
+			buf = binary.LittleEndian.AppendUint32(buf, o.Points)
+		}
+	}
+	if flags&FrameHasGrid != 0 {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(grid)))
+		buf = append(buf, grid...)
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+	return buf, nil
+}
+
+// DecodeFrame проверяет сигнатуру, версию и контрольную сумму кадра и возвращает его
+// заголовок, полезную нагрузку (nil, если кадр без облака точек) и необязательные секции
+func DecodeFrame(data []byte) (FrameHeader, []byte, FrameSections, error) {
+	var h FrameHeader
+	var sections FrameSections
+	if len(data) < frameFixedSize {
+		return h, nil, sections, ErrTruncatedFrame
+	}
+	if string(data[:len(FrameMagic)]) != FrameMagic {
+		return h, nil, sections, ErrBadMagic
+	}
+	h.Version = data[len(FrameMagic)]
+	if h.Version != FrameVersion {
+		return h, nil, sections, fmt.Errorf("%w: %d", ErrUnsupportedVersion, h.Version)
+	}
+	body := data[:len(data)-4]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(data[len(data)-4:]) {
+		return h, nil, sections, ErrBadChecksum
+	}
+
+	r := frameReader{buf: body, pos: len(FrameMagic) + 1}
+	vehicleLen := int(r.uint8())
+	h.VehicleID = string(r.bytes(vehicleLen))
+	h.SensorID = r.uint16()
+	frameLen := int(r.uint8())
+	h.FrameID = string(r.bytes(frameLen))
+	h.Seq = r.uint32()
+	h.CaptureTime = time.Unix(0, int64(r.uint64()))
+	h.PointCount = r.uint32()
+	codecCount := int(r.uint8())
+	codecs := r.bytes(codecCount)
+	h.Codecs = make([]CodecID, len(codecs))
+	for i, c := range codecs {
+		h.Codecs[i] = CodecID(c)
+	}
+	h.Resolution = math32.Float32frombits(r.uint32())
+	flags := r.uint8()
+	payloadLen := int(r.uint32())
+	payload := r.bytes(payloadLen)
+	if flags&FrameHasPoints == 0 {
+		payload = nil
+	}
+	if flags&FrameHasObstacles != 0 {
+		sections.Obstacles = make([]Obstacle, r.uint16())
+		for i := range sections.Obstacles {
+			o := &sections.Obstacles[i]
+			for _, v := range [...]*float32{
+				&o.Center[0], &o.Center[1], &o.Center[2],
+				&o.Size[0], &o.Size[1], &o.Size[2],
+				&o.Yaw,
+				&o.Centroid[0], &o.Centroid[1], &o.Centroid[2],
+			} {
+				*v = math32.Float32frombits(r.uint32())
+			}
+			o.Points = r.uint32()
+			o.Distance = math32.Sqrt(o.Centroid[0]*o.Centroid[0] + o.Centroid[1]*o.Centroid[1] + o.Centroid[2]*o.Centroid[2])
+		}
+	}
+	var grid []byte
+	if flags&FrameHasGrid != 0 {
+		grid = r.bytes(int(r.uint32()))
+	}
+	if r.err != nil || r.pos != len(body) {
+		return h, nil, FrameSections{}, ErrTruncatedFrame
+	}
+	if grid != nil {
+		g, err := DeserializeGrid(grid)
+		if err != nil {
+			return h, nil, FrameSections{}, err
+		}
+		sections.Grid = g
+	}
+	return h, payload, sections, nil
+}
+
+// FrameSeq читает номер кадра из заголовка без проверки контрольной суммы и разбора секций;
+// нужен, чтобы упорядочить принятые кадры до передачи в Rx
+func FrameSeq(data []byte) (uint32, error) {
+	if len(data) < frameFixedSize {
+		return 0, ErrTruncatedFrame
+	}
+	if string(data[:len(FrameMagic)]) != FrameMagic {
+		return 0, ErrBadMagic
+	}
+	if version := data[len(FrameMagic)]; version != FrameVersion {
+		return 0, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	r := frameReader{buf: data, pos: len(FrameMagic) + 1}
+	r.bytes(int(r.uint8()))
+	r.uint16()
+	r.bytes(int(r.uint8()))
+	seq := r.uint32()
+	return seq, r.err
+}
+
+// frameReader последовательно читает поля заголовка, запоминая первую ошибку
+type frameReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *frameReader) bytes(n int) []byte {
+	if r.err != nil || r.pos+n > len(r.buf) {
+		r.err = ErrTruncatedFrame
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *frameReader) uint8() uint8 {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *frameReader) uint16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (r *frameReader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *frameReader) uint64() uint64 {
+	b := r.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
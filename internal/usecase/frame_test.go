@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testFrameHeader() FrameHeader {
+	return FrameHeader{
+		VehicleID:   "truck-07",
+		SensorID:    2,
+		FrameID:     "base_link",
+		Seq:         4242,
+		CaptureTime: time.Unix(1700000000, 123456789),
+		PointCount:  30000,
+		Codecs:      []CodecID{CodecQuantize, CodecGzip},
+		Resolution:  0.005,
+	}
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	builder, err := NewGridBuilder(GridConfig{CellSize: 0.5, Extent: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	grid := builder.Build([]Point{{X: 0.1, Y: 0.2, Z: 0.3}})
+	obstacles := []Obstacle{{
+		Center:   [3]float32{5, -2, 0.8},
+		Size:     [3]float32{4.5, 1.8, 1.6},
+		Yaw:      0.3,
+		Centroid: [3]float32{3, 4, 0},
+		Points:   120,
+	}}
+	payload := []byte("облако точек")
+	h := testFrameHeader()
+	data, err := EncodeFrame(h, payload, FrameSections{Obstacles: obstacles, Grid: grid})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, gotPayload, sections, err := DecodeFrame(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Version = FrameVersion
+	if !reflect.DeepEqual(got, h) || !got.CaptureTime.Equal(h.CaptureTime) {
+		t.Errorf("заголовок %+v, ожидалось %+v", got, h)
+	}
+	if !bytes.Equal(gotPayload, payload) {
+		t.Errorf("полезная нагрузка %q", gotPayload)
+	}
+	if len(sections.Obstacles) != 1 {
+		t.Fatalf("препятствий %d", len(sections.Obstacles))
+	}
+	o := sections.Obstacles[0]
+	if o.Center != obstacles[0].Center || o.Size != obstacles[0].Size || o.Yaw != 0.3 || o.Points != 120 || o.Distance != 5 {
+		t.Errorf("препятствие %+v", o)
+	}
+	if sections.Grid == nil || sections.Grid.Width != grid.Width || sections.Grid.Cell(20, 20).Hits != 1 {
+		t.Errorf("сетка высот %+v", sections.Grid)
+	}
+}
+
+func TestFrameOptionalSections(t *testing.T) {
+	// Кадр только с препятствиями: облако и сетка отсутствуют, пустой список препятствий сохраняется
+	data, err := EncodeFrame(testFrameHeader(), nil, FrameSections{Obstacles: []Obstacle{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, payload, sections, err := DecodeFrame(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload != nil || sections.Grid != nil || sections.Obstacles == nil || len(sections.Obstacles) != 0 {
+		t.Fatalf("payload %v, секции %+v", payload, sections)
+	}
+
+	data, err = EncodeFrame(testFrameHeader(), []byte{}, FrameSections{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, payload, sections, err = DecodeFrame(data)
+	if err != nil || payload == nil || len(payload) != 0 || sections.Obstacles != nil {
+		t.Fatalf("пустое облако: payload %v, секции %+v, %v", payload, sections, err)
+	}
+}
+
+func TestFrameRejectsCorruption(t *testing.T) {
+	data, err := EncodeFrame(testFrameHeader(), []byte{1, 2, 3, 4}, FrameSections{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := func(edit func([]byte) []byte) []byte {
+		return edit(bytes.Clone(data))
+	}
+	// resign пересчитывает контрольную сумму, чтобы проверить разбор самого заголовка
+	resign := func(b []byte) []byte {
+		binary.LittleEndian.PutUint32(b[len(b)-4:], crc32.ChecksumIEEE(b[:len(b)-4]))
+		return b
+	}
+	cases := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"сигнатура", corrupt(func(b []byte) []byte { b[0] = 'X'; return b }), ErrBadMagic},
+		{"версия", corrupt(func(b []byte) []byte { b[4] = FrameVersion + 1; return resign(b) }), ErrUnsupportedVersion},
+		{"полезная нагрузка", corrupt(func(b []byte) []byte { b[len(b)-6] ^= 0xFF; return b }), ErrBadChecksum},
+		{"контрольная сумма", corrupt(func(b []byte) []byte { b[len(b)-1] ^= 1; return b }), ErrBadChecksum},
+		{"обрезан", data[:frameFixedSize-1], ErrTruncatedFrame},
+		{"обрезана нагрузка", corrupt(func(b []byte) []byte { return resign(append(b[:len(b)-6], 0, 0, 0, 0)) }), ErrTruncatedFrame},
+		{"лишние байты", corrupt(func(b []byte) []byte { return resign(append(b, 0, 0, 0, 0)) }), ErrTruncatedFrame},
+	}
+	for _, c := range cases {
+		if _, _, _, err := DecodeFrame(c.data); !errors.Is(err, c.want) {
+			t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.want)
+		}
+	}
+}
+
+func TestEncodeFrameRejectsLongIDs(t *testing.T) {
+	h := testFrameHeader()
+	h.VehicleID = string(make([]byte, 256))
+	if _, err := EncodeFrame(h, nil, FrameSections{}); err == nil {
+		t.Error("идентификатор ТС длиннее 255 байт принят")
+	}
+	h = testFrameHeader()
+	h.FrameID = string(make([]byte, 256))
+	if _, err := EncodeFrame(h, nil, FrameSections{}); err == nil {
+		t.Error("идентификатор системы координат длиннее 255 байт принят")
+	}
+}
@@ -0,0 +1,242 @@
+package usecase
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// FusedSensorID записывается в заголовок кадра, собранного из нескольких сенсоров;
+// источник каждой точки передаётся в поле sensor
+const FusedSensorID uint16 = 0xFFFF
+
+// FusionConfig задаёт выравнивание кадров разных сенсоров по времени
+type FusionConfig struct {
+	MaxSkew time.Duration // наибольшая разница времени съёмки кадров, объединяемых в один
+	MaxWait time.Duration // сколько ждать кадр отставшего сенсора, по времени съёмки более новых кадров
+}
+
+func DefaultFusionConfig() FusionConfig {
+	return FusionConfig{
+		MaxSkew: 50 * time.Millisecond,
+		MaxWait: 300 * time.Millisecond,
+	}
+}
+
+// clockJump — изменение расхождения часов сенсора и хоста, после которого оценка
+// расхождения начинается заново (сенсор получил или потерял PPS, перезапустился)
+const clockJump = time.Second
+
+// FusedFrame — кадр, объединяющий кадры нескольких сенсоров в системе координат ТС
+type FusedFrame struct {
+	CaptureTime time.Time // время съёмки самого раннего из объединённых кадров; по часам хоста, если часы сенсоров расходятся
+	ReceivedAt  time.Time // время съёмки самого раннего кадра по часам хоста; нулевое, если время приёма неизвестно
+	Points      []Point   // время точек отсчитывается от CaptureTime
+	Sensors     []uint8   // сенсоры, кадры которых вошли в объединённый
+	Complete    bool      // вошли кадры всех сенсоров
+}
+
+// FrameFuserStats — счётчики объединённых кадров
+type FrameFuserStats struct {
+	Frames   uint64 // выданные кадры
+	Partial  uint64 // из них без кадров части сенсоров
+	Unsynced uint64 // из них выровненные по часам хоста, потому что часы сенсоров расходятся
+}
+
+// FrameFuser выравнивает кадры сенсоров по времени съёмки и объединяет их.
+// Кадры каждого сенсора должны поступать в порядке времени съёмки.
+//
+// Метка времени Velodyne — микросекунды от начала часа по часам сенсора. Без PPS часы
+// каждого сенсора идут от включения, и метки разных сенсоров несравнимы. Поэтому для каждого
+// сенсора оценивается расхождение его часов с часами хоста по времени приёма кадров; если
+// расхождения сенсоров отличаются больше чем на MaxSkew, кадры выравниваются по часам хоста.
+type FrameFuser struct {
+	cfg      FusionConfig
+	sensors  []uint8
+	queues   map[uint8][]fuserFrame
+	offsets  map[uint8]time.Duration // сглаженное расхождение часов сенсора с часами хоста
+	newest   time.Time               // самое позднее время среди поступивших кадров
+	unsynced bool                    // часы сенсоров расходятся, кадры выравниваются по часам хоста
+
+	frames         atomic.Uint64
+	partial        atomic.Uint64
+	unsyncedFrames atomic.Uint64
+}
+
+// fuserFrame — кадр в очереди сенсора со временем съёмки, переведённым на часы хоста
+type fuserFrame struct {
+	AssembledFrame
+	hostTime time.Time
+}
+
+func NewFrameFuser(sensors []uint8, cfg FusionConfig) *FrameFuser {
+	return &FrameFuser{
+		cfg:     cfg,
+		sensors: sensors,
+		queues:  make(map[uint8][]fuserFrame, len(sensors)),
+		offsets: make(map[uint8]time.Duration, len(sensors)),
+	}
+}
+
+// Add принимает кадр сенсора и возвращает объединённые кадры, которые стали готовы
+func (f *FrameFuser) Add(sensor uint8, frame AssembledFrame) []FusedFrame {
+	queued := fuserFrame{AssembledFrame: frame, hostTime: frame.CaptureTime}
+	if !frame.ReceivedAt.IsZero() {
+		offset := frame.CaptureTime.Sub(frame.ReceivedAt)
+		estimate, ok := f.offsets[sensor]
+		if diff := offset - estimate; !ok || diff > clockJump || diff < -clockJump {
+			estimate = offset
+		} else {
+			// Задержка приёма пакетов шумит; расхождение часов меняется медленно
+			estimate += diff / 8
+		}
+		f.offsets[sensor] = estimate
+		queued.hostTime = frame.CaptureTime.Add(-estimate)
+		f.checkClocks()
+	}
+	f.queues[sensor] = append(f.queues[sensor], queued)
+	if t := f.time(queued); t.After(f.newest) {
+		f.newest = t
+	}
+	return f.drain(false)
+}
+
+// checkClocks сравнивает расхождения часов сенсоров с часами хоста и переключает выравнивание
+func (f *FrameFuser) checkClocks() {
+	if len(f.offsets) < 2 {
+		return
+	}
+	first := true
+	var lo, hi time.Duration
+	for _, offset := range f.offsets {
+		if first || offset < lo {
+			lo = offset
+		}
+		if first || offset > hi {
+			hi = offset
+		}
+		first = false
+	}
+	unsynced := hi-lo > f.cfg.MaxSkew
+	if unsynced == f.unsynced {
+		return
+	}
+	if unsynced {
+		log.Printf("Fusion: часы сенсоров расходятся на %v (допустимо %v), кадры выравниваются по времени приёма; проверьте PPS",
+			hi-lo, f.cfg.MaxSkew)
+	} else {
+		log.Printf("Fusion: часы сенсоров согласованы, кадры выравниваются по времени съёмки")
+	}
+	f.unsynced = unsynced
+	f.newest = time.Time{}
+	for _, q := range f.queues {
+		for _, frame := range q {
+			if t := f.time(frame); t.After(f.newest) {
+				f.newest = t
+			}
+		}
+	}
+}
+
+// time — время кадра, по которому он выравнивается с кадрами других сенсоров
+func (f *FrameFuser) time(frame fuserFrame) time.Time {
+	if f.unsynced {
+		return frame.hostTime
+	}
+	return frame.CaptureTime
+}
+
+// Flush объединяет все оставшиеся кадры, не дожидаясь отставших сенсоров
+func (f *FrameFuser) Flush() []FusedFrame {
+	return f.drain(true)
+}
+
+func (f *FrameFuser) drain(flush bool) []FusedFrame {
+	var done []FusedFrame
+	for {
+		frame, ok := f.next(flush)
+		if !ok {
+			return done
+		}
+		done = append(done, frame)
+	}
+}
+
+// next объединяет самый ранний из ожидающих кадров с ближайшими по времени кадрами
+// других сенсоров. Если у какого-то сенсора ещё нет кадра и время ожидания не вышло,
+// объединение откладывается.
+func (f *FrameFuser) next(flush bool) (FusedFrame, bool) {
+	var anchor time.Time
+	found := false
+	for _, id := range f.sensors {
+		if q := f.queues[id]; len(q) > 0 && (!found || f.time(q[0]).Before(anchor)) {
+			anchor, found = f.time(q[0]), true
+		}
+	}
+	if !found {
+		return FusedFrame{}, false
+	}
+	expired := flush || f.newest.Sub(anchor) > f.cfg.MaxWait
+
+	group := make([]uint8, 0, len(f.sensors))
+	for _, id := range f.sensors {
+		q := f.queues[id]
+		if len(q) > 0 && f.time(q[0]).Sub(anchor) <= f.cfg.MaxSkew {
+			group = append(group, id)
+			continue
+		}
+		// Если у сенсора уже есть более поздний кадр, парного кадра от него не будет
+		if len(q) == 0 && !expired {
+			return FusedFrame{}, false
+		}
+	}
+
+	fused := FusedFrame{
+		CaptureTime: anchor,
+		Sensors:     group,
+		Complete:    len(group) == len(f.sensors),
+	}
+	if len(group) == 1 {
+		fused.Points = f.queues[group[0]][0].Points
+	} else {
+		total := 0
+		for _, id := range group {
+			total += len(f.queues[id][0].Points)
+		}
+		fused.Points = make([]Point, 0, total)
+		for _, id := range group {
+			frame := f.queues[id][0]
+			// Время точек переводится в отсчёт от начала объединённого кадра
+			shift := uint32(f.time(frame).Sub(anchor).Microseconds())
+			for _, pt := range frame.Points {
+				pt.Time += shift
+				fused.Points = append(fused.Points, pt)
+			}
+		}
+	}
+	for _, id := range group {
+		frame := f.queues[id][0]
+		if !frame.ReceivedAt.IsZero() && (fused.ReceivedAt.IsZero() || frame.hostTime.Before(fused.ReceivedAt)) {
+			fused.ReceivedAt = frame.hostTime
+		}
+		f.queues[id] = f.queues[id][1:]
+	}
+
+	f.frames.Add(1)
+	if !fused.Complete {
+		f.partial.Add(1)
+	}
+	if f.unsynced {
+		f.unsyncedFrames.Add(1)
+	}
+	return fused, true
+}
+
+// Stats возвращает счётчики объединённых кадров; безопасно вызывать из другой горутины
+func (f *FrameFuser) Stats() FrameFuserStats {
+	return FrameFuserStats{
+		Frames:   f.frames.Load(),
+		Partial:  f.partial.Load(),
+		Unsynced: f.unsyncedFrames.Load(),
+	}
+}
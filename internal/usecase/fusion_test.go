@@ -0,0 +1,157 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	fusionStart  = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	fusionConfig = FusionConfig{MaxSkew: 50 * time.Millisecond, MaxWait: 300 * time.Millisecond}
+)
+
+// fusionFrame — кадр сенсора из двух точек, снятый в момент capture; X точек — номер сенсора
+func fusionFrame(sensor uint8, capture, received time.Time) AssembledFrame {
+	return AssembledFrame{
+		CaptureTime: capture,
+		ReceivedAt:  received,
+		Points:      []Point{{X: float32(sensor), Time: 0}, {X: float32(sensor), Time: 1000}},
+		Complete:    true,
+	}
+}
+
+func fusionAt(ms int) time.Time {
+	return fusionStart.Add(time.Duration(ms) * time.Millisecond)
+}
+
+func TestFrameFuserPairsFrames(t *testing.T) {
+	f := NewFrameFuser([]uint8{0, 1}, fusionConfig)
+	for i := 0; i < 3; i++ {
+		if fused := f.Add(0, fusionFrame(0, fusionAt(100*i), time.Time{})); len(fused) != 0 {
+			t.Fatalf("кадр %d выдан без кадра второго сенсора: %+v", i, fused)
+		}
+		fused := f.Add(1, fusionFrame(1, fusionAt(100*i+10), time.Time{}))
+		if len(fused) != 1 {
+			t.Fatalf("кадр %d: выдано %d объединённых кадров, ожидался 1", i, len(fused))
+		}
+		frame := fused[0]
+		if !frame.Complete || !frame.CaptureTime.Equal(fusionAt(100*i)) || len(frame.Sensors) != 2 || len(frame.Points) != 4 {
+			t.Fatalf("кадр %d: %+v", i, frame)
+		}
+		// Время точек второго сенсора отсчитывается от начала объединённого кадра
+		want := []Point{{X: 0, Time: 0}, {X: 0, Time: 1000}, {X: 1, Time: 10000}, {X: 1, Time: 11000}}
+		for j, pt := range frame.Points {
+			if pt != want[j] {
+				t.Errorf("кадр %d: точка %d %+v, ожидалась %+v", i, j, pt, want[j])
+			}
+		}
+	}
+	if stats := f.Stats(); stats.Frames != 3 || stats.Partial != 0 || stats.Unsynced != 0 {
+		t.Errorf("статистика %+v", stats)
+	}
+}
+
+func TestFrameFuserWaitsForLateSensor(t *testing.T) {
+	f := NewFrameFuser([]uint8{0, 1}, fusionConfig)
+	// Второй сенсор молчит: кадр ждёт его, пока более новые кадры не уйдут дальше MaxWait
+	for ms := 0; ms <= 300; ms += 100 {
+		if fused := f.Add(0, fusionFrame(0, fusionAt(ms), time.Time{})); len(fused) != 0 {
+			t.Fatalf("кадр %d мс выдан до истечения ожидания", ms)
+		}
+	}
+	fused := f.Add(0, fusionFrame(0, fusionAt(400), time.Time{}))
+	if len(fused) != 1 || fused[0].Complete || !fused[0].CaptureTime.Equal(fusionAt(0)) || len(fused[0].Sensors) != 1 {
+		t.Fatalf("по истечении ожидания выдано %+v", fused)
+	}
+
+	rest := f.Flush()
+	if len(rest) != 4 {
+		t.Fatalf("Flush выдал %d кадров, ожидалось 4", len(rest))
+	}
+	for i, frame := range rest {
+		if frame.Complete || !frame.CaptureTime.Equal(fusionAt(100*(i+1))) {
+			t.Errorf("кадр %d после Flush: %+v", i, frame)
+		}
+	}
+	if stats := f.Stats(); stats.Frames != 5 || stats.Partial != 5 {
+		t.Errorf("статистика %+v", stats)
+	}
+}
+
+func TestFrameFuserSensorDropout(t *testing.T) {
+	f := NewFrameFuser([]uint8{0, 1}, fusionConfig)
+	var fused []FusedFrame
+	for ms := 0; ms < 200; ms += 100 {
+		fused = append(fused, f.Add(0, fusionFrame(0, fusionAt(ms), time.Time{}))...)
+		fused = append(fused, f.Add(1, fusionFrame(1, fusionAt(ms+5), time.Time{}))...)
+	}
+	// Второй сенсор пропал: кадры первого выдаются с задержкой MaxWait
+	for ms := 200; ms <= 700; ms += 100 {
+		fused = append(fused, f.Add(0, fusionFrame(0, fusionAt(ms), time.Time{}))...)
+	}
+	if len(fused) != 4 {
+		t.Fatalf("до Flush выдано %d кадров, ожидалось 4", len(fused))
+	}
+	fused = append(fused, f.Flush()...)
+	if len(fused) != 8 {
+		t.Fatalf("выдано %d кадров, ожидалось 8 (2 полных и 6 без второго сенсора)", len(fused))
+	}
+	for i, frame := range fused {
+		if frame.Complete != (i < 2) || !frame.CaptureTime.Equal(fusionAt(100*i)) {
+			t.Errorf("кадр %d: полный %v, время %v", i, frame.Complete, frame.CaptureTime.Sub(fusionStart))
+		}
+	}
+
+	// Кадр второго сенсора, пропустивший пару, не задерживает первый: его пары уже не будет
+	f = NewFrameFuser([]uint8{0, 1}, fusionConfig)
+	f.Add(0, fusionFrame(0, fusionAt(0), time.Time{}))
+	fused = f.Add(1, fusionFrame(1, fusionAt(100), time.Time{}))
+	if len(fused) != 1 || fused[0].Complete || fused[0].Sensors[0] != 0 {
+		t.Fatalf("кадр без пары выдан как %+v", fused)
+	}
+}
+
+func TestFrameFuserAlignsUnsyncedClocks(t *testing.T) {
+	f := NewFrameFuser([]uint8{0, 1}, fusionConfig)
+	// Часы второго сенсора без PPS ушли на 17 минут; кадры приходят через 1–3 мс после съёмки
+	drift := 17 * time.Minute
+	var fused []FusedFrame
+	for ms := 0; ms < 500; ms += 100 {
+		fused = append(fused, f.Add(0, fusionFrame(0, fusionAt(ms), fusionAt(ms+1)))...)
+		fused = append(fused, f.Add(1, fusionFrame(1, fusionAt(ms+10).Add(drift), fusionAt(ms+13)))...)
+	}
+	if len(fused) != 5 {
+		t.Fatalf("выдано %d кадров, ожидалось 5", len(fused))
+	}
+	for i, frame := range fused {
+		if !frame.Complete {
+			t.Errorf("кадр %d не объединён: сенсоры %v", i, frame.Sensors)
+		}
+		// Время объединённого кадра — по часам хоста
+		if d := frame.CaptureTime.Sub(fusionAt(100 * i)); d < 0 || d > 5*time.Millisecond {
+			t.Errorf("кадр %d: время %v от начала", i, frame.CaptureTime.Sub(fusionStart))
+		}
+		if !frame.ReceivedAt.Equal(frame.CaptureTime) {
+			t.Errorf("кадр %d: время по часам хоста %v, время кадра %v", i, frame.ReceivedAt, frame.CaptureTime)
+		}
+	}
+	if stats := f.Stats(); stats.Unsynced != 5 || stats.Partial != 0 {
+		t.Errorf("статистика %+v", stats)
+	}
+
+	// Часы с PPS совпадают; разброс задержки приёма не переключает выравнивание
+	f = NewFrameFuser([]uint8{0, 1}, fusionConfig)
+	fused = nil
+	for ms := 0; ms < 500; ms += 100 {
+		fused = append(fused, f.Add(0, fusionFrame(0, fusionAt(ms), fusionAt(ms+1+ms/100)))...)
+		fused = append(fused, f.Add(1, fusionFrame(1, fusionAt(ms+10), fusionAt(ms+20-ms/100)))...)
+	}
+	if stats := f.Stats(); stats.Frames != 5 || stats.Unsynced != 0 || stats.Partial != 0 {
+		t.Errorf("статистика с PPS %+v", stats)
+	}
+	for i, frame := range fused {
+		if !frame.CaptureTime.Equal(fusionAt(100 * i)) {
+			t.Errorf("кадр %d с PPS: время %v от начала", i, frame.CaptureTime.Sub(fusionStart))
+		}
+	}
+}
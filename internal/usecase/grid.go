@@ -0,0 +1,197 @@
+package usecase
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/chewxy/math32"
+	"math"
+)
+
+// GridConfig задаёт сетку высот вокруг ТС
+type GridConfig struct {
+	CellSize float32 // сторона ячейки, метры
+	Extent   float32 // полуразмер сетки вокруг начала координат ТС, метры
+}
+
+func DefaultGridConfig() GridConfig {
+	return GridConfig{
+		CellSize: 0.2,
+		Extent:   50,
+	}
+}
+
+// GridCell — ячейка сетки: число точек и диапазон их высот в системе ТС
+type GridCell struct {
+	Hits uint16
+	MinZ float32
+	MaxZ float32
+}
+
+// OccupancyGrid — двумерная сетка занятости и высот (вид сверху) в системе координат ТС
+type OccupancyGrid struct {
+	CellSize float32
+	OriginX  float32 // x нижнего края ячеек столбца 0
+	OriginY  float32 // y нижнего края ячеек строки 0
+	Width    int     // число столбцов, вдоль оси x
+	Height   int     // число строк, вдоль оси y
+	Cells    []GridCell
+}
+
+// Cell возвращает ячейку столбца col строки row
+func (g *OccupancyGrid) Cell(col, row int) GridCell {
+	return g.Cells[row*g.Width+col]
+}
+
+const (
+	// gridHeightScale — высоты ячеек передаются в сантиметрах
+	gridHeightScale = 100
+	// maxGridSide ограничивает сторону сетки: размеры приходят по сети,
+	// и без ограничения повреждённый заголовок заставил бы выделить гигабайты
+	maxGridSide = 4096
+	// minGridCellBytes — наименьший размер записи занятой ячейки: два uvarint и две высоты
+	minGridCellBytes = 6
+)
+
+// GridBuilder строит сетку высот по точкам кадра
+type GridBuilder struct {
+	cfg  GridConfig
+	size int
+}
+
+func NewGridBuilder(cfg GridConfig) (*GridBuilder, error) {
+	if cfg.CellSize <= 0 || cfg.Extent <= 0 {
+		return nil, fmt.Errorf("размер ячейки и полуразмер сетки должны быть положительными")
+	}
+	size := int(math32.Ceil(2 * cfg.Extent / cfg.CellSize))
+	if size > maxGridSide {
+		return nil, fmt.Errorf("слишком много ячеек по стороне сетки: %d, не больше %d", size, maxGridSide)
+	}
+	return &GridBuilder{cfg: cfg, size: size}, nil
+}
+
+// Build раскладывает точки по ячейкам; точки за пределами сетки пропускаются
+func (b *GridBuilder) Build(points []Point) *OccupancyGrid {
+	g := &OccupancyGrid{
+		CellSize: b.cfg.CellSize,
+		OriginX:  -b.cfg.Extent,
+		OriginY:  -b.cfg.Extent,
+		Width:    b.size,
+		Height:   b.size,
+		Cells:    make([]GridCell, b.size*b.size),
+	}
+	for _, pt := range points {
+		col := int(math32.Floor((pt.X - g.OriginX) / g.CellSize))
+		row := int(math32.Floor((pt.Y - g.OriginY) / g.CellSize))
+		if col < 0 || col >= g.Width || row < 0 || row >= g.Height {
+			continue
+		}
+		cell := &g.Cells[row*g.Width+col]
+		if cell.Hits == 0 {
+			cell.MinZ, cell.MaxZ = pt.Z, pt.Z
+		} else {
+			cell.MinZ, cell.MaxZ = min(cell.MinZ, pt.Z), max(cell.MaxZ, pt.Z)
+		}
+		if cell.Hits < math.MaxUint16 {
+			cell.Hits++
+		}
+	}
+	return g
+}
+
+// SerializeGrid упаковывает сетку. Пустые ячейки не записываются: для каждой занятой
+// ячейки хранится число пустых перед ней, число точек и высоты в сантиметрах.
+//
+//	cellSize, originX, originY float32
+//	width, height              uint16
+//	occupied                   uvarint
+//	occupied раз: skip uvarint, hits uvarint, minZ int16, maxZ int16
+func SerializeGrid(g *OccupancyGrid) []byte {
+	occupied := 0
+	for _, cell := range g.Cells {
+		if cell.Hits > 0 {
+			occupied++
+		}
+	}
+	buf := make([]byte, 0, 16+binary.MaxVarintLen64+occupied*8)
+	buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(g.CellSize))
+	buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(g.OriginX))
+	buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(g.OriginY))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(g.Width))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(g.Height))
+	buf = binary.AppendUvarint(buf, uint64(occupied))
+	skip := 0
+	for _, cell := range g.Cells {
+		if cell.Hits == 0 {
+			skip++
+			continue
+		}
+		buf = binary.AppendUvarint(buf, uint64(skip))
+		buf = binary.AppendUvarint(buf, uint64(cell.Hits))
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(quantizeHeight(cell.MinZ)))
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(quantizeHeight(cell.MaxZ)))
+		skip = 0
+	}
+	return buf
+}
+
+func quantizeHeight(z float32) int16 {
+	return int16(max(math.MinInt16, min(math.MaxInt16, math32.Round(z*gridHeightScale))))
+}
+
+// DeserializeGrid восстанавливает сетку, упакованную SerializeGrid
+func DeserializeGrid(data []byte) (*OccupancyGrid, error) {
+	if len(data) < 16 {
+		return nil, fmt.Errorf("слишком короткие данные сетки: %d байт", len(data))
+	}
+	g := &OccupancyGrid{
+		CellSize: math32.Float32frombits(binary.LittleEndian.Uint32(data[0:])),
+		OriginX:  math32.Float32frombits(binary.LittleEndian.Uint32(data[4:])),
+		OriginY:  math32.Float32frombits(binary.LittleEndian.Uint32(data[8:])),
+		Width:    int(binary.LittleEndian.Uint16(data[12:])),
+		Height:   int(binary.LittleEndian.Uint16(data[14:])),
+	}
+	if !(g.CellSize > 0) || g.Width > maxGridSide || g.Height > maxGridSide {
+		return nil, fmt.Errorf("недопустимая сетка %dx%d с ячейкой %v м", g.Width, g.Height, g.CellSize)
+	}
+	data = data[16:]
+	uvarint := func() (uint64, bool) {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return 0, false
+		}
+		data = data[n:]
+		return v, true
+	}
+	occupied, ok := uvarint()
+	if !ok {
+		return nil, fmt.Errorf("повреждён заголовок сетки")
+	}
+	// Проверяем до выделения ячеек, что данных хватает на объявленное число занятых
+	if occupied > uint64(g.Width*g.Height) || occupied > uint64(len(data)/minGridCellBytes) {
+		return nil, fmt.Errorf("объявлено %d занятых ячеек в сетке %dx%d при %d байт данных", occupied, g.Width, g.Height, len(data))
+	}
+	g.Cells = make([]GridCell, g.Width*g.Height)
+	pos := uint64(0)
+	for range occupied {
+		skip, ok1 := uvarint()
+		hits, ok2 := uvarint()
+		if !ok1 || !ok2 || len(data) < 4 || hits == 0 || hits > math.MaxUint16 {
+			return nil, fmt.Errorf("повреждены ячейки сетки")
+		}
+		pos += skip
+		if pos >= uint64(len(g.Cells)) {
+			return nil, fmt.Errorf("ячейка за пределами сетки %dx%d", g.Width, g.Height)
+		}
+		g.Cells[pos] = GridCell{
+			Hits: uint16(hits),
+			MinZ: float32(int16(binary.LittleEndian.Uint16(data[0:]))) / gridHeightScale,
+			MaxZ: float32(int16(binary.LittleEndian.Uint16(data[2:]))) / gridHeightScale,
+		}
+		data = data[4:]
+		pos++
+	}
+	if len(data) != 0 {
+		return nil, fmt.Errorf("лишние %d байт после ячеек сетки", len(data))
+	}
+	return g, nil
+}
@@ -0,0 +1,70 @@
+package usecase
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestGridRoundTrip(t *testing.T) {
+	b, err := NewGridBuilder(GridConfig{CellSize: 0.5, Extent: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := []Point{
+		{X: -9.9, Y: -9.9, Z: -1.8},
+		{X: 0.1, Y: 0.2, Z: 0.3},
+		{X: 0.2, Y: 0.1, Z: 1.75},
+		{X: 9.9, Y: 5, Z: 2},
+		{X: 10.1, Y: 0, Z: 5}, // за пределами сетки
+	}
+	g := b.Build(points)
+	if g.Width != 40 || g.Height != 40 {
+		t.Fatalf("сетка %dx%d", g.Width, g.Height)
+	}
+	if cell := g.Cell(20, 20); cell.Hits != 2 || cell.MinZ != 0.3 || cell.MaxZ != 1.75 {
+		t.Errorf("ячейка (20, 20): %+v", cell)
+	}
+
+	decoded, err := DeserializeGrid(SerializeGrid(g))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Width != g.Width || decoded.Height != g.Height || decoded.OriginX != -10 || decoded.CellSize != 0.5 {
+		t.Fatalf("заголовок %+v", decoded)
+	}
+	hits := 0
+	for i, cell := range g.Cells {
+		got := decoded.Cells[i]
+		hits += int(got.Hits)
+		if got.Hits != cell.Hits || abs32(got.MinZ-cell.MinZ) > 0.005 || abs32(got.MaxZ-cell.MaxZ) > 0.005 {
+			t.Errorf("ячейка %d: %+v, ожидалось %+v", i, got, cell)
+		}
+	}
+	if hits != 4 {
+		t.Errorf("точек в сетке %d, ожидалось 4", hits)
+	}
+}
+
+func TestDeserializeGridRejectsOversizedHeader(t *testing.T) {
+	header := func(width, height uint16, occupied uint64) []byte {
+		buf := binary.LittleEndian.AppendUint32(nil, 0x3e4ccccd) // 0.2
+		buf = binary.LittleEndian.AppendUint64(buf, 0)
+		buf = binary.LittleEndian.AppendUint16(buf, width)
+		buf = binary.LittleEndian.AppendUint16(buf, height)
+		return binary.AppendUvarint(buf, occupied)
+	}
+	for name, data := range map[string][]byte{
+		"сторона больше предела":     header(65535, 65535, 0),
+		"занятых больше, чем данных": header(100, 100, 1000),
+		"занятых больше, чем ячеек":  append(header(2, 2, 5), make([]byte, 5*minGridCellBytes)...),
+		"нулевой размер ячейки":      append(make([]byte, 12), header(10, 10, 0)[12:]...),
+		"короче заголовка":           header(10, 10, 0)[:15],
+	} {
+		if _, err := DeserializeGrid(data); err == nil {
+			t.Errorf("%s: сетка принята", name)
+		}
+	}
+	if _, err := NewGridBuilder(GridConfig{CellSize: 0.01, Extent: 50}); err == nil {
+		t.Error("принята сетка больше предела")
+	}
+}
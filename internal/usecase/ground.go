@@ -0,0 +1,261 @@
+package usecase
+
+import (
+	"fmt"
+	"github.com/chewxy/math32"
+	"math/rand/v2"
+)
+
+// GroundMethod — алгоритм выделения поверхности дороги
+type GroundMethod string
+
+const (
+	// GroundRANSAC ищет плоскость дороги случайными выборками по три точки
+	GroundRANSAC GroundMethod = "ransac"
+	// GroundRing идёт по каждому азимутальному столбцу от нижнего кольца к верхнему
+	// и считает землёй точки, пока уклон между соседними кольцами мал
+	GroundRing GroundMethod = "ring"
+)
+
+// GroundMode — что делать с точками земли
+type GroundMode string
+
+const (
+	GroundDrop     GroundMode = "drop"     // удалять
+	GroundDecimate GroundMode = "decimate" // прореживать крупными вокселями и передавать с меткой
+	GroundLayer    GroundMode = "layer"    // передавать все с меткой отдельным слоем
+)
+
+// GroundConfig задаёт параметры сегментации земли
+type GroundConfig struct {
+	Method GroundMethod
+	Mode   GroundMode
+	// Ожидаемая высота дороги в системе координат ТС и наибольшее отклонение от неё, метры
+	GroundZ   float32
+	MaxHeight float32
+	// Наибольший уклон дороги, градусы
+	MaxSlope float32
+	// RANSAC: наибольшее расстояние точки земли до плоскости, метры, и число выборок
+	DistanceThreshold float32
+	Iterations        int
+	// GroundRing: число азимутальных столбцов на оборот
+	AzimuthBins int
+	// GroundDecimate: размер вокселя для точек земли, метры
+	DecimateVoxel float32
+}
+
+func DefaultGroundConfig() GroundConfig {
+	return GroundConfig{
+		Method:            GroundRing,
+		Mode:              GroundLayer,
+		MaxHeight:         1,
+		MaxSlope:          10,
+		DistanceThreshold: 0.15,
+		Iterations:        100,
+		AzimuthBins:       900,
+		DecimateVoxel:     0.5,
+	}
+}
+
+// ransacSamples — сколько точек-кандидатов проверяется при оценке каждой выборки RANSAC
+const ransacSamples = 2000
+
+// GroundSegmenter размечает точки дороги меткой LabelGround. Не безопасен для
+// одновременного использования: у каждого сенсора должен быть свой.
+type GroundSegmenter struct {
+	cfg GroundConfig
+	rng *rand.Rand
+
+	candidates []int32
+	columns    []int32 // начало каждого азимутального столбца в order
+	order      []int32
+}
+
+func NewGroundSegmenter(cfg GroundConfig) (*GroundSegmenter, error) {
+	switch cfg.Method {
+	case GroundRANSAC:
+		if cfg.Iterations <= 0 || cfg.DistanceThreshold <= 0 {
+			return nil, fmt.Errorf("RANSAC: число выборок и порог расстояния должны быть положительными")
+		}
+	case GroundRing:
+		if cfg.AzimuthBins <= 0 {
+			return nil, fmt.Errorf("число азимутальных столбцов должно быть положительным: %d", cfg.AzimuthBins)
+		}
+	default:
+		return nil, fmt.Errorf("неизвестный метод сегментации земли: %q", cfg.Method)
+	}
+	switch cfg.Mode {
+	case GroundDrop, GroundLayer:
+	case GroundDecimate:
+		if cfg.DecimateVoxel <= 0 {
+			return nil, fmt.Errorf("размер вокселя для земли должен быть положительным: %v", cfg.DecimateVoxel)
+		}
+	default:
+		return nil, fmt.Errorf("неизвестный режим передачи земли: %q", cfg.Mode)
+	}
+	if cfg.MaxSlope <= 0 || cfg.MaxSlope >= 90 || cfg.MaxHeight <= 0 {
+		return nil, fmt.Errorf("уклон должен быть в диапазоне (0, 90) градусов, а допуск высоты положительным")
+	}
+	return &GroundSegmenter{cfg: cfg, rng: rand.New(rand.NewPCG(1, 2))}, nil
+}
+
+// Fields возвращает поля, которые нужно передавать, чтобы метка земли дошла до оператора
+func (g *GroundSegmenter) Fields() PointFields {
+	if g.cfg.Mode == GroundDrop {
+		return 0
+	}
+	return FieldLabel
+}
+
+// Apply размечает землю и применяет режим передачи. origin — положение сенсора в системе ТС,
+// от него считаются азимутальные столбцы метода GroundRing. Исходный срез переиспользуется.
+func (g *GroundSegmenter) Apply(points []Point, origin [3]float32) []Point {
+	switch g.cfg.Method {
+	case GroundRANSAC:
+		g.labelRANSAC(points)
+	case GroundRing:
+		g.labelRing(points, origin)
+	}
+	if g.cfg.Mode == GroundLayer {
+		return points
+	}
+
+	kept := points[:0]
+	var ground []Point
+	for _, pt := range points {
+		if pt.Label != LabelGround {
+			kept = append(kept, pt)
+		} else if g.cfg.Mode == GroundDecimate {
+			ground = append(ground, pt)
+		}
+	}
+	if len(ground) > 0 {
+		kept = append(kept, VoxelDownsample(ground, g.cfg.DecimateVoxel)...)
+	}
+	return kept
+}
+
+// labelRANSAC ищет плоскость с наибольшим числом точек в пределах DistanceThreshold
+// среди плоскостей с уклоном не больше MaxSlope
+func (g *GroundSegmenter) labelRANSAC(points []Point) {
+	g.candidates = g.candidates[:0]
+	for i, pt := range points {
+		if math32.Abs(pt.Z-g.cfg.GroundZ) <= g.cfg.MaxHeight {
+			g.candidates = append(g.candidates, int32(i))
+		}
+	}
+	if len(g.candidates) < 3 {
+		return
+	}
+	// Для оценки выборок достаточно случайного подмножества кандидатов
+	samples := g.candidates
+	if len(samples) > ransacSamples {
+		g.rng.Shuffle(len(samples), func(i, j int) { samples[i], samples[j] = samples[j], samples[i] })
+		samples = samples[:ransacSamples]
+	}
+
+	minNormalZ := math32.Cos(g.cfg.MaxSlope * degreesToRad)
+	var best [4]float32
+	bestCount := 0
+	for range g.cfg.Iterations {
+		a := points[samples[g.rng.IntN(len(samples))]]
+		b := points[samples[g.rng.IntN(len(samples))]]
+		c := points[samples[g.rng.IntN(len(samples))]]
+		ux, uy, uz := b.X-a.X, b.Y-a.Y, b.Z-a.Z
+		vx, vy, vz := c.X-a.X, c.Y-a.Y, c.Z-a.Z
+		nx, ny, nz := uy*vz-uz*vy, uz*vx-ux*vz, ux*vy-uy*vx
+		norm := math32.Sqrt(nx*nx + ny*ny + nz*nz)
+		if norm < 1e-6 {
+			continue
+		}
+		nx, ny, nz = nx/norm, ny/norm, nz/norm
+		if nz < 0 {
+			nx, ny, nz = -nx, -ny, -nz
+		}
+		if nz < minNormalZ {
+			continue
+		}
+		plane := [4]float32{nx, ny, nz, -(nx*a.X + ny*a.Y + nz*a.Z)}
+		count := 0
+		for _, i := range samples {
+			if planeDistance(plane, points[i]) <= g.cfg.DistanceThreshold {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = plane, count
+		}
+	}
+	if bestCount == 0 {
+		return
+	}
+	for _, i := range g.candidates {
+		if planeDistance(best, points[i]) <= g.cfg.DistanceThreshold {
+			points[i].Label = LabelGround
+		}
+	}
+}
+
+func planeDistance(plane [4]float32, pt Point) float32 {
+	return math32.Abs(plane[0]*pt.X + plane[1]*pt.Y + plane[2]*pt.Z + plane[3])
+}
+
+// labelRing делит точки на азимутальные столбцы вокруг сенсора и проходит каждый столбец
+// снизу вверх по номеру кольца. Точка считается землёй, если уклон от последней точки земли
+// в столбце не больше MaxSlope, а высота не выходит за допуск. Столбец начинается
+// с точки дороги под сенсором.
+func (g *GroundSegmenter) labelRing(points []Point, origin [3]float32) {
+	bins := g.cfg.AzimuthBins
+	binOf := func(pt Point) int {
+		azimuth := math32.Atan2(pt.Y-origin[1], pt.X-origin[0])
+		return int((azimuth+math32.Pi)/(2*math32.Pi)*float32(bins)) % bins
+	}
+
+	// Сортировка подсчётом по столбцам, внутри столбца — вставками по кольцу
+	if cap(g.columns) < bins+1 {
+		g.columns = make([]int32, bins+1)
+	}
+	columns := g.columns[:bins+1]
+	clear(columns)
+	for _, pt := range points {
+		columns[binOf(pt)+1]++
+	}
+	for i := 1; i <= bins; i++ {
+		columns[i] += columns[i-1]
+	}
+	if cap(g.order) < len(points) {
+		g.order = make([]int32, len(points))
+	}
+	order := g.order[:len(points)]
+	next := make([]int32, bins)
+	copy(next, columns[:bins])
+	for i, pt := range points {
+		bin := binOf(pt)
+		order[next[bin]] = int32(i)
+		next[bin]++
+	}
+
+	maxSlope := math32.Tan(g.cfg.MaxSlope * degreesToRad)
+	for bin := range bins {
+		column := order[columns[bin]:columns[bin+1]]
+		for i := 1; i < len(column); i++ {
+			for j := i; j > 0 && points[column[j]].Ring < points[column[j-1]].Ring; j-- {
+				column[j], column[j-1] = column[j-1], column[j]
+			}
+		}
+
+		groundR, groundZ := float32(0), g.cfg.GroundZ
+		for _, i := range column {
+			pt := &points[i]
+			r := math32.Hypot(pt.X-origin[0], pt.Y-origin[1])
+			dz := math32.Abs(pt.Z - groundZ)
+			dr := r - groundR
+			ground := math32.Abs(pt.Z-g.cfg.GroundZ) <= g.cfg.MaxHeight &&
+				(dz <= g.cfg.DistanceThreshold || (dr > 0 && dz <= dr*maxSlope))
+			if ground {
+				pt.Label = LabelGround
+				groundR, groundZ = r, pt.Z
+			}
+		}
+	}
+}
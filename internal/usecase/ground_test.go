@@ -0,0 +1,151 @@
+package usecase
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// groundScene — оборот VLP-16 на высоте 1,8 м над ровной дорогой со стеной x = 8 м шириной 6 м
+// и высотой 2,5 м; лучи выше горизонта без стены не возвращаются. Возвращает точки в системе ТС (дорога на z = 0) и признак земли для каждой.
+func groundScene() ([]Point, []bool) {
+	const height = 1.8
+	rng := rand.New(rand.NewSource(3))
+	var points []Point
+	var truth []bool
+	for step := range 1800 {
+		azimuth := float64(step) * 0.2 * math.Pi / 180
+		cx, cy := math.Cos(azimuth), math.Sin(azimuth)
+		for ring := range 16 {
+			elevation := float64(2*ring-15) * math.Pi / 180
+			d, ground := math.Inf(1), false
+			if elevation < 0 {
+				if r := height / math.Sin(-elevation); r < d {
+					d, ground = r, true
+				}
+			}
+			if cx > 0 {
+				r := 8 / (cx * math.Cos(elevation))
+				y, z := r*math.Cos(elevation)*cy, height+r*math.Sin(elevation)
+				if r < d && math.Abs(y) < 3 && z > 0.05 && z < 2.5 {
+					d, ground = r, false
+				}
+			}
+			if math.IsInf(d, 1) {
+				continue // луч ушёл в небо
+			}
+			d += rng.NormFloat64() * 0.02
+			points = append(points, Point{
+				X:    float32(d * math.Cos(elevation) * cx),
+				Y:    float32(d * math.Cos(elevation) * cy),
+				Z:    float32(height + d*math.Sin(elevation)),
+				Ring: uint8(ring),
+			})
+			truth = append(truth, ground)
+		}
+	}
+	return points, truth
+}
+
+func TestGroundSegmenterMethods(t *testing.T) {
+	for _, method := range []GroundMethod{GroundRing, GroundRANSAC} {
+		cfg := DefaultGroundConfig()
+		cfg.Method = method
+		g, err := NewGroundSegmenter(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		points, truth := groundScene()
+		points = g.Apply(points, [3]float32{0, 0, 1.8})
+		var found, missed, wrong, ground, other int
+		for i, p := range points {
+			labelled := p.Label == LabelGround
+			switch {
+			case truth[i]:
+				ground++
+				if labelled {
+					found++
+				} else {
+					missed++
+				}
+			default:
+				other++
+				if labelled {
+					wrong++
+				}
+			}
+		}
+		// Ложные метки допустимы только у основания стены, в пределах DistanceThreshold от дороги
+		if missed > ground/100 || wrong > other*3/100 {
+			t.Errorf("%s: земля %d, найдено %d, пропущено %d, ложных %d из %d", method, ground, found, missed, wrong, other)
+		}
+	}
+}
+
+func TestGroundSegmenterModes(t *testing.T) {
+	all, truth := groundScene()
+	walls := 0
+	for _, ground := range truth {
+		if !ground {
+			walls++
+		}
+	}
+	for _, c := range []struct {
+		mode   GroundMode
+		fields PointFields
+	}{
+		{GroundLayer, FieldLabel},
+		{GroundDrop, 0},
+		{GroundDecimate, FieldLabel},
+	} {
+		cfg := DefaultGroundConfig()
+		cfg.Mode = c.mode
+		g, err := NewGroundSegmenter(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.Fields() != c.fields {
+			t.Errorf("%s: поля %v", c.mode, g.Fields())
+		}
+		points := g.Apply(append([]Point(nil), all...), [3]float32{0, 0, 1.8})
+		ground := 0
+		for _, p := range points {
+			if p.Label == LabelGround {
+				ground++
+			}
+		}
+		others := len(points) - ground
+		switch c.mode {
+		case GroundLayer:
+			if len(points) != len(all) || ground < len(all)-walls {
+				t.Errorf("слой: %d точек, из них земли %d", len(points), ground)
+			}
+		case GroundDrop:
+			if ground != 0 || others > walls {
+				t.Errorf("удаление: осталось %d точек земли и %d прочих", ground, others)
+			}
+		case GroundDecimate:
+			if ground == 0 || ground > (len(all)-walls)/2 || others > walls {
+				t.Errorf("прореживание: %d точек земли и %d прочих", ground, others)
+			}
+		}
+	}
+}
+
+func TestNewGroundSegmenterValidates(t *testing.T) {
+	for name, edit := range map[string]func(*GroundConfig){
+		"метод":   func(c *GroundConfig) { c.Method = "plane" },
+		"режим":   func(c *GroundConfig) { c.Mode = "hide" },
+		"столбцы": func(c *GroundConfig) { c.AzimuthBins = 0 },
+		"выборки": func(c *GroundConfig) { c.Method, c.Iterations = GroundRANSAC, 0 },
+		"воксель": func(c *GroundConfig) { c.Mode, c.DecimateVoxel = GroundDecimate, 0 },
+		"уклон":   func(c *GroundConfig) { c.MaxSlope = 90 },
+		"высота":  func(c *GroundConfig) { c.MaxHeight = 0 },
+	} {
+		cfg := DefaultGroundConfig()
+		edit(&cfg)
+		if _, err := NewGroundSegmenter(cfg); err == nil {
+			t.Errorf("%s: недопустимая конфигурация принята", name)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package usecase
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SubscriberStats содержит статистику доставки данных одному подписчику
+type SubscriberStats struct {
+	ID      uint64    `json:"id"`
+	Name    string    `json:"name"`
+	Since   time.Time `json:"since"`
+	Sent    uint64    `json:"sent"`    // сколько элементов поставлено в очередь подписчика
+	Dropped uint64    `json:"dropped"` // сколько элементов вытеснено более свежими
+	Queued  int       `json:"queued"`  // текущая длина очереди
+}
+
+// Subscription — подписка на Hub с собственной ограниченной очередью
+type Subscription[T any] struct {
+	id      uint64
+	name    string
+	since   time.Time
+	ch      chan T
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// C возвращает канал, из которого подписчик читает данные.
+// Канал закрывается при отписке или при остановке Hub.
+func (s *Subscription[T]) C() <-chan T {
+	return s.ch
+}
+
+// Stats возвращает текущую статистику подписчика
+func (s *Subscription[T]) Stats() SubscriberStats {
+	return SubscriberStats{
+		ID:      s.id,
+		Name:    s.name,
+		Since:   s.since,
+		Sent:    s.sent.Load(),
+		Dropped: s.dropped.Load(),
+		Queued:  len(s.ch),
+	}
+}
+
+// push кладёт элемент в очередь подписчика по политике "побеждает последний":
+// если очередь заполнена, самый старый элемент выбрасывается
+func (s *Subscription[T]) push(v T) {
+	for {
+		select {
+		case s.ch <- v:
+			s.sent.Add(1)
+			return
+		default:
+		}
+		select {
+		case <-s.ch:
+			s.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// Hub рассылает каждый опубликованный элемент всем подписчикам.
+// Медленный подписчик теряет старые элементы, но не тормозит остальных.
+type Hub[T any] struct {
+	queueSize int
+
+	mu     sync.RWMutex
+	nextID uint64
+	subs   map[uint64]*Subscription[T]
+	closed bool
+}
+
+func NewHub[T any](queueSize int) *Hub[T] {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	return &Hub[T]{
+		queueSize: queueSize,
+		subs:      make(map[uint64]*Subscription[T]),
+	}
+}
+
+// Subscribe регистрирует нового подписчика. name используется только для статистики.
+func (h *Hub[T]) Subscribe(name string) *Subscription[T] {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.nextID++
+	sub := &Subscription[T]{
+		id:    h.nextID,
+		name:  name,
+		since: time.Now(),
+		ch:    make(chan T, h.queueSize),
+	}
+	if h.closed {
+		close(sub.ch)
+		return sub
+	}
+	h.subs[sub.id] = sub
+	return sub
+}
+
+// Unsubscribe удаляет подписчика и закрывает его канал. Повторный вызов безопасен.
+func (h *Hub[T]) Unsubscribe(sub *Subscription[T]) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub.id]; !ok {
+		return
+	}
+	delete(h.subs, sub.id)
+	close(sub.ch)
+}
+
+// Publish рассылает элемент всем текущим подписчикам, не блокируясь на медленных
+func (h *Hub[T]) Publish(v T) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, sub := range h.subs {
+		sub.push(v)
+	}
+}
+
+// Run публикует всё, что приходит из in, пока канал не будет закрыт,
+// после чего закрывает каналы всех подписчиков
+func (h *Hub[T]) Run(in <-chan T) {
+	for v := range in {
+		h.Publish(v)
+	}
+	h.Close()
+}
+
+// Close отписывает всех подписчиков; новые подписки сразу получают закрытый канал
+func (h *Hub[T]) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for id, sub := range h.subs {
+		delete(h.subs, id)
+		close(sub.ch)
+	}
+}
+
+// Stats возвращает статистику всех подписчиков, упорядоченную по ID
+func (h *Hub[T]) Stats() []SubscriberStats {
+	h.mu.RLock()
+	stats := make([]SubscriberStats, 0, len(h.subs))
+	for _, sub := range h.subs {
+		stats = append(stats, sub.Stats())
+	}
+	h.mu.RUnlock()
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ID < stats[j].ID })
+	return stats
+}
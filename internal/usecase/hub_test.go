@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"slices"
+	"testing"
+)
+
+// drainSub читает из подписки всё, что уже лежит в очереди
+func drainSub[T any](sub *Subscription[T]) []T {
+	var got []T
+	for {
+		select {
+		case v, ok := <-sub.C():
+			if !ok {
+				return got
+			}
+			got = append(got, v)
+		default:
+			return got
+		}
+	}
+}
+
+func TestHubBroadcast(t *testing.T) {
+	h := NewHub[int](8)
+	a, b := h.Subscribe("a"), h.Subscribe("b")
+	for i := range 3 {
+		h.Publish(i)
+	}
+	for _, sub := range []*Subscription[int]{a, b} {
+		if got := drainSub(sub); !slices.Equal(got, []int{0, 1, 2}) {
+			t.Errorf("подписчик %d получил %v", sub.id, got)
+		}
+	}
+}
+
+func TestHubSlowSubscriberKeepsLatest(t *testing.T) {
+	h := NewHub[int](3)
+	slow, fast := h.Subscribe("slow"), h.Subscribe("fast")
+	var fastGot []int
+	for i := range 10 {
+		h.Publish(i)
+		fastGot = append(fastGot, drainSub(fast)...)
+	}
+	if got := drainSub(slow); !slices.Equal(got, []int{7, 8, 9}) {
+		t.Errorf("медленный подписчик получил %v, ожидались последние три", got)
+	}
+	if len(fastGot) != 10 {
+		t.Errorf("быстрый подписчик получил %v", fastGot)
+	}
+	stats := h.Stats()
+	if len(stats) != 2 || stats[0].Name != "slow" || stats[0].Sent != 10 || stats[0].Dropped != 7 || stats[1].Dropped != 0 {
+		t.Errorf("статистика %+v", stats)
+	}
+}
+
+func TestHubUnsubscribeAndClose(t *testing.T) {
+	h := NewHub[int](0) // очередь не короче одного элемента
+	a, b := h.Subscribe("a"), h.Subscribe("b")
+	h.Unsubscribe(a)
+	h.Unsubscribe(a)
+	if _, ok := <-a.C(); ok {
+		t.Fatal("канал отписанного подписчика не закрыт")
+	}
+	h.Publish(1)
+	if got := drainSub(b); !slices.Equal(got, []int{1}) {
+		t.Fatalf("подписчик получил %v", got)
+	}
+
+	in := make(chan int, 1)
+	in <- 2
+	close(in)
+	h.Run(in)
+	if got := drainSub(b); !slices.Equal(got, []int{2}) {
+		t.Fatalf("после Run подписчик получил %v", got)
+	}
+	if _, ok := <-b.C(); ok {
+		t.Fatal("Run не закрыл канал подписчика")
+	}
+	if _, ok := <-h.Subscribe("late").C(); ok {
+		t.Fatal("подписка на закрытый Hub получила открытый канал")
+	}
+	if len(h.Stats()) != 0 {
+		t.Fatalf("после закрытия остались подписчики: %+v", h.Stats())
+	}
+}
@@ -0,0 +1,312 @@
+package usecase
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/chewxy/math32"
+	"math"
+	"sort"
+	"sync"
+)
+
+const (
+	// octreeMaxDepth — наибольшая глубина октодерева: код Мортона листа помещается в uint64
+	octreeMaxDepth = 21
+	// octreeHeaderSize — маска полей, число листьев, размер листа, начало координат, глубина,
+	// флаги и длина потока занятости
+	octreeHeaderSize = 1 + 4 + 4 + 3*4 + 1 + 1 + 4
+	// octreeArithmetic — флаг: байты занятости сжаты адаптивным арифметическим кодером
+	octreeArithmetic = 1
+)
+
+// EncodeOctree упаковывает облако в октодерево с листьями размером leafSize. Точки одного листа
+// заменяются его центром: интенсивность усредняется, остальные поля берутся от первой точки листа.
+// Ошибка координат не превышает половины leafSize.
+//
+//	fields      uint8
+//	leaves      uint32     число листьев
+//	leafSize    float32    метры
+//	origin      [3]float32 угол корневого куба (наименьшие x, y, z облака)
+//	depth       uint8      число уровней под корнем
+//	flags       uint8      octreeArithmetic
+//	occupancyLen uint32
+//	occupancy   [occupancyLen]byte байты занятости узлов в обходе в ширину: бит i — дочерний узел
+//	            с кодом i (x — бит 0, y — бит 1, z — бит 2); дочерние узлы обходятся по возрастанию кода
+//	leaves раз: необязательные поля листа (см. AppendPointAttributes) в порядке обхода
+func EncodeOctree(points []Point, fields PointFields, leafSize float32, arithmetic bool) ([]byte, error) {
+	if !(leafSize > 0) || math32.IsInf(leafSize, 1) {
+		return nil, fmt.Errorf("размер листа октодерева должен быть положительным: %v", leafSize)
+	}
+	var origin, upper [3]float32
+	for i, pt := range points {
+		for axis, v := range [3]float32{pt.X, pt.Y, pt.Z} {
+			if math32.IsNaN(v) || math32.IsInf(v, 0) {
+				return nil, fmt.Errorf("точка #%d с недопустимой координатой: %v", i, v)
+			}
+			if i == 0 {
+				origin[axis], upper[axis] = v, v
+			}
+			origin[axis], upper[axis] = min(origin[axis], v), max(upper[axis], v)
+		}
+	}
+	depth := 0
+	for axis := range 3 {
+		cells := math.Floor((float64(upper[axis])-float64(origin[axis]))/float64(leafSize)) + 1
+		for float64(uint64(1)<<depth) < cells {
+			depth++
+			if depth > octreeMaxDepth {
+				return nil, fmt.Errorf("облако размером %v м не помещается в октодерево с листом %v м", upper[axis]-origin[axis], leafSize)
+			}
+		}
+	}
+
+	// Листья по возрастанию кода Мортона; порядок точек внутри листа сохраняется
+	codes := make([]uint64, len(points))
+	for i, pt := range points {
+		var idx [3]uint32
+		for axis, v := range [3]float32{pt.X, pt.Y, pt.Z} {
+			idx[axis] = min(uint32(math.Floor((float64(v)-float64(origin[axis]))/float64(leafSize))), 1<<depth-1)
+		}
+		codes[i] = mortonEncode(idx)
+	}
+	order := make([]int, len(points))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return codes[order[a]] < codes[order[b]] })
+
+	var leaves []uint64
+	var attrs []Point
+	var sum, count uint32
+	for n, i := range order {
+		if n == 0 || codes[i] != leaves[len(leaves)-1] {
+			if n > 0 {
+				attrs[len(attrs)-1].Intensity = uint8(sum / count)
+			}
+			leaves = append(leaves, codes[i])
+			attrs = append(attrs, points[i])
+			sum, count = 0, 0
+		}
+		sum += uint32(points[i].Intensity)
+		count++
+	}
+	if len(attrs) > 0 {
+		attrs[len(attrs)-1].Intensity = uint8(sum / count)
+	}
+
+	// Уровни снизу вверх: узлы уровня — различные коды следующего уровня без трёх младших битов
+	levels := make([][]uint64, depth+1)
+	levels[depth] = leaves
+	for l := depth - 1; l >= 0; l-- {
+		var nodes []uint64
+		for _, code := range levels[l+1] {
+			if parent := code >> 3; len(nodes) == 0 || nodes[len(nodes)-1] != parent {
+				nodes = append(nodes, parent)
+			}
+		}
+		levels[l] = nodes
+	}
+	var occupancy []byte
+	for l := 1; l <= depth && len(leaves) > 0; l++ {
+		for i, code := range levels[l] {
+			if i == 0 || levels[l][i-1]>>3 != code>>3 {
+				occupancy = append(occupancy, 0)
+			}
+			occupancy[len(occupancy)-1] |= 1 << (code & 7)
+		}
+	}
+	var flags uint8
+	if arithmetic {
+		flags |= octreeArithmetic
+		enc := newArithmeticEncoder()
+		for _, b := range occupancy {
+			enc.Encode(b)
+		}
+		occupancy = enc.Finish()
+	}
+
+	buf := make([]byte, 0, octreeHeaderSize+len(occupancy)+len(attrs)*PointAttributesSize(fields))
+	buf = append(buf, byte(fields))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(leaves)))
+	buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(leafSize))
+	for axis := range 3 {
+		buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(origin[axis]))
+	}
+	buf = append(buf, byte(depth), flags)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(occupancy)))
+	buf = append(buf, occupancy...)
+	for _, pt := range attrs {
+		buf = AppendPointAttributes(buf, pt, fields)
+	}
+	return buf, nil
+}
+
+// DecodeOctree восстанавливает центры узлов октодерева, упакованного EncodeOctree, на coarsen
+// уровней выше листьев: 0 — центры листьев, каждый следующий уровень вдвое крупнее.
+// Интенсивность узла — среднее по его листьям, остальные поля — от первого листа.
+func DecodeOctree(data []byte, coarsen int) ([]Point, PointFields, error) {
+	if len(data) < octreeHeaderSize {
+		return nil, 0, fmt.Errorf("слишком короткие данные октодерева: %d байт", len(data))
+	}
+	fields := PointFields(data[0])
+	if fields&^AllPointFields != 0 {
+		return nil, 0, fmt.Errorf("неизвестные поля точки: 0x%02x", uint8(fields))
+	}
+	count := int(binary.LittleEndian.Uint32(data[1:]))
+	leafSize := math32.Float32frombits(binary.LittleEndian.Uint32(data[5:]))
+	var origin [3]float64
+	for axis := range 3 {
+		origin[axis] = float64(math32.Float32frombits(binary.LittleEndian.Uint32(data[9+axis*4:])))
+	}
+	depth, flags := int(data[21]), data[22]
+	occupancyLen := int(binary.LittleEndian.Uint32(data[23:]))
+	if !(leafSize > 0) || depth > octreeMaxDepth || coarsen < 0 {
+		return nil, 0, fmt.Errorf("недопустимые параметры октодерева: лист %v, глубина %d, огрубление %d", leafSize, depth, coarsen)
+	}
+	if count > maxDecodedPoints {
+		return nil, 0, fmt.Errorf("слишком много листьев октодерева: %d, не больше %d", count, maxDecodedPoints)
+	}
+	data = data[octreeHeaderSize:]
+	attrSize := PointAttributesSize(fields)
+	if len(data) != occupancyLen+count*attrSize {
+		return nil, 0, fmt.Errorf("ожидалось %d байт на %d листьев, получено %d", occupancyLen+count*attrSize, count, len(data))
+	}
+	occupancy, attrData := data[:occupancyLen], data[occupancyLen:]
+
+	var next func() (byte, bool)
+	if flags&octreeArithmetic != 0 {
+		dec := newArithmeticDecoder(occupancy)
+		next = func() (byte, bool) {
+			b := dec.Decode()
+			return b, !dec.Overrun()
+		}
+	} else {
+		pos := 0
+		next = func() (byte, bool) {
+			if pos >= len(occupancy) {
+				return 0, false
+			}
+			pos++
+			return occupancy[pos-1], true
+		}
+	}
+	nodes := []uint64{0}
+	if count == 0 {
+		nodes = nil
+	}
+	for range depth {
+		var children []uint64
+		for _, node := range nodes {
+			b, ok := next()
+			if !ok || b == 0 {
+				return nil, 0, fmt.Errorf("повреждены байты занятости октодерева")
+			}
+			for child := range uint64(8) {
+				if b&(1<<child) != 0 {
+					children = append(children, node<<3|child)
+				}
+			}
+			if len(children) > count {
+				return nil, 0, fmt.Errorf("листьев октодерева больше заявленных %d", count)
+			}
+		}
+		nodes = children
+	}
+	if len(nodes) != count {
+		return nil, 0, fmt.Errorf("в октодереве %d листьев, заявлено %d", len(nodes), count)
+	}
+
+	coarsen = min(coarsen, depth)
+	size := float64(leafSize) * float64(uint64(1)<<coarsen)
+	var pts []Point
+	var sum, n uint32
+	for i, leaf := range nodes {
+		var attr Point
+		ReadPointAttributes(attrData[i*attrSize:], &attr, fields)
+		code := leaf >> (3 * coarsen)
+		if i == 0 || nodes[i-1]>>(3*coarsen) != code {
+			if i > 0 {
+				pts[len(pts)-1].Intensity = uint8(sum / n)
+			}
+			idx := mortonDecode(code)
+			attr.X = float32(origin[0] + (float64(idx[0])+0.5)*size)
+			attr.Y = float32(origin[1] + (float64(idx[1])+0.5)*size)
+			attr.Z = float32(origin[2] + (float64(idx[2])+0.5)*size)
+			pts = append(pts, attr)
+			sum, n = 0, 0
+		}
+		sum += uint32(attr.Intensity)
+		n++
+	}
+	if len(pts) > 0 {
+		pts[len(pts)-1].Intensity = uint8(sum / n)
+	}
+	return pts, fields, nil
+}
+
+// OctreeLevels — облака кадра, сжатого кодеком octree, огрублённые на несколько уровней выше
+// листьев (см. DecodeOctree). Уровень восстанавливается и проходит фильтры один раз при первом
+// запросе, остальные операторы получают то же облако. Безопасно для одновременного вызова.
+type OctreeLevels struct {
+	data   []byte
+	filter func([]Point) []Point
+	mu     sync.Mutex
+	levels map[int]octreeLevel
+}
+
+type octreeLevel struct {
+	points []Point
+	err    error
+}
+
+// NewOctreeLevels создаёт огрублённые облака потока октодерева data; filter применяется
+// к каждому восстановленному уровню
+func NewOctreeLevels(data []byte, filter func([]Point) []Point) *OctreeLevels {
+	return &OctreeLevels{data: data, filter: filter, levels: make(map[int]octreeLevel)}
+}
+
+// Points возвращает облако, огрублённое на lod уровней. Облако общее для всех вызывающих
+// и не должно изменяться.
+func (l *OctreeLevels) Points(lod int) ([]Point, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level, ok := l.levels[lod]; ok {
+		return level.points, level.err
+	}
+	points, _, err := DecodeOctree(l.data, lod)
+	if err == nil {
+		points = l.filter(points)
+	}
+	l.levels[lod] = octreeLevel{points: points, err: err}
+	return points, err
+}
+
+// mortonEncode перемежает биты индексов x, y, z (x — младший)
+func mortonEncode(idx [3]uint32) uint64 {
+	return spreadBits(idx[0]) | spreadBits(idx[1])<<1 | spreadBits(idx[2])<<2
+}
+
+func mortonDecode(code uint64) [3]uint32 {
+	return [3]uint32{compactBits(code), compactBits(code >> 1), compactBits(code >> 2)}
+}
+
+// spreadBits раздвигает 21 младший бит v так, что между ними по два нулевых
+func spreadBits(v uint32) uint64 {
+	x := uint64(v) & 0x1fffff
+	x = (x | x<<32) & 0x1f00000000ffff
+	x = (x | x<<16) & 0x1f0000ff0000ff
+	x = (x | x<<8) & 0x100f00f00f00f00f
+	x = (x | x<<4) & 0x10c30c30c30c30c3
+	x = (x | x<<2) & 0x1249249249249249
+	return x
+}
+
+func compactBits(x uint64) uint32 {
+	x &= 0x1249249249249249
+	x = (x | x>>2) & 0x10c30c30c30c30c3
+	x = (x | x>>4) & 0x100f00f00f00f00f
+	x = (x | x>>8) & 0x1f0000ff0000ff
+	x = (x | x>>16) & 0x1f00000000ffff
+	x = (x | x>>32) & 0x1fffff
+	return uint32(x)
+}
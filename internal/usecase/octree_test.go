@@ -0,0 +1,262 @@
+package usecase
+
+import (
+	"encoding/binary"
+	"github.com/chewxy/math32"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func octreeScene() []Point {
+	rng := rand.New(rand.NewSource(7))
+	points := make([]Point, 0, 2000)
+	for i := range 2000 {
+		points = append(points, Point{
+			X:         rng.Float32()*10 - 5,
+			Y:         rng.Float32() * 3,
+			Z:         rng.Float32(),
+			Intensity: uint8(rng.Intn(256)),
+			Time:      uint32(i),
+		})
+	}
+	return points
+}
+
+// nearestCenter возвращает ближайший к точке центр и наибольшее по осям расстояние до него
+func nearestCenter(p Point, centers []Point) (Point, float64) {
+	best, nearest := math.Inf(1), Point{}
+	for _, c := range centers {
+		d := max(math.Abs(float64(p.X-c.X)), math.Abs(float64(p.Y-c.Y)), math.Abs(float64(p.Z-c.Z)))
+		if d < best {
+			best, nearest = d, c
+		}
+	}
+	return nearest, best
+}
+
+func TestOctreeRoundTrip(t *testing.T) {
+	const leaf = 0.1
+	points := octreeScene()
+	fields := FieldIntensity | FieldTime
+	leaves := make(map[[3]int32]bool)
+	for _, p := range points {
+		leaves[[3]int32{int32(math.Floor(float64(p.X+5) / leaf)), int32(math.Floor(float64(p.Y) / leaf)), int32(math.Floor(float64(p.Z) / leaf))}] = true
+	}
+	for _, arithmetic := range []bool{false, true} {
+		data, err := EncodeOctree(points, fields, leaf, arithmetic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		centers, gotFields, err := DecodeOctree(data, 0)
+		if err != nil || gotFields != fields {
+			t.Fatalf("арифметический %v: поля %v, %v", arithmetic, gotFields, err)
+		}
+		// Начало координат дерева — угол облака, а не -5, поэтому число листьев сверяется с допуском
+		if len(centers) == 0 || math.Abs(float64(len(centers)-len(leaves))) > float64(len(leaves))/10 {
+			t.Errorf("арифметический %v: %d листьев, ожидалось около %d", arithmetic, len(centers), len(leaves))
+		}
+		for i, p := range points {
+			if _, d := nearestCenter(p, centers); d > leaf/2+1e-5 {
+				t.Fatalf("арифметический %v: точка #%d в %v от ближайшего центра", arithmetic, i, d)
+			}
+		}
+	}
+}
+
+func TestOctreeKeepsPointFields(t *testing.T) {
+	// По одной точке в листе через один, поэтому поля каждого листа — поля его точки
+	const leaf = 0.2
+	rng := rand.New(rand.NewSource(3))
+	points := make([]Point, 0, 64)
+	for i := range 64 {
+		cell := [3]float32{float32(2 * (i % 4)), float32(2 * (i / 4 % 4)), float32(2 * (i / 16))}
+		points = append(points, Point{
+			X:         (cell[0] + rng.Float32()) * leaf,
+			Y:         (cell[1] + rng.Float32()) * leaf,
+			Z:         (cell[2] + rng.Float32()) * leaf,
+			Intensity: uint8(rng.Intn(256)),
+			Ring:      uint8(i % 16),
+			Time:      uint32(i * 55),
+			Return:    ReturnLast,
+			Sensor:    uint8(i % 3),
+			Label:     uint8(i % 2),
+		})
+	}
+	for _, fields := range []PointFields{0, FieldIntensity, AllPointFields} {
+		// Исходные точки с теми полями, которые передаются при этой маске
+		masked, _, err := DeserializePoints(SerializePoints(points, fields))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, arithmetic := range []bool{false, true} {
+			data, err := EncodeOctree(points, fields, leaf, arithmetic)
+			if err != nil {
+				t.Fatal(err)
+			}
+			leaves, gotFields, err := DecodeOctree(data, 0)
+			if err != nil || gotFields != fields || len(leaves) != len(points) {
+				t.Fatalf("поля %v, арифметический %v: поля %v, %d листьев, %v", fields, arithmetic, gotFields, len(leaves), err)
+			}
+			for i, p := range masked {
+				got, _ := nearestCenter(p, leaves)
+				want := p
+				want.X, want.Y, want.Z = got.X, got.Y, got.Z
+				if got != want {
+					t.Fatalf("поля %v, арифметический %v: точка #%d %+v, ожидалось %+v", fields, arithmetic, i, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestOctreeLeafAttributes(t *testing.T) {
+	// Две точки одного листа и одна в соседнем
+	points := []Point{
+		{X: 0.01, Y: 0.01, Z: 0.01, Intensity: 10, Time: 5},
+		{X: 0.09, Y: 0.02, Z: 0.03, Intensity: 30, Time: 6},
+		{X: 0.15, Y: 0.05, Z: 0.05, Intensity: 100, Time: 7},
+	}
+	data, err := EncodeOctree(points, FieldIntensity|FieldTime, 0.1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaves, _, err := DecodeOctree(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leaves) != 2 {
+		t.Fatalf("%d листьев: %+v", len(leaves), leaves)
+	}
+	if leaves[0].Intensity != 20 || leaves[0].Time != 5 || leaves[1].Intensity != 100 {
+		t.Errorf("поля листьев %+v", leaves)
+	}
+	if abs32(leaves[0].X-0.06) > 1e-6 || abs32(leaves[1].X-0.16) > 1e-6 {
+		t.Errorf("центры листьев %v, %v", leaves[0].X, leaves[1].X)
+	}
+
+	// Уровнем выше оба листа в одном узле; интенсивность — среднее по листьям
+	coarse, _, err := DecodeOctree(data, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coarse) != 1 || coarse[0].Intensity != 60 || abs32(coarse[0].X-0.11) > 1e-6 {
+		t.Errorf("узел уровнем выше %+v", coarse)
+	}
+}
+
+func TestOctreeEmptyAndInvalid(t *testing.T) {
+	data, err := EncodeOctree(nil, FieldIntensity, 0.1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaves, _, err := DecodeOctree(data, 0); err != nil || len(leaves) != 0 {
+		t.Fatalf("пустое облако: %d листьев, %v", len(leaves), err)
+	}
+
+	if _, err := EncodeOctree([]Point{{X: 1}}, 0, 0, false); err == nil {
+		t.Error("нулевой размер листа принят")
+	}
+	if _, err := EncodeOctree([]Point{{X: float32(math.Inf(1))}}, 0, 0.1, false); err == nil {
+		t.Error("бесконечная координата принята")
+	}
+	// 1 км с листом 0,1 мм не помещается в 21 уровень
+	if _, err := EncodeOctree([]Point{{X: 0}, {X: 1000}}, 0, 0.0001, false); err == nil {
+		t.Error("слишком глубокое октодерево принято")
+	}
+
+	data, err = EncodeOctree(octreeScene(), FieldIntensity, 0.1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := DecodeOctree(data[:len(data)-1], 0); err == nil {
+		t.Error("обрезанные данные приняты")
+	}
+	broken := append([]byte(nil), data...)
+	broken[octreeHeaderSize] = 0 // корень без дочерних узлов
+	if _, _, err := DecodeOctree(broken, 0); err == nil {
+		t.Error("повреждённые байты занятости приняты")
+	}
+	if _, _, err := DecodeOctree(data, -1); err == nil {
+		t.Error("отрицательное огрубление принято")
+	}
+}
+
+// craftedOctree — октодерево без полей точки с арифметическим потоком занятости occupancy
+func craftedOctree(leaves uint32, depth uint8, occupancy []byte) []byte {
+	buf := []byte{0}
+	buf = binary.LittleEndian.AppendUint32(buf, leaves)
+	buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(0.1))
+	buf = append(buf, make([]byte, 3*4)...)
+	buf = append(buf, depth, octreeArithmetic)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(occupancy)))
+	return append(buf, occupancy...)
+}
+
+// fullOccupancy — арифметический поток занятости полного дерева глубины depth: все байты 0xff.
+// Адаптивная модель сжимает его до байта на тысячу узлов
+func fullOccupancy(depth int) []byte {
+	enc := newArithmeticEncoder()
+	for level := range depth {
+		for range 1 << (3 * level) {
+			enc.Encode(0xff)
+		}
+	}
+	return enc.Finish()
+}
+
+func TestOctreeRejectsCraftedStream(t *testing.T) {
+	// Без полей точки размер данных не ограничивает число листьев: несколько сотен байт
+	// описывают два миллиона листьев
+	occupancy := fullOccupancy(7)
+	if _, _, err := DecodeOctree(craftedOctree(1<<21, 7, occupancy), 0); err == nil {
+		t.Errorf("принято %d листьев из %d байт", 1<<21, len(occupancy))
+	}
+	occupancy = fullOccupancy(6)
+	points, _, err := DecodeOctree(craftedOctree(1<<18, 6, occupancy), 0)
+	if err != nil || len(points) != 1<<18 {
+		t.Fatalf("полное дерево глубины 6: %d листьев, %v", len(points), err)
+	}
+	if _, _, err := DecodeOctree(craftedOctree(1<<18, 6, occupancy[:len(occupancy)/2]), 0); err == nil {
+		t.Error("принят обрезанный поток занятости")
+	}
+}
+
+func TestOctreeLevelsDecodeOncePerLevel(t *testing.T) {
+	data, err := EncodeOctree(octreeScene(), FieldIntensity, 0.1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	levels := NewOctreeLevels(data, func(points []Point) []Point {
+		calls++
+		// Фильтр сервера: только точки с x > 0
+		kept := points[:0]
+		for _, p := range points {
+			if p.X > 0 {
+				kept = append(kept, p)
+			}
+		}
+		return kept
+	})
+	first, err := levels.Points(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _, _ := DecodeOctree(data, 2)
+	if len(first) == 0 || len(first) >= len(want) {
+		t.Fatalf("после фильтра %d точек из %d", len(first), len(want))
+	}
+	for _, p := range first {
+		if p.X <= 0 {
+			t.Fatalf("точка %+v не прошла фильтр", p)
+		}
+	}
+	again, err := levels.Points(2)
+	if err != nil || len(again) != len(first) || &again[0] != &first[0] {
+		t.Fatalf("уровень восстановлен повторно: %v", err)
+	}
+	if _, err := levels.Points(1); err != nil || calls != 2 {
+		t.Fatalf("фильтр вызван %d раз, %v", calls, err)
+	}
+}
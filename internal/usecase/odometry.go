@@ -0,0 +1,401 @@
+package usecase
+
+import (
+	"fmt"
+	"math"
+)
+
+// Pose — положение ТС в системе координат первого кадра одометрии
+type Pose struct {
+	Position    [3]float32 `json:"position"`
+	Orientation [4]float32 `json:"orientation"` // кватернион x, y, z, w
+	Yaw         float32    `json:"yaw"`         // курс, радианы
+	Converged   bool       `json:"converged"`   // сопоставление кадров сошлось; иначе положение экстраполировано
+	Pairs       int        `json:"pairs"`       // число пар точек в последней итерации
+	RMSE        float32    `json:"rmse"`        // СКО расстояний точка — плоскость, метры
+}
+
+// OdometryConfig задаёт сопоставление соседних кадров методом ICP точка — плоскость
+type OdometryConfig struct {
+	VoxelSize         float32 // размер вокселя для прореживания кадров перед сопоставлением, метры
+	MaxCorrespondence float32 // наибольшее расстояние между точками пары, метры
+	MaxIterations     int
+	MinPairs          int // при меньшем числе пар сопоставление считается несостоявшимся
+}
+
+func DefaultOdometryConfig() OdometryConfig {
+	return OdometryConfig{
+		VoxelSize:         0.5,
+		MaxCorrespondence: 1,
+		MaxIterations:     30,
+		MinPairs:          200,
+	}
+}
+
+const (
+	// icpEpsilon — шаг итерации, ниже которого ICP считается сошедшимся (радианы и метры)
+	icpEpsilon = 1e-4
+	// minNormalNeighbors — сколько соседей нужно для оценки нормали точки
+	minNormalNeighbors = 5
+)
+
+// rigid — перемещение x → r·x + t в двойной точности
+type rigid struct {
+	r [3][3]float64
+	t [3]float64
+}
+
+func identityRigid() rigid {
+	return rigid{r: [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}}
+}
+
+func (a rigid) apply(p [3]float64) [3]float64 {
+	var out [3]float64
+	for i := range 3 {
+		out[i] = a.r[i][0]*p[0] + a.r[i][1]*p[1] + a.r[i][2]*p[2] + a.t[i]
+	}
+	return out
+}
+
+// then возвращает перемещение «сначала b, потом a»
+func (a rigid) then(b rigid) rigid {
+	var c rigid
+	for i := range 3 {
+		for j := range 3 {
+			c.r[i][j] = a.r[i][0]*b.r[0][j] + a.r[i][1]*b.r[1][j] + a.r[i][2]*b.r[2][j]
+		}
+	}
+	c.t = a.apply(b.t)
+	return c
+}
+
+// rotationExp возвращает поворот на вектор omega (ось × угол) по формуле Родрига
+func rotationExp(omega [3]float64) [3][3]float64 {
+	theta := math.Sqrt(omega[0]*omega[0] + omega[1]*omega[1] + omega[2]*omega[2])
+	r := [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	if theta < 1e-12 {
+		return r
+	}
+	k := [3]float64{omega[0] / theta, omega[1] / theta, omega[2] / theta}
+	s, c := math.Sincos(theta)
+	kx := [3][3]float64{{0, -k[2], k[1]}, {k[2], 0, -k[0]}, {-k[1], k[0], 0}}
+	for i := range 3 {
+		for j := range 3 {
+			r[i][j] += s*kx[i][j] + (1-c)*(k[i]*k[j]-boolFloat(i == j))
+		}
+	}
+	return r
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// quaternion возвращает кватернион x, y, z, w поворота
+func (a rigid) quaternion() [4]float64 {
+	r := a.r
+	var q [4]float64
+	switch trace := r[0][0] + r[1][1] + r[2][2]; {
+	case trace > 0:
+		s := 2 * math.Sqrt(trace+1)
+		q = [4]float64{(r[2][1] - r[1][2]) / s, (r[0][2] - r[2][0]) / s, (r[1][0] - r[0][1]) / s, s / 4}
+	case r[0][0] > r[1][1] && r[0][0] > r[2][2]:
+		s := 2 * math.Sqrt(1+r[0][0]-r[1][1]-r[2][2])
+		q = [4]float64{s / 4, (r[0][1] + r[1][0]) / s, (r[0][2] + r[2][0]) / s, (r[2][1] - r[1][2]) / s}
+	case r[1][1] > r[2][2]:
+		s := 2 * math.Sqrt(1+r[1][1]-r[0][0]-r[2][2])
+		q = [4]float64{(r[0][1] + r[1][0]) / s, s / 4, (r[1][2] + r[2][1]) / s, (r[0][2] - r[2][0]) / s}
+	default:
+		s := 2 * math.Sqrt(1+r[2][2]-r[0][0]-r[1][1])
+		q = [4]float64{(r[0][2] + r[2][0]) / s, (r[1][2] + r[2][1]) / s, s / 4, (r[1][0] - r[0][1]) / s}
+	}
+	norm := math.Sqrt(q[0]*q[0] + q[1]*q[1] + q[2]*q[2] + q[3]*q[3])
+	return [4]float64{q[0] / norm, q[1] / norm, q[2] / norm, q[3] / norm}
+}
+
+// normalized восстанавливает ортонормированность поворота, накопившего ошибки округления
+func (a rigid) normalized() rigid {
+	a.r = quaternionRotation(a.quaternion())
+	return a
+}
+
+// quaternionRotation возвращает матрицу поворота единичного кватерниона x, y, z, w
+func quaternionRotation(q [4]float64) [3][3]float64 {
+	x, y, z, w := q[0], q[1], q[2], q[3]
+	return [3][3]float64{
+		{1 - 2*(y*y+z*z), 2 * (x*y - z*w), 2 * (x*z + y*w)},
+		{2 * (x*y + z*w), 1 - 2*(x*x+z*z), 2 * (y*z - x*w)},
+		{2 * (x*z - y*w), 2 * (y*z + x*w), 1 - 2*(x*x+y*y)},
+	}
+}
+
+// poseRigid возвращает перемещение из системы ТС в систему первого кадра одометрии
+func poseRigid(pose Pose) rigid {
+	q := pose.Orientation
+	return rigid{
+		r: quaternionRotation([4]float64{float64(q[0]), float64(q[1]), float64(q[2]), float64(q[3])}),
+		t: [3]float64{float64(pose.Position[0]), float64(pose.Position[1]), float64(pose.Position[2])},
+	}
+}
+
+// Odometry оценивает перемещение ТС между соседними кадрами методом ICP точка — плоскость
+// и накапливает траекторию. Не безопасна для одновременного использования: у каждого ТС своя.
+type Odometry struct {
+	cfg    OdometryConfig
+	pose   rigid // положение ТС в системе первого кадра
+	motion rigid // перемещение за предыдущий кадр — начальное приближение для следующего
+
+	// Предыдущий кадр: точки с нормалями и их индекс по ячейкам со стороной MaxCorrespondence
+	target  [][3]float64
+	normals [][3]float64
+	cells   map[[3]int32][]int32
+	started bool
+}
+
+// Validate проверяет параметры ICP
+func (cfg OdometryConfig) Validate() error {
+	if cfg.VoxelSize <= 0 || cfg.MaxCorrespondence <= 0 {
+		return fmt.Errorf("размер вокселя и расстояние пары должны быть положительными")
+	}
+	if cfg.MaxIterations < 1 || cfg.MinPairs < 6 {
+		return fmt.Errorf("недопустимые параметры ICP: итераций %d, пар %d", cfg.MaxIterations, cfg.MinPairs)
+	}
+	return nil
+}
+
+func NewOdometry(cfg OdometryConfig) (*Odometry, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Odometry{
+		cfg:    cfg,
+		pose:   identityRigid(),
+		motion: identityRigid(),
+		cells:  make(map[[3]int32][]int32),
+	}, nil
+}
+
+// Update сопоставляет кадр с предыдущим и возвращает новое положение ТС.
+// Первый кадр задаёт начало координат траектории.
+func (o *Odometry) Update(points []Point) Pose {
+	sparse := VoxelDownsample(points, o.cfg.VoxelSize)
+	source := make([][3]float64, len(sparse))
+	for i, pt := range sparse {
+		source[i] = [3]float64{float64(pt.X), float64(pt.Y), float64(pt.Z)}
+	}
+
+	pose := Pose{Converged: true}
+	if o.started {
+		motion, pairs, rmse, ok := o.align(source, o.motion)
+		pose.Converged, pose.Pairs, pose.RMSE = ok, pairs, float32(rmse)
+		if ok {
+			o.motion = motion
+		}
+		// Без сходимости ТС считается продолжающим прежнее движение
+		o.pose = o.pose.then(o.motion).normalized()
+	}
+	o.started = true
+	o.setTarget(source)
+
+	q := o.pose.quaternion()
+	pose.Position = [3]float32{float32(o.pose.t[0]), float32(o.pose.t[1]), float32(o.pose.t[2])}
+	pose.Orientation = [4]float32{float32(q[0]), float32(q[1]), float32(q[2]), float32(q[3])}
+	pose.Yaw = float32(math.Atan2(o.pose.r[1][0], o.pose.r[0][0]))
+	return pose
+}
+
+func (o *Odometry) cellOf(p [3]float64) [3]int32 {
+	size := float64(o.cfg.MaxCorrespondence)
+	return [3]int32{int32(math.Floor(p[0] / size)), int32(math.Floor(p[1] / size)), int32(math.Floor(p[2] / size))}
+}
+
+// neighbors вызывает visit для точек предыдущего кадра в соседних с p ячейках
+func (o *Odometry) neighbors(p [3]float64, visit func(i int32)) {
+	key := o.cellOf(p)
+	for _, off := range cellOffsets {
+		for _, i := range o.cells[[3]int32{key[0] + off[0], key[1] + off[1], key[2] + off[2]}] {
+			visit(i)
+		}
+	}
+}
+
+// setTarget запоминает кадр как опорный и оценивает нормали его точек по соседям
+func (o *Odometry) setTarget(points [][3]float64) {
+	clear(o.cells)
+	for i, p := range points {
+		key := o.cellOf(p)
+		o.cells[key] = append(o.cells[key], int32(i))
+	}
+	o.target = points
+	o.normals = make([][3]float64, len(points))
+	radius2 := float64(o.cfg.MaxCorrespondence) * float64(o.cfg.MaxCorrespondence)
+	for i, p := range points {
+		var mean [3]float64
+		var cov [3][3]float64
+		n := 0
+		o.neighbors(p, func(j int32) {
+			q := points[j]
+			d := [3]float64{q[0] - p[0], q[1] - p[1], q[2] - p[2]}
+			if d[0]*d[0]+d[1]*d[1]+d[2]*d[2] > radius2 {
+				return
+			}
+			n++
+			for a := range 3 {
+				mean[a] += q[a]
+				for b := range 3 {
+					cov[a][b] += q[a] * q[b]
+				}
+			}
+		})
+		if n < minNormalNeighbors {
+			continue // нулевая нормаль: точка не участвует в парах
+		}
+		for a := range 3 {
+			mean[a] /= float64(n)
+		}
+		for a := range 3 {
+			for b := range 3 {
+				cov[a][b] = cov[a][b]/float64(n) - mean[a]*mean[b]
+			}
+		}
+		o.normals[i] = smallestEigenvector(cov)
+	}
+}
+
+// align уточняет перемещение, переводящее точки кадра в систему предыдущего кадра
+func (o *Odometry) align(source [][3]float64, guess rigid) (rigid, int, float64, bool) {
+	radius2 := float64(o.cfg.MaxCorrespondence) * float64(o.cfg.MaxCorrespondence)
+	estimate := guess
+	pairs, rmse := 0, 0.0
+	for range o.cfg.MaxIterations {
+		// Нормальные уравнения JᵀJ·x = −Jᵀr для x = [ω, t]
+		var ata [6][6]float64
+		var atb [6]float64
+		pairs, rmse = 0, 0
+		for _, s := range source {
+			p := estimate.apply(s)
+			best, bestDist := int32(-1), radius2
+			o.neighbors(p, func(j int32) {
+				if o.normals[j] == ([3]float64{}) {
+					return
+				}
+				q := o.target[j]
+				d := (p[0]-q[0])*(p[0]-q[0]) + (p[1]-q[1])*(p[1]-q[1]) + (p[2]-q[2])*(p[2]-q[2])
+				if d <= bestDist {
+					best, bestDist = j, d
+				}
+			})
+			if best < 0 {
+				continue
+			}
+			q, n := o.target[best], o.normals[best]
+			r := n[0]*(p[0]-q[0]) + n[1]*(p[1]-q[1]) + n[2]*(p[2]-q[2])
+			j := [6]float64{
+				p[1]*n[2] - p[2]*n[1],
+				p[2]*n[0] - p[0]*n[2],
+				p[0]*n[1] - p[1]*n[0],
+				n[0], n[1], n[2],
+			}
+			for a := range 6 {
+				for b := range 6 {
+					ata[a][b] += j[a] * j[b]
+				}
+				atb[a] -= j[a] * r
+			}
+			pairs++
+			rmse += r * r
+		}
+		if pairs < o.cfg.MinPairs {
+			return guess, pairs, 0, false
+		}
+		rmse = math.Sqrt(rmse / float64(pairs))
+		x, ok := solve6(ata, atb)
+		if !ok {
+			return guess, pairs, rmse, false
+		}
+		step := rigid{r: rotationExp([3]float64{x[0], x[1], x[2]}), t: [3]float64{x[3], x[4], x[5]}}
+		estimate = step.then(estimate)
+		if math.Abs(x[0])+math.Abs(x[1])+math.Abs(x[2])+math.Abs(x[3])+math.Abs(x[4])+math.Abs(x[5]) < icpEpsilon {
+			return estimate, pairs, rmse, true
+		}
+	}
+	// Итерации исчерпаны: оценка принимается, если пар по-прежнему достаточно
+	return estimate, pairs, rmse, true
+}
+
+// solve6 решает систему 6×6 методом Гаусса с выбором главного элемента
+func solve6(a [6][6]float64, b [6]float64) ([6]float64, bool) {
+	for col := range 6 {
+		pivot := col
+		for row := col + 1; row < 6; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-9 {
+			return b, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+		for row := col + 1; row < 6; row++ {
+			f := a[row][col] / a[col][col]
+			for k := col; k < 6; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+			b[row] -= f * b[col]
+		}
+	}
+	var x [6]float64
+	for row := 5; row >= 0; row-- {
+		sum := b[row]
+		for k := row + 1; k < 6; k++ {
+			sum -= a[row][k] * x[k]
+		}
+		x[row] = sum / a[row][row]
+	}
+	return x, true
+}
+
+// smallestEigenvector возвращает собственный вектор симметричной матрицы 3×3
+// с наименьшим собственным значением (вращения Якоби)
+func smallestEigenvector(m [3][3]float64) [3]float64 {
+	v := [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	for range 16 {
+		off := math.Abs(m[0][1]) + math.Abs(m[0][2]) + math.Abs(m[1][2])
+		if off < 1e-12 {
+			break
+		}
+		for _, pq := range [3][2]int{{0, 1}, {0, 2}, {1, 2}} {
+			p, q := pq[0], pq[1]
+			if math.Abs(m[p][q]) < 1e-15 {
+				continue
+			}
+			theta := (m[q][q] - m[p][p]) / (2 * m[p][q])
+			t := math.Copysign(1, theta) / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+			c := 1 / math.Sqrt(t*t+1)
+			s := t * c
+			for k := range 3 {
+				mkp, mkq := m[k][p], m[k][q]
+				m[k][p], m[k][q] = c*mkp-s*mkq, s*mkp+c*mkq
+			}
+			for k := range 3 {
+				mpk, mqk := m[p][k], m[q][k]
+				m[p][k], m[q][k] = c*mpk-s*mqk, s*mpk+c*mqk
+			}
+			for k := range 3 {
+				vkp, vkq := v[k][p], v[k][q]
+				v[k][p], v[k][q] = c*vkp-s*vkq, s*vkp+c*vkq
+			}
+		}
+	}
+	smallest := 0
+	for i := 1; i < 3; i++ {
+		if m[i][i] < m[smallest][smallest] {
+			smallest = i
+		}
+	}
+	return [3]float64{v[0][smallest], v[1][smallest], v[2][smallest]}
+}
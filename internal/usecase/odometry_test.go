@@ -0,0 +1,170 @@
+package usecase
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// streetScene — улица: дорога, две стены по сторонам, столбы и ящик
+func streetScene(rng *rand.Rand) [][3]float32 {
+	var world [][3]float32
+	for range 20000 {
+		world = append(world, [3]float32{rng.Float32()*80 - 40, rng.Float32()*16 - 8, 0})
+	}
+	for range 8000 {
+		x, z := rng.Float32()*80-40, rng.Float32()*4
+		world = append(world, [3]float32{x, 8, z}, [3]float32{x, -8, z})
+	}
+	for k := range 10 {
+		cx := float64(k*8 - 36)
+		for range 300 {
+			a := rng.Float64() * 2 * math.Pi
+			world = append(world, [3]float32{float32(cx + 0.2*math.Cos(a)), float32(5 + 0.2*math.Sin(a)), rng.Float32() * 5})
+		}
+	}
+	for range 3000 {
+		world = append(world,
+			[3]float32{10 + rng.Float32()*2, -3, rng.Float32() * 1.5},
+			[3]float32{10, -3 + rng.Float32()*1.5, rng.Float32() * 1.5})
+	}
+	return world
+}
+
+// streetFrame — точки улицы в системе ТС в положении (x, y) с курсом yaw: половина точек
+// в радиусе 30 м с шумом 2 см
+func streetFrame(rng *rand.Rand, world [][3]float32, x, y, yaw float64) []Point {
+	sin, cos := math.Sincos(yaw)
+	points := make([]Point, 0, len(world)/2)
+	for _, w := range world {
+		dx, dy := float64(w[0])-x, float64(w[1])-y
+		lx, ly := cos*dx+sin*dy, -sin*dx+cos*dy
+		if math.Hypot(lx, ly) > 30 || rng.Intn(2) == 0 {
+			continue
+		}
+		points = append(points, Point{X: float32(lx + rng.NormFloat64()*0.02), Y: float32(ly + rng.NormFloat64()*0.02), Z: w[2]})
+	}
+	return points
+}
+
+func TestOdometryTracksMotion(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	world := streetScene(rng)
+	odometry, err := NewOdometry(DefaultOdometryConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ТС проезжает 1 м за кадр и поворачивает на 1° за кадр
+	var x, y, yaw float64
+	for k := range 10 {
+		pose := odometry.Update(streetFrame(rng, world, x, y, yaw))
+		if !pose.Converged {
+			t.Fatalf("кадр %d: ICP не сошёлся (пар %d)", k, pose.Pairs)
+		}
+		if k > 0 && (pose.Pairs < DefaultOdometryConfig().MinPairs || pose.RMSE > 0.1) {
+			t.Errorf("кадр %d: пар %d, СКО %v", k, pose.Pairs, pose.RMSE)
+		}
+		if dx, dy := float64(pose.Position[0])-x, float64(pose.Position[1])-y; math.Hypot(dx, dy) > 0.2 {
+			t.Fatalf("кадр %d: положение %v, ожидалось (%.3f, %.3f)", k, pose.Position, x, y)
+		}
+		if math.Abs(float64(pose.Yaw)-yaw) > 0.01 {
+			t.Fatalf("кадр %d: курс %v, ожидалось %v", k, pose.Yaw, yaw)
+		}
+		q := pose.Orientation
+		if math.Abs(float64(q[3])-math.Cos(yaw/2)) > 0.01 || math.Abs(float64(q[2])-math.Sin(yaw/2)) > 0.01 {
+			t.Fatalf("кадр %d: кватернион %v при курсе %v", k, q, yaw)
+		}
+		x += math.Cos(yaw)
+		y += math.Sin(yaw)
+		yaw += math.Pi / 180
+	}
+}
+
+func TestOdometryExtrapolatesWithoutPairs(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	world := streetScene(rng)
+	odometry, err := NewOdometry(DefaultOdometryConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := range 3 {
+		odometry.Update(streetFrame(rng, world, float64(k), 0, 0))
+	}
+	// Пустой кадр не сопоставляется: положение продолжает прежнее движение
+	pose := odometry.Update(nil)
+	if pose.Converged || math.Abs(float64(pose.Position[0])-3) > 0.2 {
+		t.Fatalf("пустой кадр: %+v", pose)
+	}
+}
+
+func TestOdometryMotionVelocity(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	world := streetScene(rng)
+	odometry, err := NewOdometry(DefaultOdometryConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	motion := NewOdometryMotion(odometry)
+	start := time.Unix(1700000000, 0)
+	if _, ok := motion.Velocity(start); ok {
+		t.Fatal("скорость известна до первого кадра")
+	}
+	// 10 м/с и 0,1745 рад/с при кадрах 10 Гц
+	var yaw float64
+	for k := range 4 {
+		x := float64(k)
+		motion.Observe(start.Add(time.Duration(k)*100*time.Millisecond), streetFrame(rng, world, x, 0, yaw))
+		yaw += math.Pi / 180
+	}
+	v, ok := motion.Velocity(start)
+	if !ok {
+		t.Fatal("скорость не оценена")
+	}
+	if math.Abs(v.Linear[0]-10) > 1 || math.Abs(v.Linear[1]) > 1 || math.Abs(v.Angular[2]-math.Pi/18) > 0.05 {
+		t.Fatalf("скорость %+v", v)
+	}
+}
+
+func TestRigidRotationHelpers(t *testing.T) {
+	for _, omega := range [][3]float64{{0, 0, 0}, {0, 0, 0.3}, {0.1, -0.2, 0.05}, {1, 0.5, -2}} {
+		r := rigid{r: rotationExp(omega), t: [3]float64{1, 2, 3}}
+		back := rotationLog(r.r)
+		for i := range 3 {
+			if math.Abs(back[i]-omega[i]) > 1e-9 {
+				t.Fatalf("rotationLog(rotationExp(%v)) = %v", omega, back)
+			}
+		}
+		q := r.quaternion()
+		restored := quaternionRotation(q)
+		identity := r.inverse().then(r)
+		for i := range 3 {
+			for j := range 3 {
+				if math.Abs(restored[i][j]-r.r[i][j]) > 1e-9 {
+					t.Fatalf("кватернион %v не восстанавливает поворот %v", q, omega)
+				}
+				if math.Abs(identity.r[i][j]-boolFloat(i == j)) > 1e-9 {
+					t.Fatalf("inverse: %v", identity.r)
+				}
+			}
+			if math.Abs(identity.t[i]) > 1e-9 {
+				t.Fatalf("inverse: смещение %v", identity.t)
+			}
+		}
+	}
+}
+
+func TestOdometryConfigValidate(t *testing.T) {
+	for name, edit := range map[string]func(*OdometryConfig){
+		"воксель":  func(c *OdometryConfig) { c.VoxelSize = 0 },
+		"пара":     func(c *OdometryConfig) { c.MaxCorrespondence = -1 },
+		"итерации": func(c *OdometryConfig) { c.MaxIterations = 0 },
+		"мало пар": func(c *OdometryConfig) { c.MinPairs = 5 },
+	} {
+		cfg := DefaultOdometryConfig()
+		edit(&cfg)
+		if _, err := NewOdometry(cfg); err == nil {
+			t.Errorf("%s: недопустимая конфигурация принята", name)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package usecase
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/chewxy/math32"
+	"strings"
+)
+
+// PointFields — битовая маска необязательных полей точки, присутствующих в облаке
+type PointFields uint8
+
+const (
+	FieldIntensity PointFields = 1 << iota // отражательная способность, 0..255
+	FieldRing                              // номер кольца лазера
+	FieldTime                              // время выстрела относительно начала кадра
+	FieldReturn                            // тип отражения (сильнейшее, последнее, совпавшие)
+	FieldSensor                            // идентификатор сенсора, снявшего точку
+	FieldLabel                             // класс точки (см. LabelGround)
+
+	AllPointFields = FieldIntensity | FieldRing | FieldTime | FieldReturn | FieldSensor | FieldLabel
+)
+
+var pointFieldNames = []struct {
+	field PointFields
+	name  string
+}{
+	{FieldIntensity, "intensity"},
+	{FieldRing, "ring"},
+	{FieldTime, "time"},
+	{FieldReturn, "return"},
+	{FieldSensor, "sensor"},
+	{FieldLabel, "label"},
+}
+
+// Names возвращает имена полей маски в порядке их записи
+func (f PointFields) Names() []string {
+	names := make([]string, 0, len(pointFieldNames))
+	for _, fn := range pointFieldNames {
+		if f&fn.field != 0 {
+			names = append(names, fn.name)
+		}
+	}
+	return names
+}
+
+func (f PointFields) String() string {
+	return strings.Join(f.Names(), ",")
+}
+
+// ParsePointFields разбирает имена полей из конфигурации
+func ParsePointFields(names []string) (PointFields, error) {
+	var fields PointFields
+	for _, name := range names {
+		found := false
+		for _, fn := range pointFieldNames {
+			if fn.name == name {
+				fields |= fn.field
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("неизвестное поле точки: %q", name)
+		}
+	}
+	return fields, nil
+}
+
+// Point — точка облака. Необязательные поля имеют смысл, только если они есть в маске облака.
+type Point struct {
+	X, Y, Z   float32
+	Intensity uint8
+	Ring      uint8
+	Time      uint32     // мкс от времени съёмки кадра
+	Return    ReturnMode // тип отражения
+	Sensor    uint8      // идентификатор сенсора
+	Label     uint8      // класс точки
+
+	// Измерение сенсора, из которого получена точка: дальность (м) и азимут (градусы) в системе
+	// сенсора. Заполняются в Tx и не сериализуются; нужны кодеку дальностей (см. EncodeRangeImage).
+	// Нулевая дальность — точка не соответствует измерению.
+	Distance float32
+	Azimuth  float32
+}
+
+// Классы точек в поле label
+const (
+	LabelUnknown uint8 = 0
+	LabelGround  uint8 = 1 // поверхность дороги
+)
+
+// pointSize возвращает размер сериализованной точки в байтах для маски полей
+func pointSize(fields PointFields) int {
+	return 12 + PointAttributesSize(fields)
+}
+
+// PointAttributesSize возвращает размер необязательных полей точки в байтах для маски полей
+func PointAttributesSize(fields PointFields) int {
+	size := 0
+	if fields&FieldIntensity != 0 {
+		size++
+	}
+	if fields&FieldRing != 0 {
+		size++
+	}
+	if fields&FieldTime != 0 {
+		size += 4
+	}
+	if fields&FieldReturn != 0 {
+		size++
+	}
+	if fields&FieldSensor != 0 {
+		size++
+	}
+	if fields&FieldLabel != 0 {
+		size++
+	}
+	return size
+}
+
+// SerializePoints записывает облако в бинарный вид (little-endian):
+// маска полей (1 байт), число точек (4 байта), затем точки: x, y, z (float32)
+// и присутствующие необязательные поля в порядке intensity (uint8), ring (uint8), time (uint32),
+// return (uint8), sensor (uint8), label (uint8)
+func SerializePoints(points []Point, fields PointFields) []byte {
+	buf := make([]byte, 0, 5+len(points)*pointSize(fields))
+	buf = append(buf, byte(fields))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(points)))
+	for _, pt := range points {
+		buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(pt.X))
+		buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(pt.Y))
+		buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(pt.Z))
+		buf = AppendPointAttributes(buf, pt, fields)
+	}
+	return buf
+}
+
+// DeserializePoints разбирает облако, записанное SerializePoints
+func DeserializePoints(data []byte) ([]Point, PointFields, error) {
+	if len(data) < 5 {
+		return nil, 0, fmt.Errorf("слишком короткие данные облака: %d байт", len(data))
+	}
+	fields := PointFields(data[0])
+	if fields&^AllPointFields != 0 {
+		return nil, 0, fmt.Errorf("неизвестные поля точки: 0x%02x", uint8(fields))
+	}
+	count := int(binary.LittleEndian.Uint32(data[1:5]))
+	size := pointSize(fields)
+	data = data[5:]
+	if len(data) != count*size {
+		return nil, 0, fmt.Errorf("ожидалось %d байт на %d точек, получено %d", count*size, count, len(data))
+	}
+	pts := make([]Point, count)
+	for i := range pts {
+		rec := data[i*size : (i+1)*size]
+		pt := &pts[i]
+		pt.X = math32.Float32frombits(binary.LittleEndian.Uint32(rec[0:4]))
+		pt.Y = math32.Float32frombits(binary.LittleEndian.Uint32(rec[4:8]))
+		pt.Z = math32.Float32frombits(binary.LittleEndian.Uint32(rec[8:12]))
+		ReadPointAttributes(rec[12:], pt, fields)
+	}
+	return pts, fields, nil
+}
+
+// AppendPointAttributes дописывает необязательные поля точки, присутствующие в маске,
+// в порядке intensity (uint8), ring (uint8), time (uint32), return (uint8), sensor (uint8), label (uint8)
+func AppendPointAttributes(buf []byte, pt Point, fields PointFields) []byte {
+	if fields&FieldIntensity != 0 {
+		buf = append(buf, pt.Intensity)
+	}
+	if fields&FieldRing != 0 {
+		buf = append(buf, pt.Ring)
+	}
+	if fields&FieldTime != 0 {
+		buf = binary.LittleEndian.AppendUint32(buf, pt.Time)
+	}
+	if fields&FieldReturn != 0 {
+		buf = append(buf, byte(pt.Return))
+	}
+	if fields&FieldSensor != 0 {
+		buf = append(buf, pt.Sensor)
+	}
+	if fields&FieldLabel != 0 {
+		buf = append(buf, pt.Label)
+	}
+	return buf
+}
+
+// ReadPointAttributes читает необязательные поля, записанные AppendPointAttributes;
+// rec должен содержать не меньше PointAttributesSize(fields) байт
+func ReadPointAttributes(rec []byte, pt *Point, fields PointFields) {
+	pos := 0
+	if fields&FieldIntensity != 0 {
+		pt.Intensity = rec[pos]
+		pos++
+	}
+	if fields&FieldRing != 0 {
+		pt.Ring = rec[pos]
+		pos++
+	}
+	if fields&FieldTime != 0 {
+		pt.Time = binary.LittleEndian.Uint32(rec[pos:])
+		pos += 4
+	}
+	if fields&FieldReturn != 0 {
+		pt.Return = ReturnMode(rec[pos])
+		pos++
+	}
+	if fields&FieldSensor != 0 {
+		pt.Sensor = rec[pos]
+		pos++
+	}
+	if fields&FieldLabel != 0 {
+		pt.Label = rec[pos]
+	}
+}
@@ -1,155 +1,550 @@
 package usecase
 
 import (
-	"dispatcher/internal/delivery/udp"
-	"encoding/binary"
+	"errors"
 	"fmt"
-	"github.com/chewxy/math32"
 	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// keyframeRequestInterval — наименьший интервал между запросами опорного кадра: пока запрос
+// идёт до ТС, следующие дельты тоже отклоняются
+const keyframeRequestInterval = time.Second
+
 type PointCloudProcessor struct {
-	FilterRadius float32
-	compressors  []PointCloudCompressor
+	fields      PointFields
+	returns     ReturnSelection
+	filters     []PointCloudFilter
+	compressors []PointCloudCompressor
+	sensors     []*Sensor
+	fuser       *FrameFuser
+	clusterer   *Clusterer
+	tracker     *Tracker
+	odometry    *Odometry
+	voxelMap    *VoxelMap
+	grid        *GridBuilder
+	motion      MotionSource
+	keyframe    func()
+	deskewed    atomic.Uint64
+	unskewed    atomic.Uint64
+
+	// filterMu защищает фильтры: кроме Rx их вызывают операторы через OctreeLevels,
+	// а фильтры переиспользуют буферы между кадрами
+	filterMu   sync.Mutex
+	codecMu    sync.Mutex
+	codecStats map[CodecID]*CodecStats
+	cloud      bool
+	vehicleID  string
+	frameID    string
+	seq        uint32
+}
+
+func NewPointCloudProcessor() *PointCloudProcessor {
+	return &PointCloudProcessor{
+		fields: AllPointFields &^ FieldSensor,
+		cloud:  true,
+	}
+}
+
+// AddSensor подключает сенсор к Tx. Кадры нескольких сенсоров объединяются в один.
+func (p *PointCloudProcessor) AddSensor(sensor *Sensor) {
+	p.sensors = append(p.sensors, sensor)
+}
+
+// SetFuser задаёт объединение кадров сенсоров; по умолчанию Tx создаёт его с DefaultFusionConfig
+func (p *PointCloudProcessor) SetFuser(fuser *FrameFuser) {
+	p.fuser = fuser
+}
+
+// SetFilters задаёт цепочку фильтров. В Tx они применяются по порядку к объединённому кадру
+// перед компрессией, в Rx — к принятому кадру после десериализации.
+func (p *PointCloudProcessor) SetFilters(filters ...PointCloudFilter) {
+	p.filters = filters
+}
+
+// SetClusterer включает выделение препятствий после фильтров. В Tx препятствия
+// передаются в кадре, в Rx вычисляются заново по принятым точкам.
+func (p *PointCloudProcessor) SetClusterer(clusterer *Clusterer) {
+	p.clusterer = clusterer
+}
+
+// SetTracker включает сопровождение препятствий между кадрами в Rx. Треки строятся
+// по препятствиям из кадра или выделенным в Rx, если задан SetClusterer.
+func (p *PointCloudProcessor) SetTracker(tracker *Tracker) {
+	p.tracker = tracker
+}
+
+// SetOdometry включает оценку положения ТС по облакам точек в Rx
+func (p *PointCloudProcessor) SetOdometry(odometry *Odometry) {
+	p.odometry = odometry
 }
 
-func NewPointCloudProcessor(filterRadius float32) *PointCloudProcessor {
-	return &PointCloudProcessor{FilterRadius: filterRadius}
+// SetVoxelMap включает накопление карты окружения в Rx по положениям одометрии.
+// В карту попадают только кадры, для которых сопоставление сошлось.
+func (p *PointCloudProcessor) SetVoxelMap(voxelMap *VoxelMap) {
+	p.voxelMap = voxelMap
 }
 
+// VoxelMap возвращает карту окружения или nil, если она не ведётся
+func (p *PointCloudProcessor) VoxelMap() *VoxelMap {
+	return p.voxelMap
+}
+
+// SetGrid включает построение сетки высот по каждому кадру в Tx; cloud задаёт,
+// передавать ли вместе с сеткой облако точек
+func (p *PointCloudProcessor) SetGrid(grid *GridBuilder, cloud bool) {
+	p.grid = grid
+	p.cloud = cloud
+}
+
+// SetMotion включает компенсацию движения ТС за время кадра в Tx: перед фильтрами точки
+// переводятся в положение ТС на момент последнего выстрела кадра
+func (p *PointCloudProcessor) SetMotion(motion MotionSource) {
+	p.motion = motion
+}
+
+// SetKeyframeRequester задаёт, как Rx запрашивает у ТС опорный кадр, если кадр передан
+// изменениями относительно кадра, который не был принят (см. ErrDeltaBaseMissing).
+// Запросы отправляются не чаще раза в keyframeRequestInterval.
+func (p *PointCloudProcessor) SetKeyframeRequester(request func()) {
+	p.keyframe = request
+}
+
+// SetFields задаёт необязательные поля точек, которые Tx передаёт вместе с координатами.
+// При нескольких сенсорах поле sensor добавляется всегда.
+func (p *PointCloudProcessor) SetFields(fields PointFields) {
+	p.fields = fields
+}
+
+// SetCompressors задаёт цепочку компрессоров. В Tx они применяются по порядку,
+// в Rx используются для декомпрессии по идентификаторам из заголовка кадра.
+// Первым в Tx может стоять PointCloudEncoder: он заменяет SerializePoints.
 func (p *PointCloudProcessor) SetCompressors(compressors ...PointCloudCompressor) {
 	p.compressors = compressors
 }
 
-// Tx отвечает за сериализацию и прямой проход по pipeline компрессоров
-func (p *PointCloudProcessor) Tx(in <-chan udp.Packet, out chan<- []byte) {
-	frameBuf := make([][]float32, 0, 40000)
-	prevAzimuth := float32(-1.0)
+// SetReturnSelection задаёт, какие отражения оставлять, когда сенсор работает в двойном режиме
+func (p *PointCloudProcessor) SetReturnSelection(selection ReturnSelection) {
+	p.returns = selection
+}
+
+// SetSource задаёт идентификатор ТС и систему координат точек, которые Tx записывает в заголовок кадра
+func (p *PointCloudProcessor) SetSource(vehicleID string, frameID string) {
+	p.vehicleID = vehicleID
+	p.frameID = frameID
+}
+
+// sensorFrame — кадр, собранный одним сенсором
+type sensorFrame struct {
+	sensor uint8
+	frame  AssembledFrame
+}
+
+// Tx отвечает за декодирование пакетов всех сенсоров, сборку и объединение кадров,
+// сериализацию и прямой проход по pipeline компрессоров
+func (p *PointCloudProcessor) Tx(out chan<- []byte) {
+	if len(p.sensors) == 0 {
+		log.Printf("Processor: сенсоры не заданы, Tx остановлен")
+		return
+	}
+
+	fields := p.fields
+	sensorID := uint16(p.sensors[0].ID)
+	ids := make([]uint8, len(p.sensors))
+	for i, s := range p.sensors {
+		ids[i] = s.ID
+		if s.ground != nil {
+			fields |= s.ground.Fields()
+		}
+	}
+	if len(p.sensors) > 1 {
+		fields |= FieldSensor
+		sensorID = FusedSensorID
+	}
+	if p.fuser == nil {
+		p.fuser = NewFrameFuser(ids, DefaultFusionConfig())
+	}
+
+	frames := make(chan sensorFrame, len(p.sensors))
+	var wg sync.WaitGroup
+	for _, s := range p.sensors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.assemble(s, frames)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(frames)
+	}()
+
 	frameCount := 0
+	send := func(frame FusedFrame) {
+		frameCount++
+		if !frame.Complete {
+			log.Printf("Processor: Кадр #%d собран без части сенсоров: есть %v из %v", frameCount, frame.Sensors, ids)
+		}
+		p.deskew(&frame)
+		frame.Points = p.filter(frame.Points)
+		if p.motion != nil {
+			p.motion.Observe(frame.CaptureTime, frame.Points)
+		}
+		log.Printf("Processor: Обработка кадра #%d: %d точек", frameCount, len(frame.Points))
+		sections := FrameSections{Obstacles: p.cluster(frame.Points)}
+		if p.grid != nil {
+			sections.Grid = p.grid.Build(frame.Points)
+		}
+		data, err := p.encodeFrame(frame.Points, sections, fields, sensorID, frame.CaptureTime)
+		if err != nil {
+			log.Printf("Processor: Ошибка упаковки кадра #%d: %v", frameCount, err)
+			return
+		}
+		select {
+		case out <- data:
+			log.Printf("Processor: Кадр #%d отправлен в канал byteChan (размер: %d байт)", frameCount, len(data))
+		default:
+			log.Printf("Processor: Канал byteChan заполнен, кадр #%d пропущен", frameCount)
+		}
+	}
+	for f := range frames {
+		for _, frame := range p.fuser.Add(f.sensor, f.frame) {
+			send(frame)
+		}
+	}
+	for _, frame := range p.fuser.Flush() {
+		send(frame)
+	}
+}
 
-	for packet := range in {
-		buf := packet.RawData
-		azimuth := float32(uint16(buf[2])|uint16(buf[3])<<8) / 100.0
-		if prevAzimuth >= 0 && azimuth < prevAzimuth {
-			if len(frameBuf) > 0 {
-				frameCount++
-				log.Printf("Processor: Обработка кадра #%d: %d точек", frameCount, len(frameBuf))
-				data, err := serializePoints(frameBuf)
-				if err != nil {
-					log.Printf("Processor: Ошибка сериализации кадра #%d: %v", frameCount, err)
-					continue
-				}
-				for i, compressor := range p.compressors {
-					data, err = compressor.Compress(data)
-					if err != nil {
-						log.Printf("Processor: Ошибка компрессии #%d для кадра #%d: %v", i, frameCount, err)
-						continue
-					}
-				}
-				select {
-				case out <- data:
-					log.Printf("Processor: Кадр #%d отправлен в канал byteChan (размер: %d байт)", frameCount, len(data))
-				default:
-					log.Printf("Processor: Канал byteChan заполнен, кадр #%d пропущен", frameCount)
-				}
+// assemble декодирует пакеты сенсора, переводит точки в систему координат ТС и собирает кадры
+func (p *PointCloudProcessor) assemble(s *Sensor, out chan<- sensorFrame) {
+	returns := make([]LaserReturn, 0, 384) // 12 блоков по 32 измерения
+	mounted := !s.transform.IsIdentity()
+	var frameReceived time.Time // время приёма пакета, с которого начался текущий кадр
+
+	for packet := range s.packets {
+		pkt, err := s.decoder.Decode(packet, returns[:0])
+		if err != nil {
+			log.Printf("Processor: Ошибка декодирования пакета сенсора %d: %v", s.ID, err)
+			continue
+		}
+		returns = pkt.Returns
+		packetTime := SensorTime(packet.ReceivedAt, pkt.Timestamp)
+		if mounted {
+			// Азимут остаётся в системе сенсора: по нему режутся кадры
+			for i := range pkt.Returns {
+				r := &pkt.Returns[i]
+				r.X, r.Y, r.Z = s.transform.Apply(r.X, r.Y, r.Z)
+			}
+		}
+
+		// Фильтры применяются к собранному кадру; здесь отбираются только отражения двойного режима
+		keep := func(r *LaserReturn) bool {
+			return pkt.ReturnMode != ReturnDual || p.returns.Keeps(r.Return)
+		}
+		if frameReceived.IsZero() {
+			frameReceived = packet.ReceivedAt
+		}
+		frames := s.assembler.AddPacket(packetTime, pkt, keep)
+		for _, frame := range frames {
+			frame.ReceivedAt = frameReceived
+			if !frame.Complete {
+				log.Printf("Processor: Кадр сенсора %d неполный: охват %.0f°, пакетов %d", s.ID, frame.Coverage, frame.Packets)
 			}
-			frameBuf = make([][]float32, 0, 40000)
-		}
-		prevAzimuth = azimuth
-		for block := 0; block < 12; block++ {
-			start := block * 100
-			azimuthBlock := float32(uint16(buf[start+2])|uint16(buf[start+3])<<8) / 100.0
-			for laser := 0; laser < 32; laser++ {
-				offset := start + 4 + laser*3
-				dist := float32(uint16(buf[offset])|uint16(buf[offset+1])<<8) * 0.002
-				vertAngle := vlp16VerticalAngle(laser)
-				azimuthRad := azimuthBlock * math32.Pi / 180.0
-				vertRad := vertAngle * math32.Pi / 180.0
-				x := dist * math32.Cos(vertRad) * math32.Sin(azimuthRad)
-				y := dist * math32.Cos(vertRad) * math32.Cos(azimuthRad)
-				z := dist * math32.Sin(vertRad)
-				if p.FilterRadius == 0 ||
-					math32.Abs(x) > p.FilterRadius ||
-					math32.Abs(y) > p.FilterRadius ||
-					math32.Abs(z) > p.FilterRadius {
-					frameBuf = append(frameBuf, []float32{x, y, z})
+			for i := range frame.Points {
+				frame.Points[i].Sensor = s.ID
+			}
+			if modeler, ok := s.decoder.(SensorModeler); ok {
+				// Модель определяется по пакетам и может смениться, поэтому обновляется с каждым кадром
+				if model, ok := modeler.SensorModel(); ok {
+					s.model.Store(&model)
 				}
 			}
+			if s.ground != nil {
+				before := len(frame.Points)
+				frame.Points = s.ground.Apply(frame.Points, s.transform.Translation)
+				log.Printf("Processor: Земля сенсора %d: %d -> %d точек", s.ID, before, len(frame.Points))
+			}
+			out <- sensorFrame{sensor: s.ID, frame: frame}
+		}
+		if len(frames) > 0 {
+			// Пакет разреза содержит начало следующего кадра
+			frameReceived = packet.ReceivedAt
 		}
 	}
 }
 
-// Rx отвечает за обратный проход по pipeline компрессоров и десериализацию
-func (p *PointCloudProcessor) Rx(in <-chan []byte, out chan<- [][]float32) {
-	frameCount := 0
-	for data := range in {
-		frameCount++
-		log.Printf("Rx: получен кадр #%d (размер: %d байт)", frameCount, len(data))
-
+// encodeFrame сериализует точки, прогоняет их через цепочку компрессоров и упаковывает в кадр
+// вместе с необязательными секциями. Без облака точек компрессоры не применяются.
+func (p *PointCloudProcessor) encodeFrame(points []Point, sections FrameSections, fields PointFields, sensorID uint16, captureTime time.Time) ([]byte, error) {
+	var data []byte
+	var resolution float32
+	codecs := make([]CodecID, 0, len(p.compressors))
+	if p.cloud {
+		chain := p.compressors
+		var encoder PointCloudEncoder
+		if len(chain) > 0 {
+			encoder, _ = chain[0].(PointCloudEncoder)
+		}
 		var err error
-		for i := len(p.compressors) - 1; i >= 0; i-- {
-			log.Printf("Rx: декомпрессия #%d для кадра #%d, тип компрессора: %T", i, frameCount, p.compressors[i])
-			before := len(data)
-			data, err = p.compressors[i].Decompress(data)
+		if encoder != nil {
+			data, err = encoder.Encode(points, fields, p.geometries())
 			if err != nil {
-				log.Printf("Rx: ошибка декомпрессии #%d для кадра #%d: %v", i, frameCount, err)
-				break
+				return nil, fmt.Errorf("ошибка компрессии #0 (%s): %w", encoder.Codec(), err)
 			}
-			log.Printf("Rx: декомпрессия #%d успешна, размер до: %d, после: %d байт", i, before, len(data))
+			codecs = append(codecs, encoder.Codec())
+			chain = chain[1:]
+		} else {
+			data = SerializePoints(points, fields)
 		}
-
-		if err != nil {
-			log.Printf("Rx: пропуск кадра #%d из-за ошибки декомпрессии", frameCount)
-			continue
+		for _, compressor := range chain {
+			data, err = compressor.Compress(data)
+			if err != nil {
+				return nil, fmt.Errorf("ошибка компрессии #%d (%s): %w", len(codecs), compressor.Codec(), err)
+			}
+			codecs = append(codecs, compressor.Codec())
+			if q, ok := compressor.(QuantizingCompressor); ok {
+				resolution += q.Resolution()
+			}
 		}
+		// Степень сжатия считается относительно SerializePoints, чтобы цепочки можно было сравнивать
+		serialized := 5 + len(points)*pointSize(fields)
+		log.Printf("Processor: облако %d -> %d байт, сжатие в %.1f раза (кодеки %v)",
+			serialized, len(data), float64(serialized)/float64(len(data)), codecs)
+	}
+	p.seq++
+	return EncodeFrame(FrameHeader{
+		VehicleID:   p.vehicleID,
+		SensorID:    sensorID,
+		FrameID:     p.frameID,
+		Seq:         p.seq,
+		CaptureTime: captureTime,
+		PointCount:  uint32(len(points)),
+		Codecs:      codecs,
+		Resolution:  resolution,
+	}, data, sections)
+}
 
-		pts, err := deserializePoints(data)
+// Rx отвечает за разбор кадра, обратный проход по pipeline компрессоров и десериализацию
+func (p *PointCloudProcessor) Rx(in <-chan []byte, out chan<- Frame) {
+	frameCount := 0
+	var lastKeyframeRequest time.Time
+	for data := range in {
+		frameCount++
+		log.Printf("Rx: получен кадр #%d (размер: %d байт)", frameCount, len(data))
+
+		header, payload, sections, err := DecodeFrame(data)
 		if err != nil {
-			log.Printf("Rx: ошибка десериализации точек для кадра #%d: %v", frameCount, err)
+			log.Printf("Rx: кадр #%d отклонён: %v", frameCount, err)
 			continue
 		}
 
-		log.Printf("Rx: десериализовано %d точек для кадра #%d", len(pts), frameCount)
+		frame := Frame{Header: header, Obstacles: sections.Obstacles, Grid: sections.Grid}
+		if payload != nil {
+			var octree []byte
+			payload, octree, err = p.decompress(header, payload)
+			if err != nil {
+				log.Printf("Rx: пропуск кадра %s/%d из-за ошибки декомпрессии: %v", header.VehicleID, header.Seq, err)
+				if errors.Is(err, ErrDeltaBaseMissing) && p.keyframe != nil && time.Since(lastKeyframeRequest) >= keyframeRequestInterval {
+					log.Printf("Rx: запрос опорного кадра у ТС %s", header.VehicleID)
+					lastKeyframeRequest = time.Now()
+					p.keyframe()
+				}
+				continue
+			}
+
+			// PointCount в заголовке — число точек до компрессии; прореживающие
+			// кодеки (например, воксельный) передают меньше точек
+			pts, fields, err := DeserializePoints(payload)
+			if err != nil {
+				log.Printf("Rx: ошибка десериализации точек для кадра %s/%d: %v", header.VehicleID, header.Seq, err)
+				continue
+			}
+
+			log.Printf("Rx: десериализовано %d точек для кадра %s/%d", len(pts), header.VehicleID, header.Seq)
+			frame.Points, frame.Fields = p.filter(pts), fields
+			if octree != nil {
+				frame.Levels = NewOctreeLevels(octree, p.filter)
+			}
+			if p.clusterer != nil {
+				frame.Obstacles = p.cluster(frame.Points)
+			}
+			if p.odometry != nil {
+				pose := p.odometry.Update(frame.Points)
+				if !pose.Converged {
+					log.Printf("Rx: одометрия кадра %s/%d не сошлась (пар: %d), положение экстраполировано", header.VehicleID, header.Seq, pose.Pairs)
+				}
+				frame.Pose = &pose
+				if p.voxelMap != nil && pose.Converged {
+					update := p.voxelMap.Insert(pose, frame.Points)
+					frame.MapUpdate = &update
+				}
+			}
+		}
+		if p.tracker != nil && frame.Obstacles != nil {
+			frame.Tracks = p.tracker.Update(header.CaptureTime, frame.Obstacles)
+		}
 
 		select {
-		case out <- pts:
-			log.Printf("Rx: кадр #%d отправлен в канал pointsChan", frameCount)
+		case out <- frame:
+			log.Printf("Rx: кадр %s/%d отправлен в канал pointsChan", header.VehicleID, header.Seq)
 		default:
-			log.Printf("Rx: канал pointsChan заполнен, кадр #%d пропущен", frameCount)
+			log.Printf("Rx: канал pointsChan заполнен, кадр %s/%d пропущен", header.VehicleID, header.Seq)
 		}
 	}
 }
 
-func serializePoints(points [][]float32) ([]byte, error) {
-	buf := make([]byte, 0, len(points)*12)
-	for _, pt := range points {
-		for i := 0; i < 3; i++ {
-			b := make([]byte, 4)
-			binary.LittleEndian.PutUint32(b, math32.Float32bits(pt[i]))
-			buf = append(buf, b...)
+// filter прогоняет точки через цепочку фильтров
+func (p *PointCloudProcessor) filter(points []Point) []Point {
+	p.filterMu.Lock()
+	defer p.filterMu.Unlock()
+	for _, filter := range p.filters {
+		before := len(points)
+		points = filter.Filter(points)
+		log.Printf("Processor: фильтр %s: %d -> %d точек", filter.Name(), before, len(points))
+	}
+	return points
+}
+
+// cluster выделяет препятствия, если кластеризация включена; иначе возвращает nil
+func (p *PointCloudProcessor) cluster(points []Point) []Obstacle {
+	if p.clusterer == nil {
+		return nil
+	}
+	obstacles := p.clusterer.Cluster(points)
+	log.Printf("Processor: выделено препятствий: %d", len(obstacles))
+	return obstacles
+}
+
+// deskew компенсирует движение ТС за время кадра, если источник движения задан и скорость известна.
+// После компенсации вся геометрия относится к моменту последнего выстрела, поэтому время кадра
+// переносится на этот момент, а время точек обнуляется. Скорость запрашивается по часам хоста:
+// часы сенсора без PPS не совпадают с часами источника движения.
+func (p *PointCloudProcessor) deskew(frame *FusedFrame) {
+	if p.motion == nil || len(frame.Points) == 0 {
+		return
+	}
+	at := frame.ReceivedAt
+	if at.IsZero() {
+		at = frame.CaptureTime
+	}
+	velocity, ok := p.motion.Velocity(at)
+	if !ok {
+		missing := p.unskewed.Add(1)
+		log.Printf("Processor: скорость ТС неизвестна, кадр передаётся без компенсации движения (всего таких кадров %d)", missing)
+		return
+	}
+	p.deskewed.Add(1)
+	end := Deskew(frame.Points, velocity)
+	frame.CaptureTime = frame.CaptureTime.Add(time.Duration(end) * time.Microsecond)
+	for i := range frame.Points {
+		frame.Points[i].Time = 0
+	}
+}
+
+// DeskewStats возвращает число кадров с компенсацией движения и без неё из-за неизвестной скорости
+func (p *PointCloudProcessor) DeskewStats() (compensated, missing uint64) {
+	return p.deskewed.Load(), p.unskewed.Load()
+}
+
+// decompress применяет декомпрессию в порядке, обратном указанному в заголовке кадра.
+// Вторым значением возвращается поток октодерева, если кадр сжат кодеком octree.
+func (p *PointCloudProcessor) decompress(header FrameHeader, data []byte) ([]byte, []byte, error) {
+	var octree []byte
+	for i := len(header.Codecs) - 1; i >= 0; i-- {
+		compressor := p.compressor(header.Codecs[i])
+		if compressor == nil {
+			return nil, nil, fmt.Errorf("неизвестный кодек %s", header.Codecs[i])
+		}
+		if header.Codecs[i] == CodecOctree {
+			octree = data
 		}
+		before := len(data)
+		start := time.Now()
+		var err error
+		data, err = compressor.Decompress(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("декомпрессия #%d (%s): %w", i, header.Codecs[i], err)
+		}
+		elapsed := time.Since(start)
+		p.countDecode(header.Codecs[i], before, len(data), elapsed)
+		log.Printf("Rx: декомпрессия #%d (%s) успешна, размер до: %d, после: %d байт, за %v", i, header.Codecs[i], before, len(data), elapsed)
+	}
+	return data, octree, nil
+}
+
+// CodecStats — счётчики декомпрессии кадров одним кодеком в Rx
+type CodecStats struct {
+	Codec       string  `json:"codec"`
+	Frames      uint64  `json:"frames"`
+	InputBytes  uint64  `json:"inputBytes"`  // данные на входе кодека
+	OutputBytes uint64  `json:"outputBytes"` // данные после декомпрессии
+	Ratio       float64 `json:"ratio"`       // степень сжатия кодека, OutputBytes / InputBytes
+	DecodeMs    float64 `json:"decodeMs"`    // среднее время декомпрессии кадра, мс
+
+	decodeTime time.Duration
+}
+
+func (p *PointCloudProcessor) countDecode(codec CodecID, before, after int, elapsed time.Duration) {
+	p.codecMu.Lock()
+	defer p.codecMu.Unlock()
+	if p.codecStats == nil {
+		p.codecStats = make(map[CodecID]*CodecStats)
+	}
+	stats, ok := p.codecStats[codec]
+	if !ok {
+		stats = &CodecStats{Codec: codec.String()}
+		p.codecStats[codec] = stats
 	}
-	return buf, nil
+	stats.Frames++
+	stats.InputBytes += uint64(before)
+	stats.OutputBytes += uint64(after)
+	stats.decodeTime += elapsed
 }
 
-func deserializePoints(data []byte) ([][]float32, error) {
-	if len(data)%12 != 0 {
-		return nil, fmt.Errorf("количество байт должно быть кратно 12")
+// CodecStats возвращает счётчики декомпрессии по кодекам, упорядоченные по идентификатору кодека.
+// Безопасно вызывать из другой горутины.
+func (p *PointCloudProcessor) CodecStats() []CodecStats {
+	p.codecMu.Lock()
+	defer p.codecMu.Unlock()
+	codecs := make([]CodecID, 0, len(p.codecStats))
+	for codec := range p.codecStats {
+		codecs = append(codecs, codec)
+	}
+	slices.Sort(codecs)
+	stats := make([]CodecStats, 0, len(codecs))
+	for _, codec := range codecs {
+		s := *p.codecStats[codec]
+		if s.InputBytes > 0 {
+			s.Ratio = float64(s.OutputBytes) / float64(s.InputBytes)
+		}
+		s.DecodeMs = float64(s.decodeTime) / float64(time.Millisecond) / float64(s.Frames)
+		stats = append(stats, s)
 	}
-	pts := make([][]float32, 0, len(data)/12)
-	for i := 0; i+12 <= len(data); i += 12 {
-		x := math32.Float32frombits(binary.LittleEndian.Uint32(data[i : i+4]))
-		y := math32.Float32frombits(binary.LittleEndian.Uint32(data[i+4 : i+8]))
-		z := math32.Float32frombits(binary.LittleEndian.Uint32(data[i+8 : i+12]))
-		pts = append(pts, []float32{x, y, z})
+	return stats
+}
+
+// geometries возвращает геометрию сенсоров, уже собравших кадр
+func (p *PointCloudProcessor) geometries() []SensorGeometry {
+	geometries := make([]SensorGeometry, 0, len(p.sensors))
+	for _, s := range p.sensors {
+		if g, ok := s.Geometry(); ok {
+			geometries = append(geometries, g)
+		}
 	}
-	return pts, nil
+	return geometries
 }
 
-func vlp16VerticalAngle(laser int) float32 {
-	angles := []float32{
-		-15, 1, -13, 3, -11, 5, -9, 7,
-		-7, 9, -5, 11, -3, 13, -1, 15,
+// compressor ищет зарегистрированный компрессор по идентификатору кодека
+func (p *PointCloudProcessor) compressor(id CodecID) PointCloudCompressor {
+	for _, c := range p.compressors {
+		if c.Codec() == id {
+			return c
+		}
 	}
-	return angles[laser%16]
+	return nil
 }
@@ -0,0 +1,384 @@
+package usecase
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/chewxy/math32"
+	"math"
+)
+
+const (
+	// rangeImageAzimuthUnit — разрешение азимута в пакетах сенсора, градусы
+	rangeImageAzimuthUnit = 0.01
+	// rangeImageMaxStep — наибольшая ширина столбца: смещение азимута внутри столбца помещается в байт
+	rangeImageMaxStep = 255 * rangeImageAzimuthUnit
+	// rangeImageHeaderSize — сенсор, кольца, столбцы, ширина столбца, единица дальности,
+	// положение сенсора и число занятых ячеек (без геометрии лазеров и потоков)
+	rangeImageHeaderSize = 1 + 1 + 2 + 4 + 4 + 12*4 + 4
+	// rangeImageStreams — потоки изображения: занятость, дальности, азимуты, интенсивности
+	rangeImageStreams = 4
+)
+
+// RangeImageFields — поля точки, которые переносит изображение дальностей: кольцо и сенсор
+// задаются положением ячейки, интенсивность передаётся отдельным потоком
+const RangeImageFields = FieldIntensity | FieldRing | FieldSensor
+
+// rangeImage — изображение дальностей одного сенсора: кольца × столбцы азимута
+type rangeImage struct {
+	geometry  SensorGeometry
+	ranges    []uint32 // дальность в единицах rangeUnit; 0 — нет измерения
+	azimuths  []uint8  // смещение азимута от начала столбца в rangeImageAzimuthUnit
+	intensity []uint8
+	count     int
+}
+
+// rowPredictor предсказывает значение ячейки по предыдущей занятой ячейке того же кольца,
+// а первую ячейку кольца — по первой занятой ячейке предыдущего кольца
+type rowPredictor struct {
+	prev, first int64
+	started     bool
+}
+
+func (p *rowPredictor) predict() int64 {
+	if p.started {
+		return p.prev
+	}
+	return p.first
+}
+
+func (p *rowPredictor) observe(v int64) {
+	if !p.started {
+		p.first, p.started = v, true
+	}
+	p.prev = v
+}
+
+func (p *rowPredictor) newRow() {
+	p.started = false
+}
+
+// EncodeRangeImage упаковывает кадр в изображения дальностей сенсоров sensors: строки — кольца,
+// столбцы — азимут шириной azimuthStep градусов. В ячейку попадает первое измерение; остальные
+// измерения той же ячейки (второе отражение двойного режима) и точки без измерения сенсора
+// (Distance == 0) не передаются. Координаты восстанавливаются по дальности и азимуту измерения,
+// поэтому изменения координат в Tx (компенсация движения) не сохраняются. Из полей передаются
+// только RangeImageFields.
+//
+// Значения ячеек предсказываются по предыдущей ячейке кольца (см. rowPredictor), остатки сжимаются
+// адаптивным арифметическим кодером, у каждого потока своя модель. Ошибка дальности не превышает
+// половины rangeUnit, азимута — половины rangeImageAzimuthUnit.
+//
+//	fields  uint8
+//	images  uint8
+//	images раз:
+//	  sensor      uint8
+//	  rings       uint8
+//	  columns     uint16     ceil(360 / step)
+//	  step        float32    ширина столбца, градусы
+//	  unit        float32    единица дальности, метры
+//	  rotation    [9]float32 положение сенсора на ТС (см. Transform), по строкам
+//	  translation [3]float32
+//	  count       uint32     число занятых ячеек
+//	  rings раз: elevation, vertOffset, horizOffset float32 (см. LaserModel)
+//	  rangeImageStreams раз: длина uint32 и поток арифметического кодера:
+//	    занятость    биты ячеек по кольцам, затем по столбцам, старший бит байта первый
+//	    дальности    остатки предсказания дальности, zigzag varint
+//	    азимуты      остатки предсказания смещения азимута по модулю 256
+//	    интенсивности остатки предсказания по модулю 256; пуст без FieldIntensity
+func EncodeRangeImage(points []Point, fields PointFields, sensors []SensorGeometry, azimuthStep, rangeUnit float32) ([]byte, error) {
+	if err := ValidateRangeImageConfig(azimuthStep, rangeUnit); err != nil {
+		return nil, err
+	}
+	if len(sensors) > math.MaxUint8 {
+		return nil, fmt.Errorf("слишком много сенсоров для изображения дальностей: %d", len(sensors))
+	}
+	fields &= RangeImageFields
+	step := float64(azimuthStep)
+	columns := rangeImageColumns(azimuthStep)
+
+	images := make([]*rangeImage, len(sensors))
+	byID := make(map[uint8]*rangeImage, len(sensors))
+	for i, geometry := range sensors {
+		rings := len(geometry.Model.Lasers)
+		if rings == 0 || rings > math.MaxUint8 {
+			return nil, fmt.Errorf("сенсор %d: недопустимое число колец %d", geometry.ID, rings)
+		}
+		images[i] = &rangeImage{
+			geometry:  geometry,
+			ranges:    make([]uint32, rings*columns),
+			azimuths:  make([]uint8, rings*columns),
+			intensity: make([]uint8, rings*columns),
+		}
+		byID[geometry.ID] = images[i]
+	}
+	for i, pt := range points {
+		if !(pt.Distance > 0) {
+			continue
+		}
+		img := byID[pt.Sensor]
+		if img == nil {
+			return nil, fmt.Errorf("точка #%d сенсора %d, геометрия которого неизвестна", i, pt.Sensor)
+		}
+		if int(pt.Ring) >= len(img.geometry.Model.Lasers) {
+			return nil, fmt.Errorf("точка #%d: кольцо %d вне модели сенсора %d", i, pt.Ring, pt.Sensor)
+		}
+		azimuth := math.Mod(float64(pt.Azimuth), 360)
+		if azimuth < 0 {
+			azimuth += 360
+		}
+		column := min(int(azimuth/step), columns-1)
+		offset := min(math.Round((azimuth-float64(column)*step)/rangeImageAzimuthUnit), math.MaxUint8)
+		distance := math.Round(float64(pt.Distance) / float64(rangeUnit))
+		if distance > math.MaxUint32 {
+			return nil, fmt.Errorf("точка #%d: дальность %v не помещается в %d единиц %v", i, pt.Distance, uint32(math.MaxUint32), rangeUnit)
+		}
+		cell := int(pt.Ring)*columns + column
+		if img.ranges[cell] != 0 {
+			continue
+		}
+		img.ranges[cell] = max(uint32(distance), 1)
+		img.azimuths[cell] = uint8(offset)
+		img.intensity[cell] = pt.Intensity
+		img.count++
+	}
+
+	buf := []byte{byte(fields), byte(len(images))}
+	for _, img := range images {
+		g := img.geometry
+		rings := len(g.Model.Lasers)
+		buf = append(buf, g.ID, byte(rings))
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(columns))
+		buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(azimuthStep))
+		buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(rangeUnit))
+		for _, row := range g.Transform.Rotation {
+			for _, v := range row {
+				buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(v))
+			}
+		}
+		for _, v := range g.Transform.Translation {
+			buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(v))
+		}
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(img.count))
+		for _, laser := range g.Model.Lasers {
+			buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(laser.Elevation))
+			buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(laser.VertOffset))
+			buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(laser.HorizOffset))
+		}
+
+		var streams [rangeImageStreams]*arithmeticEncoder
+		for i := range streams {
+			streams[i] = newArithmeticEncoder()
+		}
+		occupancy, ranges, azimuths, intensity := streams[0], streams[1], streams[2], streams[3]
+		var rangePred, azimuthPred, intensityPred rowPredictor
+		var bits byte
+		var varint []byte
+		for cell, r := range img.ranges {
+			if cell%columns == 0 {
+				rangePred.newRow()
+				azimuthPred.newRow()
+				intensityPred.newRow()
+			}
+			bits <<= 1
+			if r != 0 {
+				bits |= 1
+				varint = binary.AppendVarint(varint[:0], int64(r)-rangePred.predict())
+				for _, b := range varint {
+					ranges.Encode(b)
+				}
+				rangePred.observe(int64(r))
+				azimuths.Encode(byte(int64(img.azimuths[cell]) - azimuthPred.predict()))
+				azimuthPred.observe(int64(img.azimuths[cell]))
+				if fields&FieldIntensity != 0 {
+					intensity.Encode(byte(int64(img.intensity[cell]) - intensityPred.predict()))
+					intensityPred.observe(int64(img.intensity[cell]))
+				}
+			}
+			if cell%8 == 7 {
+				occupancy.Encode(bits)
+				bits = 0
+			}
+		}
+		if tail := len(img.ranges) % 8; tail != 0 {
+			occupancy.Encode(bits << (8 - tail))
+		}
+		for _, stream := range streams {
+			data := stream.Finish()
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(len(data)))
+			buf = append(buf, data...)
+		}
+	}
+	return buf, nil
+}
+
+// DecodeRangeImage восстанавливает точки из изображений, упакованных EncodeRangeImage: координаты
+// вычисляются по модели сенсора и переводятся в систему ТС. Точки идут по сенсорам, кольцам и
+// столбцам; у них заполнены Distance и Azimuth.
+func DecodeRangeImage(data []byte) ([]Point, PointFields, error) {
+	if len(data) < 2 {
+		return nil, 0, fmt.Errorf("слишком короткие данные изображения дальностей: %d байт", len(data))
+	}
+	fields := PointFields(data[0])
+	if fields&^RangeImageFields != 0 {
+		return nil, 0, fmt.Errorf("недопустимые поля изображения дальностей: 0x%02x", uint8(fields))
+	}
+	images := int(data[1])
+	data = data[2:]
+	var pts []Point
+	for range images {
+		if len(data) < rangeImageHeaderSize {
+			return nil, 0, fmt.Errorf("обрезан заголовок изображения дальностей: %d байт", len(data))
+		}
+		var g SensorGeometry
+		g.ID = data[0]
+		rings := int(data[1])
+		columns := int(binary.LittleEndian.Uint16(data[2:]))
+		step := math32.Float32frombits(binary.LittleEndian.Uint32(data[4:]))
+		unit := math32.Float32frombits(binary.LittleEndian.Uint32(data[8:]))
+		pos := 12
+		for i := range 3 {
+			for j := range 3 {
+				g.Transform.Rotation[i][j] = math32.Float32frombits(binary.LittleEndian.Uint32(data[pos:]))
+				pos += 4
+			}
+		}
+		for i := range 3 {
+			g.Transform.Translation[i] = math32.Float32frombits(binary.LittleEndian.Uint32(data[pos:]))
+			pos += 4
+		}
+		count := int(binary.LittleEndian.Uint32(data[pos:]))
+		pos += 4
+		if !(step >= rangeImageAzimuthUnit) || step > rangeImageMaxStep || columns != rangeImageColumns(step) || !(unit > 0) || rings == 0 {
+			return nil, 0, fmt.Errorf("сенсор %d: недопустимые параметры изображения дальностей: колец %d, столбцов %d, шаг %v°, единица %v м", g.ID, rings, columns, step, unit)
+		}
+		if count > rings*columns {
+			return nil, 0, fmt.Errorf("сенсор %d: занятых ячеек %d больше, чем ячеек %d", g.ID, count, rings*columns)
+		}
+		if len(pts)+count > maxDecodedPoints {
+			return nil, 0, fmt.Errorf("сенсор %d: слишком много точек в изображениях дальностей: %d, не больше %d", g.ID, len(pts)+count, maxDecodedPoints)
+		}
+		data = data[pos:]
+		if len(data) < rings*12 {
+			return nil, 0, fmt.Errorf("сенсор %d: обрезана геометрия лазеров", g.ID)
+		}
+		g.Model.Lasers = make([]LaserModel, rings)
+		for i := range g.Model.Lasers {
+			g.Model.Lasers[i] = LaserModel{
+				Elevation:   math32.Float32frombits(binary.LittleEndian.Uint32(data[i*12:])),
+				VertOffset:  math32.Float32frombits(binary.LittleEndian.Uint32(data[i*12+4:])),
+				HorizOffset: math32.Float32frombits(binary.LittleEndian.Uint32(data[i*12+8:])),
+			}
+		}
+		data = data[rings*12:]
+		var streams [rangeImageStreams]*arithmeticDecoder
+		for i := range streams {
+			if len(data) < 4 {
+				return nil, 0, fmt.Errorf("сенсор %d: нет потока изображения дальностей #%d", g.ID, i)
+			}
+			size := int(binary.LittleEndian.Uint32(data))
+			if size > len(data)-4 {
+				return nil, 0, fmt.Errorf("сенсор %d: поток #%d длиной %d байт обрезан", g.ID, i, size)
+			}
+			streams[i] = newArithmeticDecoder(data[4 : 4+size])
+			data = data[4+size:]
+		}
+		occupancy, ranges, azimuths, intensity := streams[0], streams[1], streams[2], streams[3]
+
+		mounted := !g.Transform.IsIdentity()
+		start := len(pts)
+		var rangePred, azimuthPred, intensityPred rowPredictor
+		var bits byte
+		for cell := range rings * columns {
+			ring, column := cell/columns, cell%columns
+			if column == 0 {
+				rangePred.newRow()
+				azimuthPred.newRow()
+				intensityPred.newRow()
+			}
+			if cell%8 == 0 {
+				bits = occupancy.Decode()
+				if occupancy.Overrun() {
+					return nil, 0, fmt.Errorf("сенсор %d: обрезан поток занятости", g.ID)
+				}
+			}
+			occupied := bits&0x80 != 0
+			bits <<= 1
+			if !occupied {
+				continue
+			}
+			if len(pts)-start >= count {
+				return nil, 0, fmt.Errorf("сенсор %d: занятых ячеек больше заявленных %d", g.ID, count)
+			}
+			residual, err := decodeArithmeticVarint(ranges)
+			if err != nil {
+				return nil, 0, fmt.Errorf("сенсор %d: %w", g.ID, err)
+			}
+			r := rangePred.predict() + residual
+			if r <= 0 || r > math.MaxUint32 {
+				return nil, 0, fmt.Errorf("сенсор %d: повреждён поток дальностей", g.ID)
+			}
+			rangePred.observe(r)
+			offset := int64(byte(azimuthPred.predict() + int64(azimuths.Decode())))
+			azimuthPred.observe(offset)
+
+			pt := Point{
+				Ring:     uint8(ring),
+				Sensor:   g.ID,
+				Distance: float32(float64(r) * float64(unit)),
+				Azimuth:  float32(float64(column)*float64(step) + float64(offset)*rangeImageAzimuthUnit),
+			}
+			if fields&FieldIntensity != 0 {
+				pt.Intensity = byte(intensityPred.predict() + int64(intensity.Decode()))
+				intensityPred.observe(int64(pt.Intensity))
+			}
+			if ranges.Overrun() || azimuths.Overrun() || intensity.Overrun() {
+				return nil, 0, fmt.Errorf("сенсор %d: обрезан поток изображения дальностей", g.ID)
+			}
+			pt.X, pt.Y, pt.Z = g.Model.Project(pt.Ring, pt.Azimuth, pt.Distance)
+			if mounted {
+				pt.X, pt.Y, pt.Z = g.Transform.Apply(pt.X, pt.Y, pt.Z)
+			}
+			pts = append(pts, pt)
+		}
+		if len(pts)-start != count {
+			return nil, 0, fmt.Errorf("сенсор %d: в изображении %d занятых ячеек, заявлено %d", g.ID, len(pts)-start, count)
+		}
+	}
+	if len(data) != 0 {
+		return nil, 0, fmt.Errorf("изображение дальностей: лишние %d байт", len(data))
+	}
+	return pts, fields, nil
+}
+
+// ValidateRangeImageConfig проверяет ширину столбца (градусы) и единицу дальности (метры)
+// изображения дальностей
+func ValidateRangeImageConfig(azimuthStep, rangeUnit float32) error {
+	if !(azimuthStep >= rangeImageAzimuthUnit) || azimuthStep > rangeImageMaxStep {
+		return fmt.Errorf("ширина столбца изображения дальностей должна быть от %v до %v°: %v", rangeImageAzimuthUnit, rangeImageMaxStep, azimuthStep)
+	}
+	if !(rangeUnit > 0) || math32.IsInf(rangeUnit, 1) {
+		return fmt.Errorf("единица дальности должна быть положительной: %v", rangeUnit)
+	}
+	return nil
+}
+
+// rangeImageColumns возвращает число столбцов изображения для ширины столбца step
+func rangeImageColumns(step float32) int {
+	return int(math.Ceil(360 / float64(step)))
+}
+
+// decodeArithmeticVarint читает из арифметического потока число, записанное binary.AppendVarint
+func decodeArithmeticVarint(dec *arithmeticDecoder) (int64, error) {
+	var buf [binary.MaxVarintLen64]byte
+	for i := range buf {
+		buf[i] = dec.Decode()
+		if buf[i] < 0x80 {
+			v, n := binary.Varint(buf[:i+1])
+			if n <= 0 {
+				break
+			}
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("повреждён поток дальностей")
+}
@@ -0,0 +1,160 @@
+package usecase
+
+import (
+	"encoding/binary"
+	"github.com/chewxy/math32"
+	"math"
+	"testing"
+)
+
+func vlp16Model() SensorModel {
+	elevations := []float32{-15, -13, -11, -9, -7, -5, -3, -1, 1, 3, 5, 7, 9, 11, 13, 15}
+	lasers := make([]LaserModel, len(elevations))
+	for i, elevation := range elevations {
+		lasers[i] = LaserModel{Elevation: elevation}
+	}
+	return SensorModel{Lasers: lasers}
+}
+
+func sinDegrees(degrees float32) float32 {
+	return float32(math.Sin(float64(degrees) * math.Pi / 180))
+}
+
+func TestRangeImageRoundTrip(t *testing.T) {
+	sensors := []SensorGeometry{
+		{ID: 1, Model: vlp16Model(), Transform: IdentityTransform()},
+		{ID: 7, Model: vlp16Model(), Transform: NewTransformRPY([3]float32{-4, 1, 2}, 3, 5, 170)},
+	}
+	// Оборот каждого сенсора с шагом 0,2° по азимуту и пропусками без отражения
+	var points []Point
+	for _, geometry := range sensors {
+		for step := 0; step < 1800; step++ {
+			azimuth := float32(step)*0.2 + 0.013
+			for ring := range geometry.Model.Lasers {
+				if (step+ring)%17 == 0 {
+					continue
+				}
+				distance := 10 + 4*sinDegrees(3*azimuth) + float32(ring)*0.3
+				x, y, z := geometry.Model.Project(uint8(ring), azimuth, distance)
+				x, y, z = geometry.Transform.Apply(x, y, z)
+				points = append(points, Point{
+					X: x, Y: y, Z: z,
+					Intensity: uint8(40 + ring),
+					Ring:      uint8(ring),
+					Sensor:    geometry.ID,
+					Distance:  distance,
+					Azimuth:   azimuth,
+				})
+			}
+		}
+	}
+	const rangeUnit = 0.002
+	data, err := EncodeRangeImage(points, FieldIntensity|FieldRing|FieldSensor|FieldTime, sensors, 0.2, rangeUnit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw := len(SerializePoints(points, FieldIntensity|FieldRing|FieldSensor)); len(data) >= raw/4 {
+		t.Errorf("изображение %d байт, облако %d байт", len(data), raw)
+	}
+
+	decoded, fields, err := DecodeRangeImage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields != FieldIntensity|FieldRing|FieldSensor {
+		t.Errorf("поля %v", fields)
+	}
+	if len(decoded) != len(points) {
+		t.Fatalf("точек %d, ожидалось %d", len(decoded), len(points))
+	}
+	// Точки идут по сенсорам, кольцам и столбцам; сопоставляем по этому ключу
+	type cell struct {
+		sensor, ring uint8
+		column       int
+	}
+	want := make(map[cell]Point, len(points))
+	for _, p := range points {
+		want[cell{p.Sensor, p.Ring, int(p.Azimuth / 0.2)}] = p
+	}
+	// Ошибка дальности до rangeUnit/2 и азимута до 0,005° дают на 20 м меньше 3 мм
+	for _, got := range decoded {
+		p, ok := want[cell{got.Sensor, got.Ring, int(got.Azimuth / 0.2)}]
+		if !ok {
+			t.Fatalf("лишняя точка %+v", got)
+		}
+		dx, dy, dz := float64(got.X-p.X), float64(got.Y-p.Y), float64(got.Z-p.Z)
+		if d := math.Sqrt(dx*dx + dy*dy + dz*dz); d > 0.003 || got.Intensity != p.Intensity {
+			t.Fatalf("точка %+v, ожидалось %+v (ошибка %.4f м)", got, p, d)
+		}
+	}
+}
+
+func TestValidateRangeImageConfig(t *testing.T) {
+	if err := ValidateRangeImageConfig(0.2, 0.002); err != nil {
+		t.Error(err)
+	}
+	for _, c := range [][2]float32{{0, 0.002}, {0.005, 0.002}, {3, 0.002}, {0.2, 0}, {0.2, float32(math.Inf(1))}, {float32(math.NaN()), 0.002}} {
+		if err := ValidateRangeImageConfig(c[0], c[1]); err == nil {
+			t.Errorf("принята ширина столбца %v и единица дальности %v", c[0], c[1])
+		}
+		if _, err := EncodeRangeImage(nil, 0, nil, c[0], c[1]); err == nil {
+			t.Errorf("кодер принял ширину столбца %v и единицу дальности %v", c[0], c[1])
+		}
+	}
+}
+
+// craftedRangeImage — изображение одного сенсора без полей точки с шагом 0,01° и потоками streams
+func craftedRangeImage(rings uint8, count uint32, streams [rangeImageStreams][]byte) []byte {
+	buf := []byte{0, 1, 0, rings}
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(rangeImageColumns(rangeImageAzimuthUnit)))
+	buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(rangeImageAzimuthUnit))
+	buf = binary.LittleEndian.AppendUint32(buf, math32.Float32bits(0.01))
+	buf = append(buf, make([]byte, 12*4)...)
+	buf = binary.LittleEndian.AppendUint32(buf, count)
+	buf = append(buf, make([]byte, int(rings)*12)...)
+	for _, stream := range streams {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(stream)))
+		buf = append(buf, stream...)
+	}
+	return buf
+}
+
+// encodeArithmetic сжимает байты data арифметическим кодером
+func encodeArithmetic(data []byte) []byte {
+	enc := newArithmeticEncoder()
+	for _, b := range data {
+		enc.Encode(b)
+	}
+	return enc.Finish()
+}
+
+func TestRangeImageRejectsCraftedStream(t *testing.T) {
+	// Заголовок допускает 255 колец по 36000 столбцов, а пустые потоки занимают несколько байт
+	if _, _, err := DecodeRangeImage(craftedRangeImage(255, 1<<21, [rangeImageStreams][]byte{})); err == nil {
+		t.Errorf("принято %d точек", 1<<21)
+	}
+
+	// Первые 8000 ячеек кольца заняты, все дальности равны 1000 единиц
+	const occupied = 8000
+	columns := rangeImageColumns(rangeImageAzimuthUnit)
+	occupancy := make([]byte, columns/8)
+	for i := range occupied / 8 {
+		occupancy[i] = 0xff
+	}
+	residuals := binary.AppendVarint(nil, 1000)
+	residuals = append(residuals, make([]byte, occupied-1)...)
+	streams := [rangeImageStreams][]byte{
+		encodeArithmetic(occupancy),
+		encodeArithmetic(residuals),
+		encodeArithmetic(make([]byte, occupied)),
+	}
+	points, _, err := DecodeRangeImage(craftedRangeImage(1, occupied, streams))
+	if err != nil || len(points) != occupied {
+		t.Fatalf("%d точек, %v", len(points), err)
+	}
+	// Из пустого потока декодер читает нули и выдаёт нулевые смещения азимута без конца
+	streams[2] = nil
+	if _, _, err := DecodeRangeImage(craftedRangeImage(1, occupied, streams)); err == nil {
+		t.Error("принят поток азимутов, прочитанный далеко за концом")
+	}
+}
@@ -0,0 +1,227 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// FrameReorderer восстанавливает порядок кадров одного соединения ТС. Кадры идут отдельными
+// потоками QUIC и читаются параллельно, поэтому могут прийти не в порядке Seq, а дельта-кодек
+// принимает изменения только относительно предыдущего кадра. Кадры ждут предшественников
+// не дольше timeout, и ждущих не больше window; после этого пропуск в нумерации считается потерей,
+// и доставка продолжается со следующего принятого кадра. Кадры старше уже доставленных
+// отбрасываются. Методы безопасны для одновременного вызова.
+//
+// Готовые кадры доставляет одна горутина вне блокировки: медленный получатель не задерживает
+// Add и таймер, а порядок доставки сохраняется.
+type FrameReorderer struct {
+	window  int
+	timeout time.Duration
+	deliver func([]byte)
+	notify  chan struct{} // будит горутину доставки; ёмкость 1
+	done    chan struct{} // закрывается, когда горутина доставки завершилась
+
+	mu      sync.Mutex
+	pending map[uint32][]byte
+	ready   [][]byte // кадры, готовые к доставке, по порядку
+	next    uint32   // Seq следующего ожидаемого кадра
+	started bool     // был ли доставлен хотя бы один кадр; до этого next — наименьший принятый Seq
+	timer   *time.Timer
+	gen     uint64 // номер текущего ожидания
+	closed  bool
+	skipped uint64
+	stale   uint64
+}
+
+// NewFrameReorderer создаёт буфер упорядочивания и запускает горутину доставки; deliver
+// вызывается для кадров в порядке Seq из этой горутины. Close останавливает её.
+func NewFrameReorderer(window int, timeout time.Duration, deliver func([]byte)) (*FrameReorderer, error) {
+	if window < 1 {
+		return nil, fmt.Errorf("окно упорядочивания кадров должно быть положительным: %d", window)
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("время ожидания пропущенного кадра должно быть положительным: %v", timeout)
+	}
+	r := &FrameReorderer{
+		window:  window,
+		timeout: timeout,
+		deliver: deliver,
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		pending: make(map[uint32][]byte),
+	}
+	go r.run()
+	return r, nil
+}
+
+// Add принимает кадр и передаёт в доставку все кадры, которые теперь идут по порядку. Кадр, номер
+// которого не удалось прочитать, передаётся сразу: Rx отклонит его с понятной ошибкой.
+func (r *FrameReorderer) Add(data []byte) {
+	seq, err := FrameSeq(data)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	if err != nil {
+		r.ready = append(r.ready, data)
+		r.signal()
+		return
+	}
+	switch {
+	case len(r.pending) == 0 && !r.started:
+		r.next = seq
+	case seqBefore(seq, r.next):
+		if r.started {
+			r.stale++
+			log.Printf("Reorder: кадр %d пришёл после кадра %d и отброшен", seq, r.next-1)
+			return
+		}
+		r.next = seq
+	}
+	if _, ok := r.pending[seq]; ok {
+		return
+	}
+	r.pending[seq] = data
+	// Первый кадр соединения ждёт возможных предшественников так же, как кадр после пропуска
+	if r.started {
+		r.drain()
+	}
+	if len(r.pending) > r.window {
+		r.skip()
+	}
+	r.arm()
+	r.signal()
+}
+
+// Close доставляет оставшиеся кадры по порядку, прекращает приём новых
+// и ждёт, пока горутина доставки завершится
+func (r *FrameReorderer) Close() {
+	r.mu.Lock()
+	if !r.closed {
+		for len(r.pending) > 0 {
+			r.skip()
+		}
+		r.closed = true
+		r.disarm()
+		r.signal()
+	}
+	r.mu.Unlock()
+	<-r.done
+}
+
+// Stats возвращает число пропусков в нумерации, по которым ожидание прекращено,
+// и число отброшенных устаревших кадров
+func (r *FrameReorderer) Stats() (skipped, stale uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.skipped, r.stale
+}
+
+// run доставляет готовые кадры, пока буфер не закрыт и все кадры не доставлены
+func (r *FrameReorderer) run() {
+	defer close(r.done)
+	for range r.notify {
+		r.mu.Lock()
+		ready, closed := r.ready, r.closed
+		r.ready = nil
+		r.mu.Unlock()
+		for _, data := range ready {
+			r.deliver(data)
+		}
+		// После закрытия новые кадры не появляются: всё готовое уже забрано
+		if closed {
+			return
+		}
+	}
+}
+
+// signal будит горутину доставки, если есть готовые кадры или буфер закрыт
+func (r *FrameReorderer) signal() {
+	if len(r.ready) == 0 && !r.closed {
+		return
+	}
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// drain переносит в очередь доставки кадры, идущие подряд начиная с next. Если что-то
+// доставлено, ожидание следующего пропуска отсчитывается заново.
+func (r *FrameReorderer) drain() {
+	for {
+		data, ok := r.pending[r.next]
+		if !ok {
+			return
+		}
+		delete(r.pending, r.next)
+		r.ready = append(r.ready, data)
+		r.next++
+		r.started = true
+		r.disarm()
+	}
+}
+
+// skip перестаёт ждать кадры до ближайшего принятого и передаёт его в доставку вместе со следующими
+func (r *FrameReorderer) skip() {
+	first, ok := uint32(0), false
+	for seq := range r.pending {
+		if !ok || seqBefore(seq, first) {
+			first, ok = seq, true
+		}
+	}
+	if !ok {
+		return
+	}
+	if r.started && first != r.next {
+		r.skipped++
+		log.Printf("Reorder: кадры %d..%d не получены, доставка продолжается с %d", r.next, first-1, first)
+	}
+	r.next = first
+	r.drain()
+}
+
+// arm запускает ожидание пропущенного кадра, если есть кадры, ждущие доставки,
+// и останавливает его, если ждать больше нечего
+func (r *FrameReorderer) arm() {
+	if len(r.pending) == 0 {
+		r.disarm()
+		return
+	}
+	if r.timer != nil {
+		return
+	}
+	gen := r.gen
+	r.timer = time.AfterFunc(r.timeout, func() { r.expire(gen) })
+}
+
+// disarm останавливает ожидание; уже сработавший таймер узнаёт об этом по смене gen
+func (r *FrameReorderer) disarm() {
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+		r.gen++
+	}
+}
+
+func (r *FrameReorderer) expire(gen uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || gen != r.gen {
+		return
+	}
+	r.timer = nil
+	r.gen++
+	r.skip()
+	r.arm()
+	r.signal()
+}
+
+// seqBefore сравнивает номера кадров с учётом переполнения uint32
+func seqBefore(a, b uint32) bool {
+	return int32(a-b) < 0
+}
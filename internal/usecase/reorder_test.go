@@ -0,0 +1,212 @@
+package usecase
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// reorderFrame — кадр без облака точек с заданным номером
+func reorderFrame(t *testing.T, seq uint32) []byte {
+	t.Helper()
+	data, err := EncodeFrame(FrameHeader{VehicleID: "v1", FrameID: "lidar", Seq: seq, CaptureTime: time.Unix(0, 0)}, []byte{1}, FrameSections{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// reorderSink собирает номера доставленных кадров
+type reorderSink struct {
+	mu   sync.Mutex
+	seqs []uint32
+}
+
+func (s *reorderSink) deliver(data []byte) {
+	h, _, _, err := DecodeFrame(data)
+	if err != nil {
+		panic(err)
+	}
+	s.mu.Lock()
+	s.seqs = append(s.seqs, h.Seq)
+	s.mu.Unlock()
+}
+
+func (s *reorderSink) delivered() []uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.seqs)
+}
+
+// waitDelivered ждёт, пока горутина доставки передаст не меньше n кадров
+func (s *reorderSink) waitDelivered(n int) []uint32 {
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.delivered()) < n && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	return s.delivered()
+}
+
+func TestFrameSeq(t *testing.T) {
+	seq, err := FrameSeq(reorderFrame(t, 123456))
+	if err != nil || seq != 123456 {
+		t.Fatalf("FrameSeq = %d, %v", seq, err)
+	}
+	if _, err := FrameSeq([]byte("LDRF")); err == nil {
+		t.Fatal("обрезанный кадр принят")
+	}
+}
+
+func TestFrameReordererRestoresOrder(t *testing.T) {
+	sink := &reorderSink{}
+	r, err := NewFrameReorderer(16, time.Hour, sink.deliver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// Первый кадр соединения ждёт предшественников, поэтому ранний кадр 11 не отбрасывается
+	for _, seq := range []uint32{12, 11, 10, 13, 15, 14, 16} {
+		r.Add(reorderFrame(t, seq))
+	}
+	if got := sink.delivered(); len(got) != 0 {
+		t.Fatalf("кадры доставлены до первого пропуска: %v", got)
+	}
+	r.Close()
+	want := []uint32{10, 11, 12, 13, 14, 15, 16}
+	if got := sink.delivered(); !slices.Equal(got, want) {
+		t.Fatalf("доставлено %v, ожидалось %v", got, want)
+	}
+}
+
+func TestFrameReordererDropsStale(t *testing.T) {
+	sink := &reorderSink{}
+	r, err := NewFrameReorderer(2, time.Hour, sink.deliver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, seq := range []uint32{1, 2, 3} {
+		r.Add(reorderFrame(t, seq))
+	}
+	// Окно переполнено: доставка началась с кадра 1, дальше кадры идут без ожидания
+	for _, seq := range []uint32{4, 2, 5} {
+		r.Add(reorderFrame(t, seq))
+	}
+	want := []uint32{1, 2, 3, 4, 5}
+	if got := sink.waitDelivered(len(want)); !slices.Equal(got, want) {
+		t.Fatalf("доставлено %v, ожидалось %v", got, want)
+	}
+	if skipped, stale := r.Stats(); skipped != 0 || stale != 1 {
+		t.Fatalf("пропусков %d, устаревших %d; ожидалось 0 и 1", skipped, stale)
+	}
+}
+
+func TestFrameReordererSkipsLostFrame(t *testing.T) {
+	sink := &reorderSink{}
+	r, err := NewFrameReorderer(3, time.Hour, sink.deliver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, seq := range []uint32{1, 2, 3, 4} {
+		r.Add(reorderFrame(t, seq))
+	}
+	// Кадр 5 потерян: ждём, пока в окне не окажется больше трёх кадров
+	for _, seq := range []uint32{7, 6, 8} {
+		r.Add(reorderFrame(t, seq))
+	}
+	if got := sink.waitDelivered(4); !slices.Equal(got, []uint32{1, 2, 3, 4}) {
+		t.Fatalf("доставлено %v до переполнения окна", got)
+	}
+	r.Add(reorderFrame(t, 9))
+	r.Add(reorderFrame(t, 5))
+	want := []uint32{1, 2, 3, 4, 6, 7, 8, 9}
+	if got := sink.waitDelivered(len(want)); !slices.Equal(got, want) {
+		t.Fatalf("доставлено %v, ожидалось %v", got, want)
+	}
+	if skipped, stale := r.Stats(); skipped != 1 || stale != 1 {
+		t.Fatalf("пропусков %d, устаревших %d; ожидалось 1 и 1", skipped, stale)
+	}
+}
+
+func TestFrameReordererTimeout(t *testing.T) {
+	sink := &reorderSink{}
+	r, err := NewFrameReorderer(16, 20*time.Millisecond, sink.deliver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.Add(reorderFrame(t, 1))
+	r.Add(reorderFrame(t, 3))
+	if got := sink.waitDelivered(2); !slices.Equal(got, []uint32{1, 3}) {
+		t.Fatalf("после ожидания доставлено %v, ожидалось [1 3]", got)
+	}
+}
+
+func TestFrameReordererSeqWrap(t *testing.T) {
+	sink := &reorderSink{}
+	r, err := NewFrameReorderer(16, time.Hour, sink.deliver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, seq := range []uint32{1, 0, 0xFFFFFFFF, 0xFFFFFFFE} {
+		r.Add(reorderFrame(t, seq))
+	}
+	r.Close()
+	want := []uint32{0xFFFFFFFE, 0xFFFFFFFF, 0, 1}
+	if got := sink.delivered(); !slices.Equal(got, want) {
+		t.Fatalf("доставлено %v, ожидалось %v", got, want)
+	}
+}
+
+func TestFrameReordererDeliversOutsideLock(t *testing.T) {
+	var r *FrameReorderer
+	release := make(chan struct{})
+	var got []uint32
+	r, err := NewFrameReorderer(1, time.Hour, func(data []byte) {
+		// Получатель может обращаться к буферу и задерживаться, не блокируя Add
+		r.Stats()
+		<-release
+		seq, _ := FrameSeq(data)
+		got = append(got, seq)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var frames [][]byte
+	for seq := uint32(1); seq <= 20; seq++ {
+		frames = append(frames, reorderFrame(t, seq))
+	}
+	added := make(chan struct{})
+	go func() {
+		for _, data := range frames {
+			r.Add(data)
+		}
+		close(added)
+	}()
+	select {
+	case <-added:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Add заблокирован медленным получателем")
+	}
+	close(release)
+	r.Close()
+	want := make([]uint32, 0, 20)
+	for seq := uint32(1); seq <= 20; seq++ {
+		want = append(want, seq)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("доставлено %v, ожидалось %v", got, want)
+	}
+}
+
+func TestNewFrameReordererValidates(t *testing.T) {
+	if _, err := NewFrameReorderer(0, time.Second, func([]byte) {}); err == nil {
+		t.Fatal("нулевое окно принято")
+	}
+	if _, err := NewFrameReorderer(1, 0, func([]byte) {}); err == nil {
+		t.Fatal("нулевое время ожидания принято")
+	}
+}
@@ -0,0 +1,55 @@
+package usecase
+
+import (
+	"dispatcher/internal/delivery/udp"
+	"sync/atomic"
+)
+
+// Sensor — один LiDAR на ТС: источник пакетов, декодер, положение на ТС и сборщик кадров
+type Sensor struct {
+	ID        uint8
+	packets   <-chan udp.Packet
+	decoder   Decoder
+	transform Transform
+	assembler *FrameAssembler
+	ground    *GroundSegmenter
+	model     atomic.Pointer[SensorModel] // геометрия лазеров на момент последнего кадра
+}
+
+func NewSensor(id uint8, packets <-chan udp.Packet, decoder Decoder, transform Transform, assembler *FrameAssembler) *Sensor {
+	return &Sensor{
+		ID:        id,
+		packets:   packets,
+		decoder:   decoder,
+		transform: transform,
+		assembler: assembler,
+	}
+}
+
+// SetGround включает сегментацию земли в кадрах сенсора
+func (s *Sensor) SetGround(ground *GroundSegmenter) {
+	s.ground = ground
+}
+
+// Stats возвращает счётчики кадров сенсора; безопасно вызывать из другой горутины
+func (s *Sensor) Stats() FrameAssemblerStats {
+	return s.assembler.Stats()
+}
+
+// SensorGeometry — всё, что нужно для восстановления координат по измерениям сенсора:
+// геометрия лазеров и положение сенсора на ТС
+type SensorGeometry struct {
+	ID        uint8
+	Model     SensorModel
+	Transform Transform
+}
+
+// Geometry возвращает геометрию сенсора, если декодер её знает и сенсор уже собрал кадр;
+// безопасно вызывать из другой горутины
+func (s *Sensor) Geometry() (SensorGeometry, bool) {
+	model := s.model.Load()
+	if model == nil {
+		return SensorGeometry{}, false
+	}
+	return SensorGeometry{ID: s.ID, Model: *model, Transform: s.transform}, true
+}
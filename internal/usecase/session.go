@@ -0,0 +1,233 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// VehicleStats содержит сведения о сессии ТС для операторов
+type VehicleStats struct {
+	ID          string       `json:"id"`
+	Active      bool         `json:"active"`
+	Connections int32        `json:"connections"`
+	ConnectedAt time.Time    `json:"connectedAt"`
+	LastSeen    time.Time    `json:"lastSeen"`
+	Frames      uint64       `json:"frames"`
+	Bytes       uint64       `json:"bytes"`
+	Dropped     uint64       `json:"dropped"` // кадры, отброшенные из-за переполнения очереди Rx
+	Operators   int          `json:"operators"`
+	Codecs      []CodecStats `json:"codecs"` // степень сжатия и время декомпрессии по кодекам
+}
+
+// VehicleSession — сессия одного ТС со своим Rx pipeline и рассылкой операторам
+type VehicleSession struct {
+	ID  string
+	Hub *Hub[Frame]
+
+	connectedAt time.Time
+	lastSeen    atomic.Int64
+	connections atomic.Int32
+	frames      atomic.Uint64
+	bytes       atomic.Uint64
+	dropped     atomic.Uint64
+	grid        atomic.Pointer[OccupancyGrid]
+	voxelMap    *VoxelMap
+	processor   *PointCloudProcessor
+	keyframes   chan struct{}
+
+	mu     sync.Mutex
+	in     chan []byte
+	closed bool
+}
+
+// Push передаёт принятый кадр в Rx pipeline сессии, не блокируясь при переполнении
+func (s *VehicleSession) Push(data []byte) {
+	s.lastSeen.Store(time.Now().UnixNano())
+	s.frames.Add(1)
+	s.bytes.Add(uint64(len(data)))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.in <- data:
+	default:
+		s.dropped.Add(1)
+		log.Printf("Session %s: очередь Rx заполнена, кадр отброшен", s.ID)
+	}
+}
+
+// KeyframeRequests возвращает запросы опорного кадра от Rx; соединение ТС передаёт их клиенту.
+// Неотправленные запросы не накапливаются: в канале не больше одного.
+func (s *VehicleSession) KeyframeRequests() <-chan struct{} {
+	return s.keyframes
+}
+
+func (s *VehicleSession) requestKeyframe() {
+	select {
+	case s.keyframes <- struct{}{}:
+	default:
+	}
+}
+
+// Grid возвращает последнюю принятую сетку высот ТС или nil
+func (s *VehicleSession) Grid() *OccupancyGrid {
+	return s.grid.Load()
+}
+
+// VoxelMap возвращает карту окружения ТС или nil, если она не ведётся
+func (s *VehicleSession) VoxelMap() *VoxelMap {
+	return s.voxelMap
+}
+
+// LastSeen возвращает время последней активности ТС
+func (s *VehicleSession) LastSeen() time.Time {
+	return time.Unix(0, s.lastSeen.Load())
+}
+
+func (s *VehicleSession) Stats() VehicleStats {
+	connections := s.connections.Load()
+	return VehicleStats{
+		ID:          s.ID,
+		Active:      connections > 0,
+		Connections: connections,
+		ConnectedAt: s.connectedAt,
+		LastSeen:    s.LastSeen(),
+		Frames:      s.frames.Load(),
+		Bytes:       s.bytes.Load(),
+		Dropped:     s.dropped.Load(),
+		Operators:   len(s.Hub.Stats()),
+		Codecs:      s.processor.CodecStats(),
+	}
+}
+
+// close останавливает Rx pipeline; Hub закроется сам, когда Rx завершится
+func (s *VehicleSession) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.in)
+}
+
+// SessionRegistry хранит сессии ТС по их идентификаторам
+type SessionRegistry struct {
+	newProcessor func() (*PointCloudProcessor, error)
+	queueSize    int
+
+	mu       sync.RWMutex
+	sessions map[string]*VehicleSession
+}
+
+// NewSessionRegistry создаёт реестр; newProcessor вызывается для каждой новой сессии,
+// queueSize задаёт длину очереди кадров каждого оператора
+func NewSessionRegistry(newProcessor func() (*PointCloudProcessor, error), queueSize int) *SessionRegistry {
+	return &SessionRegistry{
+		newProcessor: newProcessor,
+		queueSize:    queueSize,
+		sessions:     make(map[string]*VehicleSession),
+	}
+}
+
+// Attach возвращает сессию ТС, создавая её при первом подключении, и учитывает новое соединение.
+// Ошибка возвращается, если не удалось создать Rx pipeline новой сессии.
+func (r *SessionRegistry) Attach(vehicleID string) (*VehicleSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[vehicleID]
+	if !ok {
+		processor, err := r.newProcessor()
+		if err != nil {
+			return nil, fmt.Errorf("невозможно создать Rx pipeline ТС %s: %w", vehicleID, err)
+		}
+		s = &VehicleSession{
+			ID:          vehicleID,
+			Hub:         NewHub[Frame](r.queueSize),
+			connectedAt: time.Now(),
+			in:          make(chan []byte, 64),
+			keyframes:   make(chan struct{}, 1),
+		}
+		decoded := make(chan Frame, 64)
+		frames := make(chan Frame, 64)
+		s.voxelMap = processor.VoxelMap()
+		s.processor = processor
+		processor.SetKeyframeRequester(s.requestKeyframe)
+		go func() {
+			processor.Rx(s.in, decoded)
+			close(decoded)
+		}()
+		// Последняя сетка высот запоминается для запросов снимка
+		go func() {
+			for frame := range decoded {
+				if frame.Grid != nil {
+					s.grid.Store(frame.Grid)
+				}
+				frames <- frame
+			}
+			close(frames)
+		}()
+		go s.Hub.Run(frames)
+		r.sessions[vehicleID] = s
+		log.Printf("Session %s: создана новая сессия ТС", vehicleID)
+	}
+	s.connections.Add(1)
+	s.lastSeen.Store(time.Now().UnixNano())
+	return s, nil
+}
+
+// Detach учитывает закрытие соединения ТС; сама сессия сохраняется до Prune
+func (r *SessionRegistry) Detach(s *VehicleSession) {
+	s.connections.Add(-1)
+}
+
+// Get возвращает сессию ТС по идентификатору
+func (r *SessionRegistry) Get(vehicleID string) (*VehicleSession, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.sessions[vehicleID]
+	return s, ok
+}
+
+// Sessions возвращает все сессии, упорядоченные по идентификатору ТС
+func (r *SessionRegistry) Sessions() []*VehicleSession {
+	r.mu.RLock()
+	sessions := make([]*VehicleSession, 0, len(r.sessions))
+	for _, s := range r.sessions {
+		sessions = append(sessions, s)
+	}
+	r.mu.RUnlock()
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	return sessions
+}
+
+// List возвращает статистику всех сессий, упорядоченную по идентификатору ТС
+func (r *SessionRegistry) List() []VehicleStats {
+	sessions := r.Sessions()
+	stats := make([]VehicleStats, 0, len(sessions))
+	for _, s := range sessions {
+		stats = append(stats, s.Stats())
+	}
+	return stats
+}
+
+// Prune закрывает сессии без соединений, неактивные дольше maxIdle
+func (r *SessionRegistry) Prune(maxIdle time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, s := range r.sessions {
+		if s.connections.Load() > 0 || time.Since(s.LastSeen()) < maxIdle {
+			continue
+		}
+		delete(r.sessions, id)
+		s.close()
+		log.Printf("Session %s: сессия закрыта после %s неактивности", id, maxIdle)
+	}
+}
@@ -0,0 +1,159 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionRegistryAttach(t *testing.T) {
+	r := NewSessionRegistry(func() (*PointCloudProcessor, error) { return NewPointCloudProcessor(), nil }, 8)
+	a, err := r.Attach("b-vehicle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := r.Attach("b-vehicle")
+	if err != nil || again != a {
+		t.Fatalf("повторное подключение создало новую сессию: %v", err)
+	}
+	if _, err := r.Attach("a-vehicle"); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := r.Get("b-vehicle"); !ok || s != a {
+		t.Fatal("Get не вернул сессию")
+	}
+	list := r.List()
+	if len(list) != 2 || list[0].ID != "a-vehicle" || list[1].ID != "b-vehicle" {
+		t.Fatalf("список сессий %+v", list)
+	}
+	if list[1].Connections != 2 || !list[1].Active {
+		t.Fatalf("соединений %d", list[1].Connections)
+	}
+	r.Detach(a)
+	r.Detach(a)
+	if stats := a.Stats(); stats.Active || stats.Connections != 0 {
+		t.Fatalf("после отключения %+v", stats)
+	}
+}
+
+func TestSessionRegistryProcessorError(t *testing.T) {
+	failure := errors.New("нет компрессора")
+	r := NewSessionRegistry(func() (*PointCloudProcessor, error) { return nil, failure }, 8)
+	if _, err := r.Attach("v1"); !errors.Is(err, failure) {
+		t.Fatalf("ошибка %v", err)
+	}
+	if _, ok := r.Get("v1"); ok {
+		t.Fatal("сессия с неудавшимся pipeline сохранена")
+	}
+}
+
+func TestSessionPushReachesOperators(t *testing.T) {
+	r := NewSessionRegistry(func() (*PointCloudProcessor, error) { return NewPointCloudProcessor(), nil }, 8)
+	s, err := r.Attach("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := s.Hub.Subscribe("оператор")
+	builder, err := NewGridBuilder(GridConfig{CellSize: 1, Extent: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := EncodeFrame(FrameHeader{VehicleID: "v1", Seq: 7}, nil, FrameSections{Grid: builder.Build([]Point{{X: 1, Y: 1, Z: 1}})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Push(data)
+	select {
+	case frame := <-sub.C():
+		if frame.Header.Seq != 7 || frame.Grid == nil {
+			t.Fatalf("кадр %+v", frame.Header)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("кадр не дошёл до оператора")
+	}
+	if s.Grid() == nil {
+		t.Fatal("сетка высот не сохранена для снимка")
+	}
+	if stats := s.Stats(); stats.Frames != 1 || stats.Bytes != uint64(len(data)) || stats.Operators != 1 {
+		t.Fatalf("статистика %+v", stats)
+	}
+}
+
+func TestSessionRegistryPrune(t *testing.T) {
+	r := NewSessionRegistry(func() (*PointCloudProcessor, error) { return NewPointCloudProcessor(), nil }, 8)
+	idle, _ := r.Attach("idle")
+	if _, err := r.Attach("busy"); err != nil {
+		t.Fatal(err)
+	}
+	sub := idle.Hub.Subscribe("оператор")
+	r.Detach(idle)
+
+	r.Prune(time.Hour)
+	if len(r.Sessions()) != 2 {
+		t.Fatal("сессия закрыта раньше maxIdle")
+	}
+	r.Prune(0)
+	if _, ok := r.Get("idle"); ok {
+		t.Fatal("неактивная сессия без соединений не закрыта")
+	}
+	if _, ok := r.Get("busy"); !ok {
+		t.Fatal("закрыта сессия с активным соединением")
+	}
+	// Rx завершается, и Hub закрывает каналы операторов
+	select {
+	case _, ok := <-sub.C():
+		if ok {
+			t.Fatal("получен кадр закрытой сессии")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("канал оператора не закрыт после закрытия сессии")
+	}
+	idle.Push([]byte("после закрытия")) // не должен паниковать
+}
+
+// fixedCodec разворачивает любые данные в заданное облако точек
+type fixedCodec struct {
+	out []byte
+}
+
+func (c fixedCodec) Codec() CodecID                       { return CodecGzip }
+func (c fixedCodec) Compress(data []byte) ([]byte, error) { return data, nil }
+func (c fixedCodec) Decompress([]byte) ([]byte, error)    { return c.out, nil }
+
+func TestSessionStatsReportCodecs(t *testing.T) {
+	cloud := SerializePoints([]Point{{X: 1}, {X: 2}, {X: 3}, {X: 4}}, 0)
+	r := NewSessionRegistry(func() (*PointCloudProcessor, error) {
+		p := NewPointCloudProcessor()
+		p.SetCompressors(fixedCodec{out: cloud})
+		return p, nil
+	}, 8)
+	s, err := r.Attach("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := s.Hub.Subscribe("оператор")
+	for seq := uint32(1); seq <= 2; seq++ {
+		data, err := EncodeFrame(FrameHeader{VehicleID: "v1", Seq: seq, Codecs: []CodecID{CodecGzip}}, make([]byte, 10), FrameSections{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Push(data)
+		select {
+		case <-sub.C():
+		case <-time.After(5 * time.Second):
+			t.Fatal("кадр не дошёл до оператора")
+		}
+	}
+
+	codecs := s.Stats().Codecs
+	if len(codecs) != 1 {
+		t.Fatalf("статистика кодеков %+v", codecs)
+	}
+	got := codecs[0]
+	if got.Codec != CodecGzip.String() || got.Frames != 2 || got.InputBytes != 20 || got.OutputBytes != uint64(2*len(cloud)) {
+		t.Errorf("статистика кодека %+v", got)
+	}
+	if want := float64(len(cloud)) / 10; got.Ratio != want || got.DecodeMs < 0 {
+		t.Errorf("степень сжатия %v, ожидалось %v; время %v мс", got.Ratio, want, got.DecodeMs)
+	}
+}
@@ -0,0 +1,256 @@
+package usecase
+
+import (
+	"fmt"
+	"github.com/chewxy/math32"
+	"sort"
+	"time"
+)
+
+// Track — препятствие, сопровождаемое между кадрами. Скорость оценивается в системе
+// координат ТС, то есть относительно ТС.
+type Track struct {
+	ID         uint32     `json:"id"`
+	Center     [3]float32 `json:"center"`
+	Size       [3]float32 `json:"size"`
+	Yaw        float32    `json:"yaw"`
+	Velocity   [2]float32 `json:"velocity"` // vx, vy, м/с
+	Speed      float32    `json:"speed"`    // м/с
+	Heading    float32    `json:"heading"`  // направление движения, радианы; у стоящих объектов — последнее известное
+	Age        float32    `json:"age"`      // время с первого обнаружения, секунды
+	Hits       uint32     `json:"hits"`     // число кадров, в которых трек сопоставлен с препятствием
+	Misses     uint32     `json:"misses"`   // число кадров подряд без сопоставления
+	Confidence float32    `json:"confidence"`
+}
+
+// TrackerConfig задаёт параметры сопровождения препятствий
+type TrackerConfig struct {
+	MaxDistance      float32 // наибольшее расстояние от прогноза трека до препятствия при сопоставлении, метры
+	MaxMisses        int     // после стольких кадров подряд без сопоставления трек удаляется
+	MinHits          int     // трек публикуется после стольких сопоставлений
+	ProcessNoise     float32 // СКО ускорения в модели постоянной скорости, м/с²
+	MeasurementNoise float32 // СКО положения центроида препятствия, метры
+}
+
+func DefaultTrackerConfig() TrackerConfig {
+	return TrackerConfig{
+		MaxDistance:      2,
+		MaxMisses:        5,
+		MinHits:          3,
+		ProcessNoise:     2,
+		MeasurementNoise: 0.3,
+	}
+}
+
+const (
+	// initialVelocityVar — дисперсия скорости нового трека, (м/с)²
+	initialVelocityVar = 100
+	// confidenceGain — вес последнего кадра в экспоненциальном сглаживании уверенности
+	confidenceGain = 0.3
+	// minHeadingSpeed — ниже этой скорости направление движения не обновляется, м/с
+	minHeadingSpeed = 0.5
+)
+
+// kalmanTrack — трек с состоянием фильтра Калмана [x, y, vx, vy] по центроиду препятствия
+type kalmanTrack struct {
+	Track
+	state   [4]float64
+	cov     [4][4]float64
+	born    time.Time
+	matched bool
+}
+
+// Tracker сопровождает препятствия между кадрами: фильтр Калмана с моделью постоянной
+// скорости на плоскости xy и жадное сопоставление ближайших пар прогноз — препятствие.
+// Не безопасен для одновременного использования: у каждого ТС должен быть свой.
+type Tracker struct {
+	cfg    TrackerConfig
+	tracks []*kalmanTrack
+	last   time.Time
+	nextID uint32
+}
+
+// Validate проверяет параметры сопровождения
+func (cfg TrackerConfig) Validate() error {
+	if cfg.MaxDistance <= 0 {
+		return fmt.Errorf("расстояние сопоставления должно быть положительным: %v", cfg.MaxDistance)
+	}
+	if cfg.MaxMisses < 0 || cfg.MinHits < 1 {
+		return fmt.Errorf("недопустимые пороги трека: maxMisses %d, minHits %d", cfg.MaxMisses, cfg.MinHits)
+	}
+	if cfg.ProcessNoise <= 0 || cfg.MeasurementNoise <= 0 {
+		return fmt.Errorf("шумы модели и измерений должны быть положительными")
+	}
+	return nil
+}
+
+func NewTracker(cfg TrackerConfig) (*Tracker, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Tracker{cfg: cfg, nextID: 1}, nil
+}
+
+// Update продвигает треки ко времени съёмки кадра, сопоставляет их с препятствиями кадра
+// и возвращает подтверждённые треки, упорядоченные по ID
+func (t *Tracker) Update(captureTime time.Time, obstacles []Obstacle) []Track {
+	dt := 0.0
+	if !t.last.IsZero() && captureTime.After(t.last) {
+		dt = captureTime.Sub(t.last).Seconds()
+	}
+	t.last = captureTime
+	for _, tr := range t.tracks {
+		t.predict(tr, dt)
+		tr.matched = false
+	}
+
+	// Жадное сопоставление: пары по возрастанию расстояния в пределах MaxDistance
+	type pair struct {
+		track, obstacle int
+		distance        float32
+	}
+	var pairs []pair
+	for i, tr := range t.tracks {
+		for j, o := range obstacles {
+			dx := float32(tr.state[0]) - o.Centroid[0]
+			dy := float32(tr.state[1]) - o.Centroid[1]
+			if d := math32.Hypot(dx, dy); d <= t.cfg.MaxDistance {
+				pairs = append(pairs, pair{i, j, d})
+			}
+		}
+	}
+	sort.Slice(pairs, func(a, b int) bool { return pairs[a].distance < pairs[b].distance })
+	used := make([]bool, len(obstacles))
+	for _, p := range pairs {
+		tr := t.tracks[p.track]
+		if tr.matched || used[p.obstacle] {
+			continue
+		}
+		tr.matched, used[p.obstacle] = true, true
+		t.correct(tr, obstacles[p.obstacle])
+	}
+
+	kept := t.tracks[:0]
+	for _, tr := range t.tracks {
+		if tr.matched {
+			tr.Misses = 0
+			tr.Confidence += confidenceGain * (1 - tr.Confidence)
+		} else {
+			tr.Misses++
+			tr.Confidence -= confidenceGain * tr.Confidence
+		}
+		if int(tr.Misses) <= t.cfg.MaxMisses {
+			kept = append(kept, tr)
+		}
+	}
+	clear(t.tracks[len(kept):])
+	t.tracks = kept
+	for j, o := range obstacles {
+		if !used[j] {
+			t.tracks = append(t.tracks, t.spawn(captureTime, o))
+		}
+	}
+
+	tracks := make([]Track, 0, len(t.tracks))
+	for _, tr := range t.tracks {
+		if int(tr.Hits) < t.cfg.MinHits {
+			continue
+		}
+		tr.Age = float32(captureTime.Sub(tr.born).Seconds())
+		tracks = append(tracks, tr.Track)
+	}
+	return tracks
+}
+
+// spawn начинает трек с нулевой скоростью и большой неопределённостью скорости
+func (t *Tracker) spawn(captureTime time.Time, o Obstacle) *kalmanTrack {
+	r := float64(t.cfg.MeasurementNoise) * float64(t.cfg.MeasurementNoise)
+	tr := &kalmanTrack{
+		Track: Track{ID: t.nextID, Confidence: confidenceGain},
+		state: [4]float64{float64(o.Centroid[0]), float64(o.Centroid[1]), 0, 0},
+		born:  captureTime,
+	}
+	tr.cov[0][0], tr.cov[1][1] = r, r
+	tr.cov[2][2], tr.cov[3][3] = initialVelocityVar, initialVelocityVar
+	t.nextID++
+	tr.observe(o)
+	return tr
+}
+
+// predict продвигает состояние на dt секунд: x' = F·x, P' = F·P·Fᵀ + Q
+func (t *Tracker) predict(tr *kalmanTrack, dt float64) {
+	if dt == 0 {
+		return
+	}
+	dx, dy := tr.state[2]*dt, tr.state[3]*dt
+	tr.state[0] += dx
+	tr.state[1] += dy
+	tr.Center[0] += float32(dx)
+	tr.Center[1] += float32(dy)
+
+	p := &tr.cov
+	for i := range 4 {
+		p[i][0] += dt * p[i][2]
+		p[i][1] += dt * p[i][3]
+	}
+	for j := range 4 {
+		p[0][j] += dt * p[2][j]
+		p[1][j] += dt * p[3][j]
+	}
+	// Шум модели для ускорения с дисперсией q: дискретный белый шум
+	q := float64(t.cfg.ProcessNoise) * float64(t.cfg.ProcessNoise)
+	dt2 := dt * dt
+	for axis := range 2 {
+		pos, vel := axis, axis+2
+		p[pos][pos] += q * dt2 * dt2 / 4
+		p[pos][vel] += q * dt2 * dt / 2
+		p[vel][pos] += q * dt2 * dt / 2
+		p[vel][vel] += q * dt2
+	}
+}
+
+// correct уточняет состояние по центроиду препятствия; измеряется только положение (H = [I 0])
+func (t *Tracker) correct(tr *kalmanTrack, o Obstacle) {
+	r := float64(t.cfg.MeasurementNoise) * float64(t.cfg.MeasurementNoise)
+	p := &tr.cov
+	// S = H·P·Hᵀ + R, K = P·Hᵀ·S⁻¹
+	s00, s01, s10, s11 := p[0][0]+r, p[0][1], p[1][0], p[1][1]+r
+	det := s00*s11 - s01*s10
+	i00, i01, i10, i11 := s11/det, -s01/det, -s10/det, s00/det
+	var k [4][2]float64
+	for i := range 4 {
+		k[i][0] = p[i][0]*i00 + p[i][1]*i10
+		k[i][1] = p[i][0]*i01 + p[i][1]*i11
+	}
+	y0 := float64(o.Centroid[0]) - tr.state[0]
+	y1 := float64(o.Centroid[1]) - tr.state[1]
+	for i := range 4 {
+		tr.state[i] += k[i][0]*y0 + k[i][1]*y1
+	}
+	// P = (I − K·H)·P
+	var rows [2][4]float64
+	rows[0], rows[1] = p[0], p[1]
+	for i := range 4 {
+		for j := range 4 {
+			p[i][j] -= k[i][0]*rows[0][j] + k[i][1]*rows[1][j]
+		}
+	}
+	tr.observe(o)
+}
+
+// observe переносит в трек размеры и положение рамки, оценку скорости и направления
+func (tr *kalmanTrack) observe(o Obstacle) {
+	tr.Hits++
+	tr.Size, tr.Yaw = o.Size, o.Yaw
+	// Центр рамки сдвигается вместе с отфильтрованным центроидом
+	tr.Center = [3]float32{
+		o.Center[0] + float32(tr.state[0]) - o.Centroid[0],
+		o.Center[1] + float32(tr.state[1]) - o.Centroid[1],
+		o.Center[2],
+	}
+	tr.Velocity = [2]float32{float32(tr.state[2]), float32(tr.state[3])}
+	tr.Speed = math32.Hypot(tr.Velocity[0], tr.Velocity[1])
+	if tr.Speed >= minHeadingSpeed {
+		tr.Heading = math32.Atan2(tr.Velocity[1], tr.Velocity[0])
+	}
+}
@@ -0,0 +1,121 @@
+package usecase
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func trackObstacle(x, y float32) Obstacle {
+	return Obstacle{
+		Center:   [3]float32{x, y, 0.8},
+		Size:     [3]float32{4.5, 1.8, 1.6},
+		Centroid: [3]float32{x, y, 0.7},
+	}
+}
+
+func TestTrackerEstimatesVelocity(t *testing.T) {
+	tracker, err := NewTracker(DefaultTrackerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1700000000, 0)
+	var tracks []Track
+	// Машина едет по диагонали со скоростью 5 м/с, кадры 10 Гц; стоящий объект рядом
+	for k := range 40 {
+		at := start.Add(time.Duration(k) * 100 * time.Millisecond)
+		s := float32(k) * 0.5 / float32(math.Sqrt2)
+		tracks = tracker.Update(at, []Obstacle{trackObstacle(10+s, s), trackObstacle(-8, 3)})
+		if k < DefaultTrackerConfig().MinHits-1 && len(tracks) != 0 {
+			t.Fatalf("кадр %d: трек опубликован до MinHits", k)
+		}
+	}
+	if len(tracks) != 2 {
+		t.Fatalf("треков %d", len(tracks))
+	}
+	moving, still := tracks[0], tracks[1]
+	if moving.ID != 1 || still.ID != 2 {
+		t.Fatalf("ID треков %d, %d", moving.ID, still.ID)
+	}
+	if abs32(moving.Speed-5) > 0.2 || abs32(moving.Heading-math.Pi/4) > 0.05 {
+		t.Errorf("движущийся трек: скорость %v, направление %v", moving.Speed, moving.Heading)
+	}
+	if still.Speed > 0.2 {
+		t.Errorf("стоящий трек: скорость %v", still.Speed)
+	}
+	if moving.Hits != 40 || moving.Misses != 0 || moving.Confidence < 0.99 || abs32(moving.Age-3.9) > 1e-3 {
+		t.Errorf("счётчики трека %+v", moving)
+	}
+	if moving.Size != [3]float32{4.5, 1.8, 1.6} || abs32(moving.Center[2]-0.8) > 1e-6 {
+		t.Errorf("рамка трека %+v", moving)
+	}
+}
+
+func TestTrackerCoastsAndDrops(t *testing.T) {
+	tracker, err := NewTracker(DefaultTrackerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(0, 0)
+	at := func(k int) time.Time { return start.Add(time.Duration(k) * 100 * time.Millisecond) }
+	k := 0
+	for ; k < 20; k++ {
+		tracker.Update(at(k), []Obstacle{trackObstacle(float32(k)*0.5, 0)})
+	}
+	// Препятствие пропадает на два кадра: трек продолжает движение по прогнозу
+	var tracks []Track
+	for ; k < 22; k++ {
+		tracks = tracker.Update(at(k), nil)
+	}
+	if len(tracks) != 1 || tracks[0].Misses != 2 || abs32(tracks[0].Center[0]-10.5) > 0.2 {
+		t.Fatalf("трек без сопоставления %+v", tracks)
+	}
+	// И снова сопоставляется по прогнозу, а не начинает новый трек
+	tracks = tracker.Update(at(k), []Obstacle{trackObstacle(float32(k)*0.5, 0)})
+	k++
+	if len(tracks) != 1 || tracks[0].ID != 1 || tracks[0].Misses != 0 {
+		t.Fatalf("трек после возвращения препятствия %+v", tracks)
+	}
+	// После MaxMisses кадров без сопоставления трек удаляется
+	for range DefaultTrackerConfig().MaxMisses + 1 {
+		tracks = tracker.Update(at(k), nil)
+		k++
+	}
+	if len(tracks) != 0 {
+		t.Fatalf("трек не удалён: %+v", tracks)
+	}
+	tracks = tracker.Update(at(k), []Obstacle{trackObstacle(0, 0)})
+	if len(tracks) != 0 || tracker.tracks[0].ID != 2 {
+		t.Fatalf("новое препятствие не начало трек 2: %+v", tracker.tracks[0].Track)
+	}
+}
+
+func TestTrackerGatesByDistance(t *testing.T) {
+	tracker, err := NewTracker(DefaultTrackerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(0, 0)
+	tracker.Update(start, []Obstacle{trackObstacle(0, 0)})
+	// Скачок дальше MaxDistance — другой объект
+	tracker.Update(start.Add(100*time.Millisecond), []Obstacle{trackObstacle(5, 0)})
+	if len(tracker.tracks) != 2 || tracker.tracks[0].Misses != 1 || tracker.tracks[1].ID != 2 {
+		t.Fatalf("треки после скачка: %d", len(tracker.tracks))
+	}
+}
+
+func TestTrackerConfigValidate(t *testing.T) {
+	for name, edit := range map[string]func(*TrackerConfig){
+		"расстояние": func(c *TrackerConfig) { c.MaxDistance = 0 },
+		"пропуски":   func(c *TrackerConfig) { c.MaxMisses = -1 },
+		"попадания":  func(c *TrackerConfig) { c.MinHits = 0 },
+		"шум модели": func(c *TrackerConfig) { c.ProcessNoise = 0 },
+		"шум центра": func(c *TrackerConfig) { c.MeasurementNoise = -1 },
+	} {
+		cfg := DefaultTrackerConfig()
+		edit(&cfg)
+		if _, err := NewTracker(cfg); err == nil {
+			t.Errorf("%s: недопустимая конфигурация принята", name)
+		}
+	}
+}
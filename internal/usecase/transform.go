@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"fmt"
+	"github.com/chewxy/math32"
+)
+
+const degreesToRad = math32.Pi / 180
+
+// Transform — жёсткое преобразование из системы координат сенсора в базовую систему ТС:
+// p' = R·p + T
+type Transform struct {
+	Rotation    [3][3]float32
+	Translation [3]float32
+}
+
+// IdentityTransform — сенсор установлен в начале координат ТС без поворота
+func IdentityTransform() Transform {
+	return Transform{Rotation: [3][3]float32{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}}
+}
+
+// NewTransformRPY строит преобразование по смещению (метры) и углам крена, тангажа и рыскания (градусы).
+// Повороты применяются как в ROS: R = Rz(yaw)·Ry(pitch)·Rx(roll).
+func NewTransformRPY(translation [3]float32, roll, pitch, yaw float32) Transform {
+	sr, cr := math32.Sincos(roll * degreesToRad)
+	sp, cp := math32.Sincos(pitch * degreesToRad)
+	sy, cy := math32.Sincos(yaw * degreesToRad)
+	return Transform{
+		Rotation: [3][3]float32{
+			{cy * cp, cy*sp*sr - sy*cr, cy*sp*cr + sy*sr},
+			{sy * cp, sy*sp*sr + cy*cr, sy*sp*cr - cy*sr},
+			{-sp, cp * sr, cp * cr},
+		},
+		Translation: translation,
+	}
+}
+
+// NewTransformQuaternion строит преобразование по смещению (метры) и кватерниону (x, y, z, w).
+// Кватернион нормируется; нулевой кватернион — ошибка.
+func NewTransformQuaternion(translation [3]float32, x, y, z, w float32) (Transform, error) {
+	norm := math32.Sqrt(x*x + y*y + z*z + w*w)
+	if norm == 0 {
+		return Transform{}, fmt.Errorf("нулевой кватернион поворота")
+	}
+	x, y, z, w = x/norm, y/norm, z/norm, w/norm
+	return Transform{
+		Rotation: [3][3]float32{
+			{1 - 2*(y*y+z*z), 2 * (x*y - z*w), 2 * (x*z + y*w)},
+			{2 * (x*y + z*w), 1 - 2*(x*x+z*z), 2 * (y*z - x*w)},
+			{2 * (x*z - y*w), 2 * (y*z + x*w), 1 - 2*(x*x+y*y)},
+		},
+		Translation: translation,
+	}, nil
+}
+
+// Apply переводит точку из системы координат сенсора в систему ТС
+func (t Transform) Apply(x, y, z float32) (float32, float32, float32) {
+	r := &t.Rotation
+	return r[0][0]*x + r[0][1]*y + r[0][2]*z + t.Translation[0],
+		r[1][0]*x + r[1][1]*y + r[1][2]*z + t.Translation[1],
+		r[2][0]*x + r[2][1]*y + r[2][2]*z + t.Translation[2]
+}
+
+// IsIdentity сообщает, что преобразование не меняет точки и его можно не применять
+func (t Transform) IsIdentity() bool {
+	return t == IdentityTransform()
+}
@@ -0,0 +1,110 @@
+package usecase
+
+import (
+	"math"
+	"testing"
+)
+
+func nearPoint(got, want [3]float32) bool {
+	for i := range got {
+		if abs32(got[i]-want[i]) > 1e-5 {
+			return false
+		}
+	}
+	return true
+}
+
+func applyTo(tr Transform, p [3]float32) [3]float32 {
+	x, y, z := tr.Apply(p[0], p[1], p[2])
+	return [3]float32{x, y, z}
+}
+
+func TestTransformIdentity(t *testing.T) {
+	quaternion, err := NewTransformQuaternion([3]float32{}, 0, 0, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tr := range map[string]Transform{
+		"IdentityTransform": IdentityTransform(),
+		"rpy":               NewTransformRPY([3]float32{}, 0, 0, 0),
+		"quaternion":        quaternion,
+	} {
+		if !tr.IsIdentity() {
+			t.Errorf("%s: преобразование %+v не тождественное", name, tr)
+		}
+		if got := applyTo(tr, [3]float32{1.5, -2, 3}); got != [3]float32{1.5, -2, 3} {
+			t.Errorf("%s: точка переведена в %v", name, got)
+		}
+	}
+	if NewTransformRPY([3]float32{0, 0, 0.1}, 0, 0, 0).IsIdentity() {
+		t.Error("смещение принято за тождественное преобразование")
+	}
+}
+
+func TestTransformRPY(t *testing.T) {
+	tests := []struct {
+		name             string
+		roll, pitch, yaw float32
+		point, want      [3]float32
+	}{
+		// Одиночные повороты против часовой стрелки, если смотреть с конца оси
+		{"крен", 90, 0, 0, [3]float32{0, 1, 0}, [3]float32{0, 0, 1}},
+		{"тангаж", 0, 90, 0, [3]float32{1, 0, 0}, [3]float32{0, 0, -1}},
+		{"рыскание", 0, 0, 90, [3]float32{1, 0, 0}, [3]float32{0, 1, 0}},
+		{"рыскание назад", 0, 0, 180, [3]float32{1, 2, 0}, [3]float32{-1, -2, 0}},
+		// Порядок ROS: сначала крен, затем тангаж, затем рыскание (R = Rz·Ry·Rx).
+		// В обратном порядке (Rx·Ry·Rz) первые две точки попали бы в (0, -1, 0) и (0, 1, 0).
+		{"крен и рыскание", 90, 0, 90, [3]float32{0, 0, 1}, [3]float32{1, 0, 0}},
+		{"тангаж и рыскание", 0, 90, 90, [3]float32{1, 0, 0}, [3]float32{0, 0, -1}},
+		{"крен и тангаж", 90, 90, 0, [3]float32{0, 1, 0}, [3]float32{1, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := NewTransformRPY([3]float32{}, tt.roll, tt.pitch, tt.yaw)
+			if got := applyTo(tr, tt.point); !nearPoint(got, tt.want) {
+				t.Errorf("точка %v переведена в %v, ожидалось %v", tt.point, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformTranslation(t *testing.T) {
+	// Смещение прибавляется после поворота: сенсор в (1, 2, 3), развёрнутый влево на 90°
+	tr := NewTransformRPY([3]float32{1, 2, 3}, 0, 0, 90)
+	if got := applyTo(tr, [3]float32{1, 0, 0}); !nearPoint(got, [3]float32{1, 3, 3}) {
+		t.Errorf("точка переведена в %v, ожидалось (1, 3, 3)", got)
+	}
+	if got := applyTo(tr, [3]float32{}); got != [3]float32{1, 2, 3} {
+		t.Errorf("начало координат сенсора переведено в %v", got)
+	}
+}
+
+func TestTransformQuaternionMatchesRPY(t *testing.T) {
+	roll, pitch, yaw := 10.0, -20.0, 135.0
+	rpy := NewTransformRPY([3]float32{1, 2, 3}, float32(roll), float32(pitch), float32(yaw))
+
+	// Кватернион q = qz(yaw)·qy(pitch)·qx(roll)
+	sr, cr := math.Sincos(roll * math.Pi / 360)
+	sp, cp := math.Sincos(pitch * math.Pi / 360)
+	sy, cy := math.Sincos(yaw * math.Pi / 360)
+	x := sr*cp*cy - cr*sp*sy
+	y := cr*sp*cy + sr*cp*sy
+	z := cr*cp*sy - sr*sp*cy
+	w := cr*cp*cy + sr*sp*sy
+
+	for _, scale := range []float64{1, 2.5, -0.1} {
+		q, err := NewTransformQuaternion([3]float32{1, 2, 3}, float32(x*scale), float32(y*scale), float32(z*scale), float32(w*scale))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range [][3]float32{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}, {3, -4, 5}} {
+			if got, want := applyTo(q, p), applyTo(rpy, p); !nearPoint(got, want) {
+				t.Errorf("масштаб кватерниона %v: точка %v переведена в %v, по углам %v", scale, p, got, want)
+			}
+		}
+	}
+
+	if _, err := NewTransformQuaternion([3]float32{}, 0, 0, 0, 0); err == nil {
+		t.Error("принят нулевой кватернион")
+	}
+}
@@ -0,0 +1,252 @@
+package usecase
+
+import (
+	"fmt"
+	"github.com/chewxy/math32"
+	"math"
+	"sort"
+	"sync"
+)
+
+// VoxelMapConfig задаёт накопление карты окружения ТС
+type VoxelMapConfig struct {
+	VoxelSize float32 // размер вокселя карты, метры
+	Radius    float32 // скользящее окно: воксели дальше от ТС удаляются, метры; 0 — без ограничения
+	MaxVoxels int     // наибольшее число вокселей; при превышении удаляются давно не обновлявшиеся
+}
+
+func DefaultVoxelMapConfig() VoxelMapConfig {
+	return VoxelMapConfig{
+		VoxelSize: 0.2,
+		Radius:    100,
+		MaxVoxels: 300000,
+	}
+}
+
+// mapVoxel хранит среднее точек, попавших в воксель карты. Среднее обновляется по шагам
+// в двойной точности; после maxVoxelWeight точек вес новой точки больше не убывает,
+// и среднее становится экспоненциальным: воксель следует за медленными изменениями сцены.
+type mapVoxel struct {
+	mean      [3]float64
+	intensity float64
+	count     uint32
+	updated   uint64 // номер кадра последнего обновления
+	reported  Point  // точка, последний раз переданная в MapUpdate
+}
+
+func (v *mapVoxel) add(x, y, z float64, intensity uint8) {
+	if v.count < maxVoxelWeight {
+		v.count++
+	}
+	w := 1 / float64(v.count)
+	v.mean[0] += (x - v.mean[0]) * w
+	v.mean[1] += (y - v.mean[1]) * w
+	v.mean[2] += (z - v.mean[2]) * w
+	v.intensity += (float64(intensity) - v.intensity) * w
+}
+
+func (v *mapVoxel) point() Point {
+	return Point{
+		X:         float32(v.mean[0]),
+		Y:         float32(v.mean[1]),
+		Z:         float32(v.mean[2]),
+		Intensity: uint8(math.Round(v.intensity)),
+	}
+}
+
+// changed сообщает, сместилась ли точка вокселя с последней передачи заметнее порогов
+func (v *mapVoxel) changed(p Point, voxelSize float32) bool {
+	r := v.reported
+	limit := voxelSize * mapMoveThreshold
+	return math32.Abs(p.X-r.X) > limit || math32.Abs(p.Y-r.Y) > limit || math32.Abs(p.Z-r.Z) > limit ||
+		absDiff(p.Intensity, r.Intensity) > mapIntensityThreshold
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// MapVoxel — воксель карты: индекс и усреднённая точка в системе первого кадра одометрии
+type MapVoxel struct {
+	Key   [3]int32
+	Point Point
+}
+
+// MapUpdate — изменения карты после добавления кадра
+type MapUpdate struct {
+	Added   []MapVoxel // новые воксели
+	Updated []MapVoxel // существующие воксели, точка которых заметно сместилась с последней передачи
+	Removed [][3]int32 // удалённые воксели
+}
+
+const (
+	// voxelEvictionSlack — при переполнении удаляется на эту долю MaxVoxels больше, чтобы
+	// вытеснение не запускалось на каждом кадре
+	voxelEvictionSlack = 0.1
+	// maxVoxelWeight — наибольшее число точек, по которым усредняется воксель
+	maxVoxelWeight = 1000
+	// mapMoveThreshold — смещение точки вокселя по оси в долях VoxelSize, после которого
+	// воксель передаётся в Updated
+	mapMoveThreshold = 0.1
+	// mapIntensityThreshold — изменение средней интенсивности, после которого воксель передаётся в Updated
+	mapIntensityThreshold = 4
+)
+
+// VoxelMap — карта окружения ТС из кадров, приведённых к общей системе координат одометрией.
+// Безопасна для одновременного использования: Insert вызывается из Rx, Voxels — из HTTP.
+type VoxelMap struct {
+	cfg VoxelMapConfig
+
+	mu     sync.RWMutex
+	voxels map[[3]int32]*mapVoxel
+	frames uint64
+}
+
+// Validate проверяет параметры карты
+func (cfg VoxelMapConfig) Validate() error {
+	if cfg.VoxelSize <= 0 {
+		return fmt.Errorf("размер вокселя карты должен быть положительным: %v", cfg.VoxelSize)
+	}
+	if cfg.Radius < 0 || cfg.MaxVoxels <= 0 {
+		return fmt.Errorf("недопустимые ограничения карты: радиус %v, вокселей %d", cfg.Radius, cfg.MaxVoxels)
+	}
+	return nil
+}
+
+func NewVoxelMap(cfg VoxelMapConfig) (*VoxelMap, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &VoxelMap{cfg: cfg, voxels: make(map[[3]int32]*mapVoxel)}, nil
+}
+
+// VoxelSize возвращает размер вокселя карты
+func (m *VoxelMap) VoxelSize() float32 {
+	return m.cfg.VoxelSize
+}
+
+// Insert переводит точки кадра в систему карты по положению ТС, добавляет их в карту
+// и удаляет воксели за пределами окна и сверх MaxVoxels
+func (m *VoxelMap) Insert(pose Pose, points []Point) MapUpdate {
+	toMap := poseRigid(pose)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.frames++
+	var update MapUpdate
+	var added [][3]int32
+	touched := make(map[[3]int32]struct{})
+	for _, pt := range points {
+		p := toMap.apply([3]float64{float64(pt.X), float64(pt.Y), float64(pt.Z)})
+		size := float64(m.cfg.VoxelSize)
+		key := [3]int32{
+			int32(math.Floor(p[0] / size)),
+			int32(math.Floor(p[1] / size)),
+			int32(math.Floor(p[2] / size)),
+		}
+		v, ok := m.voxels[key]
+		if !ok {
+			v = &mapVoxel{}
+			m.voxels[key] = v
+			added = append(added, key)
+		} else if v.updated != m.frames {
+			touched[key] = struct{}{}
+		}
+		v.add(p[0], p[1], p[2], pt.Intensity)
+		v.updated = m.frames
+	}
+
+	if m.cfg.Radius > 0 {
+		// Расстояние считается до центра вокселя, по горизонтали
+		radius2 := m.cfg.Radius * m.cfg.Radius
+		for key := range m.voxels {
+			dx := (float32(key[0])+0.5)*m.cfg.VoxelSize - pose.Position[0]
+			dy := (float32(key[1])+0.5)*m.cfg.VoxelSize - pose.Position[1]
+			if dx*dx+dy*dy > radius2 {
+				delete(m.voxels, key)
+				update.Removed = append(update.Removed, key)
+			}
+		}
+	}
+	if len(m.voxels) > m.cfg.MaxVoxels {
+		update.Removed = append(update.Removed, m.evict()...)
+	}
+
+	// Воксели, добавленные или изменённые и сразу удалённые, в обновление не попадают
+	update.Added = make([]MapVoxel, 0, len(added))
+	for _, key := range added {
+		if v, ok := m.voxels[key]; ok {
+			v.reported = v.point()
+			update.Added = append(update.Added, MapVoxel{Key: key, Point: v.reported})
+		}
+	}
+	update.Updated = []MapVoxel{}
+	for key := range touched {
+		v, ok := m.voxels[key]
+		if !ok {
+			continue
+		}
+		if p := v.point(); v.changed(p, m.cfg.VoxelSize) {
+			v.reported = p
+			update.Updated = append(update.Updated, MapVoxel{Key: key, Point: p})
+		}
+	}
+	sortMapVoxels(update.Updated)
+	return update
+}
+
+// evict удаляет давно не обновлявшиеся воксели, освобождая место с запасом
+func (m *VoxelMap) evict() [][3]int32 {
+	type entry struct {
+		key     [3]int32
+		updated uint64
+	}
+	entries := make([]entry, 0, len(m.voxels))
+	for key, v := range m.voxels {
+		entries = append(entries, entry{key, v.updated})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].updated < entries[j].updated })
+	target := int(float64(m.cfg.MaxVoxels) * (1 - voxelEvictionSlack))
+	removed := make([][3]int32, 0, len(entries)-target)
+	for _, e := range entries[:len(entries)-target] {
+		delete(m.voxels, e.key)
+		removed = append(removed, e.key)
+	}
+	return removed
+}
+
+// Len возвращает число вокселей карты
+func (m *VoxelMap) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.voxels)
+}
+
+// Voxels возвращает снимок карты: все воксели с усреднёнными точками
+func (m *VoxelMap) Voxels() []MapVoxel {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	voxels := make([]MapVoxel, 0, len(m.voxels))
+	for key, v := range m.voxels {
+		voxels = append(voxels, MapVoxel{Key: key, Point: v.point()})
+	}
+	return voxels
+}
+
+// sortMapVoxels упорядочивает воксели по индексу, чтобы обновления карты не зависели
+// от порядка обхода map
+func sortMapVoxels(voxels []MapVoxel) {
+	sort.Slice(voxels, func(i, j int) bool {
+		a, b := voxels[i].Key, voxels[j].Key
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		if a[1] != b[1] {
+			return a[1] < b[1]
+		}
+		return a[2] < b[2]
+	})
+}
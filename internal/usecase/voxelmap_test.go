@@ -0,0 +1,139 @@
+package usecase
+
+import (
+	"testing"
+)
+
+// mapPose — положение ТС без поворота
+func mapPose(x, y float32) Pose {
+	return Pose{Position: [3]float32{x, y, 0}, Orientation: [4]float32{0, 0, 0, 1}, Converged: true}
+}
+
+func TestVoxelMapInsertAverages(t *testing.T) {
+	m, err := NewVoxelMap(VoxelMapConfig{VoxelSize: 1, MaxVoxels: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := m.Insert(mapPose(10, 0), []Point{
+		{X: 0.2, Y: 0.2, Z: 0.2, Intensity: 10},
+		{X: 0.4, Y: 0.6, Z: 0.8, Intensity: 21},
+		{X: -0.5, Y: 0.5, Z: 0.5, Intensity: 100},
+	})
+	// Точки переведены в систему карты: x смещён на положение ТС
+	if len(update.Added) != 2 || len(update.Updated) != 0 || len(update.Removed) != 0 || m.Len() != 2 {
+		t.Fatalf("обновление %+v", update)
+	}
+	byKey := make(map[[3]int32]Point)
+	for _, v := range update.Added {
+		byKey[v.Key] = v.Point
+	}
+	p, ok := byKey[[3]int32{10, 0, 0}]
+	if !ok || abs32(p.X-10.3) > 1e-5 || abs32(p.Y-0.4) > 1e-6 || abs32(p.Z-0.5) > 1e-6 || p.Intensity != 16 {
+		t.Errorf("воксель (10, 0, 0): %+v", p)
+	}
+	if p, ok := byKey[[3]int32{9, 0, 0}]; !ok || p.Intensity != 100 {
+		t.Errorf("воксель (9, 0, 0): %+v", p)
+	}
+	if voxels := m.Voxels(); len(voxels) != 2 {
+		t.Errorf("снимок карты %+v", voxels)
+	}
+}
+
+func TestVoxelMapReportsChangedVoxels(t *testing.T) {
+	m, err := NewVoxelMap(VoxelMapConfig{VoxelSize: 1, MaxVoxels: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Insert(mapPose(0, 0), []Point{{X: 0.1, Y: 0.5, Z: 0.5}, {X: 5.5, Y: 0.5, Z: 0.5}})
+
+	// Небольшое смещение среднего не передаётся
+	update := m.Insert(mapPose(0, 0), []Point{{X: 0.15, Y: 0.5, Z: 0.5}, {X: 5.5, Y: 0.5, Z: 0.5}})
+	if len(update.Added) != 0 || len(update.Updated) != 0 {
+		t.Fatalf("обновление без заметных изменений %+v", update)
+	}
+	// Среднее первого вокселя смещается почти на 0,4 — больше 0,1 размера вокселя
+	update = m.Insert(mapPose(0, 0), []Point{{X: 0.9, Y: 0.5, Z: 0.5}, {X: 0.9, Y: 0.5, Z: 0.5}})
+	if len(update.Added) != 0 || len(update.Updated) != 1 || update.Updated[0].Key != [3]int32{0, 0, 0} {
+		t.Fatalf("обновление %+v", update)
+	}
+	if x := update.Updated[0].Point.X; abs32(x-0.5125) > 1e-5 {
+		t.Errorf("среднее вокселя %v, ожидалось 0.5125", x)
+	}
+}
+
+func TestVoxelMapBoundedAverage(t *testing.T) {
+	m, err := NewVoxelMap(VoxelMapConfig{VoxelSize: 0.2, MaxVoxels: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Стоящее ТС: один и тот же воксель вдали от начала координат обновляется много раз
+	frame := []Point{{X: 0.05, Y: 0.15, Z: 0.1, Intensity: 255}}
+	for range 200000 {
+		m.Insert(mapPose(5000, -3000), frame)
+	}
+	v := m.Voxels()[0].Point
+	if abs32(v.X-5000.05) > 1e-3 || abs32(v.Y+2999.85) > 1e-3 || abs32(v.Z-0.1) > 1e-6 || v.Intensity != 255 {
+		t.Fatalf("среднее после 200000 точек %+v", v)
+	}
+	// После насыщения веса воксель следует за сместившимися точками
+	frame[0].X, frame[0].Intensity = 0.15, 0
+	for range 3000 {
+		m.Insert(mapPose(5000, -3000), frame)
+	}
+	v = m.Voxels()[0].Point
+	if v.X < 5000.14 || v.Intensity > 20 {
+		t.Fatalf("воксель не следует за сценой: %+v", v)
+	}
+}
+
+func TestVoxelMapRadius(t *testing.T) {
+	m, err := NewVoxelMap(VoxelMapConfig{VoxelSize: 1, Radius: 10, MaxVoxels: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Insert(mapPose(0, 0), []Point{{X: 0.5, Y: 0.5}, {X: 8.5, Y: 0.5}})
+	// ТС проехало 15 м: воксель у начала координат вне окна, второй — в 6 м
+	update := m.Insert(mapPose(15, 0), []Point{{X: 1.5, Y: 0.5}, {X: -30, Y: 0}})
+	if len(update.Removed) != 2 || m.Len() != 2 {
+		t.Fatalf("удалено %v, осталось %d", update.Removed, m.Len())
+	}
+	// Точка за пределами окна добавлена и сразу удалена: в Added её нет
+	if len(update.Added) != 1 || update.Added[0].Key != [3]int32{16, 0, 0} {
+		t.Fatalf("добавлено %+v", update.Added)
+	}
+}
+
+func TestVoxelMapEvictsStale(t *testing.T) {
+	m, err := NewVoxelMap(VoxelMapConfig{VoxelSize: 1, MaxVoxels: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		m.Insert(mapPose(0, 0), []Point{{X: float32(i) + 0.5}})
+	}
+	// Переполнение удаляет давно не обновлявшиеся воксели с запасом 10%
+	update := m.Insert(mapPose(0, 0), []Point{{X: 20.5}, {X: 9.5}})
+	if m.Len() != 9 || len(update.Removed) != 2 {
+		t.Fatalf("вокселей %d, удалено %v", m.Len(), update.Removed)
+	}
+	for _, key := range update.Removed {
+		if key[0] > 1 {
+			t.Errorf("удалён недавно обновлённый воксель %v", key)
+		}
+	}
+	if len(update.Added) != 1 || update.Added[0].Key[0] != 20 {
+		t.Errorf("добавлено %+v", update.Added)
+	}
+}
+
+func TestVoxelMapConfigValidate(t *testing.T) {
+	for _, cfg := range []VoxelMapConfig{
+		{VoxelSize: 0, MaxVoxels: 10},
+		{VoxelSize: 0.2, Radius: -1, MaxVoxels: 10},
+		{VoxelSize: 0.2, MaxVoxels: 0},
+	} {
+		if _, err := NewVoxelMap(cfg); err == nil {
+			t.Errorf("конфигурация %+v принята", cfg)
+		}
+	}
+}
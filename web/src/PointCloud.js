@@ -1,6 +1,31 @@
 import * as THREE from 'three';
 import { OrbitControls } from 'three/examples/jsm/controls/OrbitControls';
 
+// Типы отражений из заводского байта Velodyne
+const RETURN_STRONGEST = 0x37;
+const RETURN_LAST = 0x38;
+
+// Классы точек из поля label
+const LABEL_GROUND = 1;
+const GROUND_COLOR = [0.45, 0.4, 0.35];
+
+const OBSTACLE_COLOR = 0xff3030;
+
+// Диапазон высот для раскраски сетки, метры
+const GRID_MIN_HEIGHT = -2;
+const GRID_MAX_HEIGHT = 3;
+
+// Сколько последних положений ТС хранится для линии траектории
+const TRAJECTORY_LENGTH = 3000;
+const TRAJECTORY_COLOR = 0x30c0ff;
+
+// Карта окружения перерисовывается не чаще, чем раз в столько миллисекунд
+const MAP_REDRAW_MS = 500;
+const MAP_COLOR = 0x808080;
+
+// Стрелка трека показывает смещение за это время, секунды
+const TRACK_ARROW_SECONDS = 1;
+
 export default class PointCloud {
     constructor(containerId, sseUrl) {
         this.container = document.getElementById(containerId);
@@ -23,48 +48,333 @@ export default class PointCloud {
         this.controls.minDistance = 1;
         this.controls.maxDistance = 500;
         this.points = null;
+        this.showGround = true;
+        // Рамки препятствий текущего кадра
+        this.obstacles = new THREE.Group();
+        this.scene.add(this.obstacles);
+        // Стрелки скорости треков; цвет постоянен для каждого ID
+        this.tracks = new THREE.Group();
+        this.scene.add(this.tracks);
+        // Сетка высот: плоскость с текстурой, по пикселю на ячейку
+        this.grid = null;
+        this.showGrid = true;
+        // Траектория ТС по одометрии: положения в системе первого кадра
+        this.trajectory = [];
+        this.trajectoryLine = new THREE.Line(
+            new THREE.BufferGeometry(),
+            new THREE.LineBasicMaterial({ color: TRAJECTORY_COLOR })
+        );
+        this.scene.add(this.trajectoryLine);
+        // Карта окружения: воксели по ключу "i,j,k" в системе первого кадра одометрии;
+        // матрица объекта переводит их в систему текущего кадра
+        this.mapVoxels = new Map();
+        this.mapDirty = false;
+        this.mapDrawnAt = 0;
+        this.mapPoints = new THREE.Points(
+            new THREE.BufferGeometry(),
+            new THREE.PointsMaterial({ color: MAP_COLOR, size: 0.1, transparent: true, opacity: 0.5 })
+        );
+        this.mapPoints.matrixAutoUpdate = false;
+        this.scene.add(this.mapPoints);
         this.animate();
     }
 
+    // Переключает облако на другой SSE поток (например, другое ТС)
+    setSource(sseUrl) {
+        if (this.sseUrl === sseUrl) return;
+        this.sseUrl = sseUrl;
+        this.trajectory = [];
+        this.mapVoxels.clear();
+        this.mapDirty = true;
+        if (this.eventSource) {
+            this.eventSource.close();
+        }
+        clearTimeout(this.reconnectTimer);
+        this.initSSE();
+    }
+
     initSSE() {
+        if (!this.sseUrl) return;
+        this.loadMap();
         const connect = () => {
             this.eventSource = new EventSource(this.sseUrl);
             this.eventSource.onmessage = (event) => {
                 const data = JSON.parse(event.data);
                 this.updatePointCloud(data);
             };
+            this.eventSource.addEventListener('obstacles', (event) => {
+                this.updateObstacles(JSON.parse(event.data));
+            });
+            this.eventSource.addEventListener('tracks', (event) => {
+                this.updateTracks(JSON.parse(event.data));
+            });
+            this.eventSource.addEventListener('grid', (event) => {
+                this.updateGrid(JSON.parse(event.data));
+            });
+            this.eventSource.addEventListener('pose', (event) => {
+                this.updateTrajectory(JSON.parse(event.data));
+            });
+            this.eventSource.addEventListener('map', (event) => {
+                this.updateMap(JSON.parse(event.data));
+            });
             this.eventSource.onerror = () => {
                 this.eventSource.close();
-                setTimeout(connect, 1000); // попытка переподключения через 1 сек
+                this.reconnectTimer = setTimeout(connect, 1000); // попытка переподключения через 1 сек
             };
         };
         connect();
     }
 
-    updatePointCloud(pointsArray) {
+    // Режим раскраски: distance, intensity, ring, return или sensor
+    setColorMode(mode) {
+        this.colorMode = mode;
+        if (this.lastCloud) {
+            this.updatePointCloud(this.lastCloud);
+        }
+    }
+
+    // Показывает или скрывает слой точек земли
+    setGroundVisible(visible) {
+        this.showGround = visible;
+        if (this.lastCloud) {
+            this.updatePointCloud(this.lastCloud);
+        }
+    }
+
+    // Показывает или скрывает рамки препятствий
+    setObstaclesVisible(visible) {
+        this.obstacles.visible = visible;
+    }
+
+    // Заменяет рамки препятствий; каждая рамка повёрнута вокруг оси z на yaw
+    updateObstacles(event) {
+        for (const box of this.obstacles.children) {
+            box.geometry.dispose();
+        }
+        this.obstacles.clear();
+        if (!this.obstacleMaterial) {
+            this.obstacleMaterial = new THREE.LineBasicMaterial({ color: OBSTACLE_COLOR });
+        }
+        for (const obstacle of event.obstacles) {
+            const [sx, sy, sz] = obstacle.size;
+            const geometry = new THREE.EdgesGeometry(new THREE.BoxGeometry(sx, sy, sz));
+            const box = new THREE.LineSegments(geometry, this.obstacleMaterial);
+            box.position.set(...obstacle.center);
+            box.rotation.z = obstacle.yaw;
+            this.obstacles.add(box);
+        }
+    }
+
+    // Показывает или скрывает сетку высот
+    setGridVisible(visible) {
+        this.showGrid = visible;
+        if (this.grid) {
+            this.grid.visible = visible;
+        }
+    }
+
+    // Заменяет сетку высот: занятые ячейки раскрашиваются по наибольшей высоте, пустые прозрачны
+    updateGrid(grid) {
+        if (this.grid) {
+            this.scene.remove(this.grid);
+            this.grid.geometry.dispose();
+            this.grid.material.map.dispose();
+            this.grid.material.dispose();
+        }
+        const data = new Uint8Array(grid.width * grid.height * 4);
+        for (const [col, row, , , maxZ] of grid.cells) {
+            const t = Math.min(1, Math.max(0, (maxZ - GRID_MIN_HEIGHT) / (GRID_MAX_HEIGHT - GRID_MIN_HEIGHT)));
+            const i = (row * grid.width + col) * 4;
+            data[i] = 255 * t;
+            data[i + 1] = 255 * (1 - 2 * Math.abs(t - 0.5));
+            data[i + 2] = 255 * (1 - t);
+            data[i + 3] = 200;
+        }
+        const texture = new THREE.DataTexture(data, grid.width, grid.height, THREE.RGBAFormat);
+        texture.magFilter = THREE.NearestFilter;
+        texture.needsUpdate = true;
+        const sizeX = grid.width * grid.cellSize;
+        const sizeY = grid.height * grid.cellSize;
+        const material = new THREE.MeshBasicMaterial({ map: texture, transparent: true, side: THREE.DoubleSide, depthWrite: false });
+        this.grid = new THREE.Mesh(new THREE.PlaneGeometry(sizeX, sizeY), material);
+        this.grid.position.set(grid.originX + sizeX / 2, grid.originY + sizeY / 2, 0);
+        this.grid.visible = this.showGrid;
+        this.scene.add(this.grid);
+    }
+
+    // Загружает карту окружения целиком; дальше она обновляется событиями map
+    async loadMap() {
+        const url = this.sseUrl.replace('/sse?', '/map?');
+        try {
+            const response = await fetch(url);
+            if (!response.ok) return; // карта не ведётся
+            const snapshot = await response.json();
+            for (const voxel of snapshot.voxels) {
+                this.mapVoxels.set(`${voxel[0]},${voxel[1]},${voxel[2]}`, voxel);
+            }
+            this.mapDirty = true;
+        } catch (e) {
+            console.error('Не удалось загрузить карту окружения', e);
+        }
+    }
+
+    // Применяет изменения карты окружения
+    updateMap(event) {
+        for (const voxel of [...event.added, ...(event.updated ?? [])]) {
+            this.mapVoxels.set(`${voxel[0]},${voxel[1]},${voxel[2]}`, voxel);
+        }
+        for (const [i, j, k] of event.removed) {
+            this.mapVoxels.delete(`${i},${j},${k}`);
+        }
+        this.mapDirty = true;
+    }
+
+    // Перестраивает облако карты, если она изменилась
+    redrawMap() {
+        const now = performance.now();
+        if (!this.mapDirty || now - this.mapDrawnAt < MAP_REDRAW_MS) return;
+        this.mapDirty = false;
+        this.mapDrawnAt = now;
+        const vertices = new Float32Array(this.mapVoxels.size * 3);
+        let i = 0;
+        for (const voxel of this.mapVoxels.values()) {
+            vertices[i++] = voxel[3];
+            vertices[i++] = voxel[4];
+            vertices[i++] = voxel[5];
+        }
+        this.mapPoints.geometry.dispose();
+        this.mapPoints.geometry = new THREE.BufferGeometry();
+        this.mapPoints.geometry.setAttribute('position', new THREE.BufferAttribute(vertices, 3));
+    }
+
+    // Показывает или скрывает карту окружения
+    setMapVisible(visible) {
+        this.mapPoints.visible = visible;
+    }
+
+    // Показывает или скрывает траекторию ТС
+    setTrajectoryVisible(visible) {
+        this.trajectoryLine.visible = visible;
+    }
+
+    // Добавляет положение ТС и перерисовывает траекторию в системе текущего кадра,
+    // в которой приходят точки облака
+    updateTrajectory(event) {
+        const { position, orientation } = event.pose;
+        this.trajectory.push(new THREE.Vector3(...position));
+        if (this.trajectory.length > TRAJECTORY_LENGTH) {
+            this.trajectory.shift();
+        }
+        const pose = new THREE.Matrix4().compose(
+            new THREE.Vector3(...position),
+            new THREE.Quaternion(...orientation),
+            new THREE.Vector3(1, 1, 1)
+        );
+        const toVehicle = pose.invert();
+        this.mapPoints.matrix.copy(toVehicle);
+        const vertices = new Float32Array(this.trajectory.length * 3);
+        const point = new THREE.Vector3();
+        this.trajectory.forEach((p, i) => {
+            point.copy(p).applyMatrix4(toVehicle);
+            vertices.set([point.x, point.y, point.z], i * 3);
+        });
+        this.trajectoryLine.geometry.dispose();
+        this.trajectoryLine.geometry = new THREE.BufferGeometry();
+        this.trajectoryLine.geometry.setAttribute('position', new THREE.BufferAttribute(vertices, 3));
+    }
+
+    // Показывает или скрывает треки препятствий
+    setTracksVisible(visible) {
+        this.tracks.visible = visible;
+    }
+
+    // Заменяет стрелки треков: стрелка идёт из центра трека по направлению движения
+    updateTracks(event) {
+        for (const arrow of this.tracks.children) {
+            arrow.dispose();
+        }
+        this.tracks.clear();
+        for (const track of event.tracks) {
+            const color = new THREE.Color().setHSL((track.id * 0.618) % 1, 0.9, 0.55);
+            const direction = new THREE.Vector3(Math.cos(track.heading), Math.sin(track.heading), 0);
+            const length = Math.max(track.speed * TRACK_ARROW_SECONDS, 0.5);
+            const origin = new THREE.Vector3(...track.center);
+            this.tracks.add(new THREE.ArrowHelper(direction, origin, length, color, 0.4, 0.3));
+        }
+    }
+
+    updatePointCloud(cloud) {
+        this.lastCloud = cloud;
         if (this.points) {
             this.scene.remove(this.points);
+            this.points.geometry.dispose();
+            this.points.material.dispose();
         }
-        const geometry = new THREE.BufferGeometry();
-        const vertices = new Float32Array(pointsArray.flat());
-        geometry.setAttribute('position', new THREE.BufferAttribute(vertices, 3));
+        const pointsArray = cloud.points;
+        const fields = cloud.fields || [];
+        const intensityCol = fields.indexOf('intensity');
+        const ringCol = fields.indexOf('ring');
+        const returnCol = fields.indexOf('return');
+        const sensorCol = fields.indexOf('sensor');
+        const labelCol = fields.indexOf('label');
+        const mode = this.colorMode || 'distance';
 
-        // Градиент цвета по расстоянию
-        const colors = [];
+        const vertices = new Float32Array(pointsArray.length * 3);
+        const colors = new Float32Array(pointsArray.length * 3);
+        let maxRing = 1;
+        if (ringCol >= 0) {
+            for (const point of pointsArray) {
+                maxRing = Math.max(maxRing, point[3 + ringCol]);
+            }
+        }
+        // Каждому сенсору свой цвет, равномерно по градиенту
+        const sensorIndex = new Map();
+        if (sensorCol >= 0) {
+            const ids = [...new Set(pointsArray.map((point) => point[3 + sensorCol]))].sort((a, b) => a - b);
+            ids.forEach((id, i) => sensorIndex.set(id, i / Math.max(ids.length - 1, 1)));
+        }
         let avgAlpha = 0;
-        for (let i = 0; i < pointsArray.length; i++) {
-            const [x, y, z] = pointsArray[i];
-            const dist = Math.sqrt(x * x + y * y + z * z);
-            // Цвет: ближе к центру - синий, дальше - красный
-            const t = Math.min(dist / 500, 1);
-            const r = t;
-            const g = 0.2 * (1 - t);
-            const b = 1 - t;
-            colors.push(r, g, b);
+        let count = 0;
+        for (const point of pointsArray) {
+            const [x, y, z] = point;
+            // Земля рисуется отдельным слоем одного цвета
+            const ground = labelCol >= 0 && point[3 + labelCol] === LABEL_GROUND;
+            if (ground && !this.showGround) {
+                continue;
+            }
+            const i = count++;
+            vertices.set([x, y, z], i * 3);
+            if (ground) {
+                colors.set(GROUND_COLOR, i * 3);
+                avgAlpha += 0.3;
+                continue;
+            }
+
+            let t;
+            if (mode === 'intensity' && intensityCol >= 0) {
+                // Интенсивность: тёмные поверхности синие, световозвращающие красные
+                t = Math.min(point[3 + intensityCol] / 100, 1);
+            } else if (mode === 'ring' && ringCol >= 0) {
+                t = point[3 + ringCol] / maxRing;
+            } else if (mode === 'return' && returnCol >= 0) {
+                // Сильнейшее отражение - синее, последнее - красное, совпавшие - посередине
+                const ret = point[3 + returnCol];
+                t = ret === RETURN_LAST ? 1 : ret === RETURN_STRONGEST ? 0 : 0.5;
+            } else if (mode === 'sensor' && sensorCol >= 0) {
+                t = sensorIndex.get(point[3 + sensorCol]);
+            } else {
+                // Градиент цвета по расстоянию: ближе к центру - синий, дальше - красный
+                const dist = Math.sqrt(x * x + y * y + z * z);
+                t = Math.min(dist / 500, 1);
+            }
+            colors.set([t, 0.2 * (1 - t), 1 - t], i * 3);
             avgAlpha += 0.3 + 0.7 * (1 - t);
         }
-        geometry.setAttribute('color', new THREE.BufferAttribute(new Float32Array(colors), 3));
-        avgAlpha /= pointsArray.length;
+        avgAlpha /= Math.max(count, 1);
+
+        const geometry = new THREE.BufferGeometry();
+        geometry.setAttribute('position', new THREE.BufferAttribute(vertices.subarray(0, count * 3), 3));
+        geometry.setAttribute('color', new THREE.BufferAttribute(colors.subarray(0, count * 3), 3));
 
         // Материал с поддержкой прозрачности, без текстуры (будут квадраты)
         const material = new THREE.PointsMaterial({
@@ -72,7 +382,7 @@ export default class PointCloud {
             vertexColors: true,
             alphaTest: 0.01,
             transparent: true,
-            opacity: avgAlpha
+            opacity: mode === 'distance' ? avgAlpha : 1
         });
 
         this.points = new THREE.Points(geometry, material);
@@ -111,6 +421,7 @@ export default class PointCloud {
 
     animate() {
         requestAnimationFrame(() => this.animate());
+        this.redrawMap();
         this.controls.update();
         this.renderer.render(this.scene, this.camera);
     }
@@ -4,6 +4,24 @@ import PointCloud from './PointCloud.js';
 document.querySelector('#app').innerHTML = `
   <div>
     <h1>Визуализация облака точек</h1>
+    <div style="margin: 10px 0;">
+      <label for="vehicle-select">ТС:</label>
+      <select id="vehicle-select"></select>
+      <label for="color-select">Цвет:</label>
+      <select id="color-select">
+        <option value="distance">Расстояние</option>
+        <option value="intensity">Интенсивность</option>
+        <option value="ring">Кольцо</option>
+        <option value="return">Тип отражения</option>
+        <option value="sensor">Сенсор</option>
+      </select>
+      <label><input type="checkbox" id="ground-toggle" checked> Земля</label>
+      <label><input type="checkbox" id="obstacles-toggle" checked> Препятствия</label>
+      <label><input type="checkbox" id="tracks-toggle" checked> Треки</label>
+      <label><input type="checkbox" id="grid-toggle" checked> Сетка высот</label>
+      <label><input type="checkbox" id="trajectory-toggle" checked> Траектория</label>
+      <label><input type="checkbox" id="map-toggle" checked> Карта</label>
+    </div>
     <div style="margin: 10px 0;">
       <button id="preset-top">Вид сверху</button>
       <button id="preset-driver">Вид от водителя</button>
@@ -13,10 +31,57 @@ document.querySelector('#app').innerHTML = `
   </div>
 `
 
+// Детализация облака (параметр lod страницы) передаётся серверу как есть
+const lod = new URLSearchParams(window.location.search).get('lod');
+const sseUrl = (vehicleId) => `${window.location.origin}/sse?vehicle=${encodeURIComponent(vehicleId)}` +
+  (lod ? `&lod=${encodeURIComponent(lod)}` : '');
+
+// Обновляет список ТС, сохраняя текущий выбор
+async function refreshVehicles(select, onChange) {
+  try {
+    const response = await fetch(`${window.location.origin}/vehicles`);
+    const vehicles = await response.json();
+    const current = select.value;
+    select.innerHTML = '';
+    for (const vehicle of vehicles) {
+      const option = document.createElement('option');
+      option.value = vehicle.id;
+      option.textContent = vehicle.active ? vehicle.id : `${vehicle.id} (нет связи)`;
+      select.appendChild(option);
+    }
+    if (vehicles.some((v) => v.id === current)) {
+      select.value = current;
+    } else if (vehicles.length > 0) {
+      onChange(select.value);
+    }
+  } catch (e) {
+    console.error('Не удалось получить список ТС', e);
+  }
+}
+
 // Инициализация облака точек
 let pointCloudInstance;
 window.addEventListener('DOMContentLoaded', () => {
-  pointCloudInstance = new PointCloud('pointcloud-container', `${window.location.origin}/sse`);
+  pointCloudInstance = new PointCloud('pointcloud-container', null);
+  const vehicleSelect = document.getElementById('vehicle-select');
+  const selectVehicle = (vehicleId) => pointCloudInstance.setSource(sseUrl(vehicleId));
+  vehicleSelect.onchange = () => selectVehicle(vehicleSelect.value);
+  const colorSelect = document.getElementById('color-select');
+  colorSelect.onchange = () => pointCloudInstance.setColorMode(colorSelect.value);
+  const groundToggle = document.getElementById('ground-toggle');
+  groundToggle.onchange = () => pointCloudInstance.setGroundVisible(groundToggle.checked);
+  const obstaclesToggle = document.getElementById('obstacles-toggle');
+  obstaclesToggle.onchange = () => pointCloudInstance.setObstaclesVisible(obstaclesToggle.checked);
+  const tracksToggle = document.getElementById('tracks-toggle');
+  tracksToggle.onchange = () => pointCloudInstance.setTracksVisible(tracksToggle.checked);
+  const gridToggle = document.getElementById('grid-toggle');
+  gridToggle.onchange = () => pointCloudInstance.setGridVisible(gridToggle.checked);
+  const trajectoryToggle = document.getElementById('trajectory-toggle');
+  trajectoryToggle.onchange = () => pointCloudInstance.setTrajectoryVisible(trajectoryToggle.checked);
+  const mapToggle = document.getElementById('map-toggle');
+  mapToggle.onchange = () => pointCloudInstance.setMapVisible(mapToggle.checked);
+  refreshVehicles(vehicleSelect, selectVehicle);
+  setInterval(() => refreshVehicles(vehicleSelect, selectVehicle), 5000);
   document.getElementById('preset-top').onclick = () => pointCloudInstance.setCameraPreset('top');
   document.getElementById('preset-driver').onclick = () => pointCloudInstance.setCameraPreset('driver');
   document.getElementById('preset-side').onclick = () => pointCloudInstance.setCameraPreset('side');